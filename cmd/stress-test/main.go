@@ -12,6 +12,17 @@ func main() {
 	root.AddCommand(commands.NewRunCmd())
 	root.AddCommand(commands.NewCurlCmd())
 	root.AddCommand(commands.NewRampCmd())
+	root.AddCommand(commands.NewCapacityCmd())
+	root.AddCommand(commands.NewAdaptiveCmd())
+	root.AddCommand(commands.NewServeCmd())
+	root.AddCommand(commands.NewProxyCmd())
+	root.AddCommand(commands.NewRecordCmd())
+	root.AddCommand(commands.NewReplayCmd())
+	root.AddCommand(commands.NewReplayLogCmd())
+	root.AddCommand(commands.NewOpenAPICmd())
+	root.AddCommand(commands.NewReportCmd())
+	root.AddCommand(commands.NewCompareCmd())
+	root.AddCommand(commands.NewHistoryCmd())
 	root.AddCommand(commands.NewDocsCmd())
 
 	cli.Execute(root)
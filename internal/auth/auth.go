@@ -0,0 +1,30 @@
+// Package auth provides request authentication for load tests: static basic
+// and bearer credentials and an OAuth2 client-credentials token source.
+package auth
+
+import (
+	"net/http"
+)
+
+// Basic sets HTTP basic authentication on every request.
+type Basic struct {
+	Username string
+	Password string
+}
+
+// Prepare implements runner.Hook.
+func (b Basic) Prepare(req *http.Request, _ []byte) error {
+	req.SetBasicAuth(b.Username, b.Password)
+	return nil
+}
+
+// Bearer sets a static bearer token on every request.
+type Bearer struct {
+	Token string
+}
+
+// Prepare implements runner.Hook.
+func (b Bearer) Prepare(req *http.Request, _ []byte) error {
+	req.Header.Set("Authorization", "Bearer "+b.Token)
+	return nil
+}
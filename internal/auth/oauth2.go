@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultRefreshBefore is how long before expiry a token is renewed when
+// ClientCredentials.RefreshBefore is not set.
+const defaultRefreshBefore = 30 * time.Second
+
+// After a failed fetch the token endpoint is not tried again for a backoff
+// doubling from minRetryBackoff up to maxRetryBackoff, so an outage of the
+// endpoint does not hold every worker behind one fetch after another.
+const (
+	minRetryBackoff = time.Second
+	maxRetryBackoff = 30 * time.Second
+)
+
+// ClientCredentials fetches OAuth2 access tokens with the client-credentials
+// grant (RFC 6749, section 4.4). The token is cached and shared by all
+// workers, and renewed shortly before it expires so long soak tests never
+// send an expired token.
+type ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// Params holds extra form parameters sent to the token endpoint
+	// (for example "audience").
+	Params url.Values
+	// CredentialsInBody sends client_id/client_secret as form parameters
+	// instead of HTTP basic authentication.
+	CredentialsInBody bool
+	// RefreshBefore renews the token this long before it expires.
+	RefreshBefore time.Duration
+	// Client is used to reach the token endpoint (default: 30s timeout).
+	Client *http.Client
+
+	mu       sync.Mutex
+	token    string
+	expiry   time.Time // zero when the server did not report expires_in
+	lifetime time.Duration
+	// refreshing is closed when the fetch in flight ends; nil when none
+	// runs.
+	refreshing chan struct{}
+	// failures counts the fetches failed in a row; until retryAt the
+	// cached token, or lastErr when there is none, is returned instead.
+	failures int
+	retryAt  time.Time
+	lastErr  error
+}
+
+// tokenResponse is the subset of the token endpoint response we use.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// Prepare implements runner.Hook.
+func (c *ClientCredentials) Prepare(req *http.Request, _ []byte) error {
+	tok, err := c.Token(req.Context())
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+tok)
+	return nil
+}
+
+// Token returns a valid access token, fetching a new one when none is cached
+// or the cached one is about to expire. One fetch runs at a time, outside
+// the lock: while it runs, other callers get the cached token if it is still
+// valid and wait for the fetch otherwise. If a refresh fails while the
+// cached token is still valid, the cached token keeps being used; after a
+// failure the endpoint is retried with backoff, and in between callers get
+// the cached token or the last error without waiting.
+func (c *ClientCredentials) Token(ctx context.Context) (string, error) {
+	for {
+		c.mu.Lock()
+		now := time.Now()
+		valid := c.token != "" && (c.expiry.IsZero() || now.Before(c.expiry))
+		if valid && (c.expiry.IsZero() || now.Before(c.expiry.Add(-c.refreshBefore()))) {
+			tok := c.token
+			c.mu.Unlock()
+			return tok, nil
+		}
+		if now.Before(c.retryAt) || (valid && c.refreshing != nil) {
+			tok, err := c.token, c.lastErr
+			c.mu.Unlock()
+			if valid {
+				return tok, nil
+			}
+			return "", err
+		}
+		if wait := c.refreshing; wait != nil {
+			c.mu.Unlock()
+			select {
+			case <-wait:
+				continue
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+		done := make(chan struct{})
+		c.refreshing = done
+		c.mu.Unlock()
+
+		tok, err := c.fetch(ctx)
+
+		c.mu.Lock()
+		c.refreshing = nil
+		close(done)
+		if err != nil {
+			c.failures++
+			c.retryAt = time.Now().Add(min(minRetryBackoff<<min(c.failures-1, 5), maxRetryBackoff))
+			c.lastErr = err
+		} else {
+			c.failures, c.retryAt, c.lastErr = 0, time.Time{}, nil
+			c.token = tok.AccessToken
+			c.expiry = time.Time{}
+			c.lifetime = time.Duration(tok.ExpiresIn) * time.Second
+			if c.lifetime > 0 {
+				c.expiry = now.Add(c.lifetime)
+			}
+		}
+		cached := c.token
+		c.mu.Unlock()
+		if err != nil && !valid {
+			return "", err
+		}
+		return cached, nil
+	}
+}
+
+// refreshBefore caps the refresh margin at half the token lifetime so very
+// short-lived tokens are not refreshed on every request.
+func (c *ClientCredentials) refreshBefore() time.Duration {
+	d := c.RefreshBefore
+	if d <= 0 {
+		d = defaultRefreshBefore
+	}
+	if c.lifetime > 0 && d > c.lifetime/2 {
+		d = c.lifetime / 2
+	}
+	return d
+}
+
+func (c *ClientCredentials) fetch(ctx context.Context) (tokenResponse, error) {
+	if c.TokenURL == "" {
+		return tokenResponse{}, errors.New("oauth2: token URL is required")
+	}
+	form := url.Values{}
+	for k, vals := range c.Params {
+		form[k] = append([]string(nil), vals...)
+	}
+	form.Set("grant_type", "client_credentials")
+	if len(c.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	if c.CredentialsInBody {
+		form.Set("client_id", c.ClientID)
+		form.Set("client_secret", c.ClientSecret)
+	}
+
+	// Use a context that survives the cancellation of the triggering request;
+	// the token is shared by every worker.
+	ctx = context.WithoutCancel(ctx)
+	client := c.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("oauth2: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if !c.CredentialsInBody {
+		req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("oauth2: token request: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("oauth2: read token response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return tokenResponse{}, fmt.Errorf("oauth2: token endpoint returned %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	var tok tokenResponse
+	if err := json.Unmarshal(data, &tok); err != nil {
+		return tokenResponse{}, fmt.Errorf("oauth2: decode token response: %w", err)
+	}
+	if tok.AccessToken == "" {
+		return tokenResponse{}, errors.New("oauth2: token response has no access_token")
+	}
+	return tok, nil
+}
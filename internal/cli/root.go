@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -17,7 +18,18 @@ func NewRootCmd() *cobra.Command {
 Use the subcommands to run different kinds of tests:
 	- run   : Fire a fixed number of requests with a given concurrency
 	- ramp  : Execute multiple phases ramping concurrency (by requests, duration, or target RPS)
+	- capacity: Search for the highest request rate that meets latency and error SLOs
+	- adaptive: Adjust concurrency continuously to hold p95 latency at a target
 	- curl  : Send a single HTTP request using a small subset of curl flags
+	- serve : Start a local target server for calibration and testing
+	- proxy : Fault-injecting reverse proxy for resilience tests
+	- record: Capture HTTP traffic through a local proxy into a scenario file
+	- replay: Replay a recorded scenario as a load test (original, scaled or max speed)
+	- replay-log: Replay nginx/Apache/JSON access logs against a base URL
+	- openapi: Generate a replayable scenario from an OpenAPI 3 document
+	- report: Render a saved JSON result as text, Markdown, CSV, HTML (with charts) or JUnit
+	- compare: Diff two saved JSON results and exit non-zero on regressions
+	- history: List, show and follow the trend of results saved with --history
 	- version: Print build information (version, commit, date)
 
 Global flags:
@@ -46,10 +58,35 @@ stress-test version`,
 	return cmd
 }
 
+// ExitError makes Execute exit with Code instead of 1. Err is printed
+// unless it is nil.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit status %d", e.Code)
+	}
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error { return e.Err }
+
 // Execute runs the root command and handles errors consistently.
 func Execute(root *cobra.Command) {
-	if err := root.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+	err := root.Execute()
+	if err == nil {
+		return
+	}
+	var exit *ExitError
+	if errors.As(err, &exit) {
+		if exit.Err != nil {
+			fmt.Fprintln(os.Stderr, exit.Err)
+		}
+		os.Exit(exit.Code)
 	}
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
 }
@@ -0,0 +1,252 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/JeanGrijp/stress-test/internal/report"
+	"github.com/JeanGrijp/stress-test/internal/runner"
+	"github.com/spf13/cobra"
+)
+
+// NewAdaptiveCmd runs a test whose concurrency is adjusted continuously to
+// hold p95 latency at a target, with an AIMD or PID controller.
+// Example:
+//
+//	stress-test adaptive --url=https://example.com --target-p95=200ms --duration=2m
+func NewAdaptiveCmd() *cobra.Command {
+	var (
+		targetURL        string
+		targetP95        time.Duration
+		duration         time.Duration
+		interval         time.Duration
+		controller       string
+		startConcurrency int
+		minConcurrency   int
+		maxConcurrency   int
+		increase         int
+		decrease         float64
+		kp, ki, kd       float64
+		reqF             requestFlags
+		curlF            curlFlags
+		outF             outFlags
+		thresholds       []string
+		output           string
+		outFile          string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "adaptive",
+		Short: "Adjust concurrency continuously to hold p95 latency at a target",
+		Long: `Run a test whose concurrency is adjusted continuously to hold p95 latency at
+--target-p95, which tells how much load the target takes at a latency budget.
+
+After every --interval a controller looks at the p95 and the failures of the
+requests finished during it and picks the concurrency of the next one, within
+--min-concurrency and --max-concurrency:
+	aimd: additive increase, multiplicative decrease; doubles the concurrency
+	      until the first interval over the target (slow start), then adds
+	      --increase workers while p95 is at or under the target and
+	      multiplies the concurrency by --decrease when it is over
+	pid:  scales the concurrency by 1 + ki*e + kp*Δe + kd*Δ²e, a PID
+	      controller in velocity form on the relative p95 error
+	      e = (target-p95)/target, with gains --kp, --ki, --kd
+More than 1% of failed requests in an interval (no response or 5xx) counts
+as overload whatever the latency, so fast failures do not pass for headroom.
+
+The output holds the trajectory (concurrency, requests, throughput, failures
+and p95 per interval; sampled to 30 rows in text) and where the controller
+settled: the medians of concurrency, throughput and p95 over the second half
+of the test. JSON keeps the whole trajectory and HTML charts the concurrency
+next to throughput and latency. Every interval is also printed on stderr.
+
+The request is set up like in ramp: --url, --method, --header, --body, forms,
+authentication, signing, templates and --from-curl. --threshold checks the
+totals like in run (e.g. 'rps>=500') and exits with status 2 when one fails.`,
+		Example: `# Find the load that keeps p95 under 200ms for 2 minutes
+stress-test adaptive --url https://example.com/api --target-p95 200ms --duration 2m
+
+# PID controller, bounded concurrency, every 2s, result as JSON
+stress-test adaptive --url https://example.com/api --target-p95 150ms --controller pid \
+	--interval 2s --max-concurrency 200 --output json --out-file adaptive.json
+
+# Fail CI unless 500 rps are served within the budget
+stress-test adaptive --url https://example.com/api --target-p95 300ms --threshold 'rps>=500'`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// validations
+			if err := curlF.conflicts(cmd); err != nil {
+				return err
+			}
+			var curlReqs []curlRequest
+			if curlF.command != "" {
+				var err error
+				if curlReqs, err = curlF.load(cmd.InOrStdin(), cmd.ErrOrStderr()); err != nil {
+					return err
+				}
+				if len(curlReqs) > 1 {
+					return fmt.Errorf("--from-curl: adaptive takes a single curl command, got %d", len(curlReqs))
+				}
+				targetURL, reqF.method = curlReqs[0].URL, curlReqs[0].Method
+			} else {
+				if targetURL == "" {
+					return errors.New("--url or --from-curl is required")
+				}
+				if _, err := url.ParseRequestURI(targetURL); err != nil {
+					return fmt.Errorf("invalid --url: %w", err)
+				}
+			}
+
+			if err := reqF.normalizeMethod(cmd, curlF.command != ""); err != nil {
+				return err
+			}
+			hdr, err := parseHeaderFlags(reqF.headers)
+			if err != nil {
+				return err
+			}
+			if curlF.print {
+				if curlReqs != nil {
+					writeCurl(cmd.OutOrStdout(), cmd.ErrOrStderr(), reqF.curls(curlReqs, hdr))
+				} else {
+					writeCurl(cmd.OutOrStdout(), cmd.ErrOrStderr(), []curlCommand{reqF.curl(targetURL, hdr)})
+				}
+				return nil
+			}
+
+			outs, err := outF.parse(cmd, output, outFile)
+			if err != nil {
+				return err
+			}
+			ths, err := parseThresholds(thresholds)
+			if err != nil {
+				return err
+			}
+			switch {
+			case targetP95 <= 0:
+				return errors.New("--target-p95 is required (e.g. 200ms)")
+			case duration <= 0:
+				return errors.New("--duration must be > 0")
+			case interval <= 0 || interval > duration:
+				return errors.New("--interval must be > 0 and at most --duration")
+			case minConcurrency <= 0:
+				return errors.New("--min-concurrency must be > 0")
+			case maxConcurrency < minConcurrency:
+				return errors.New("--max-concurrency must be >= --min-concurrency")
+			case startConcurrency < minConcurrency || startConcurrency > maxConcurrency:
+				return errors.New("--start-concurrency must be within --min-concurrency and --max-concurrency")
+			}
+
+			controller = strings.ToLower(strings.TrimSpace(controller))
+			var ctrl runner.Controller
+			switch controller {
+			case "aimd":
+				if increase <= 0 {
+					return errors.New("--increase must be > 0")
+				}
+				if decrease <= 0 || decrease >= 1 {
+					return errors.New("--decrease must be between 0 and 1 (e.g. 0.75)")
+				}
+				ctrl = &runner.AIMD{Target: targetP95, Increase: increase, Decrease: decrease}
+			case "pid":
+				if kp < 0 || ki < 0 || kd < 0 || kp+ki+kd == 0 {
+					return errors.New("--kp, --ki and --kd must be >= 0 and not all 0")
+				}
+				ctrl = &runner.PID{Target: targetP95, Kp: kp, Ki: ki, Kd: kd}
+			default:
+				return fmt.Errorf("unsupported --controller: %s (use aimd or pid)", controller)
+			}
+
+			var cr *curlRequest
+			if curlReqs != nil {
+				cr = &curlReqs[0]
+			}
+			opts, _, err := reqF.options(hdr, cr, cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+			if err := outs.open(cmd.OutOrStdout()); err != nil {
+				return err
+			}
+			defer outs.close()
+			opts.Sinks = outs.sinks
+
+			fmt.Fprintf(cmd.ErrOrStderr(), "Adaptive (%s): target p95=%s, concurrency=%d..%d, duration=%s\n",
+				controller, targetP95, minConcurrency, maxConcurrency, duration)
+			aopts := runner.AdaptiveOptions{
+				Controller: ctrl,
+				Interval:   interval,
+				Start:      startConcurrency,
+				Min:        minConcurrency,
+				Max:        maxConcurrency,
+				OnStep: func(s runner.AdaptiveStep) {
+					fmt.Fprintf(cmd.ErrOrStderr(), "t=%s: concurrency=%d, rps=%.2f, failed=%d, p95=%s\n",
+						roundDuration(s.At), s.Concurrency, s.RPS, s.Failed, roundDuration(s.P95))
+				},
+			}
+			ctx, cancel := context.WithCancel(cmd.Context())
+			defer cancel()
+			rep, steps, err := runner.RunAdaptive(ctx, targetURL, duration, opts, aopts)
+			if err != nil {
+				return err
+			}
+			// flush the per-request log and push the final metrics; a
+			// failure is reported once the results are written
+			sinkErr := outs.close()
+
+			prepares := opts.BodyFunc != nil || len(opts.Hooks) > 0
+			res := newResult("adaptive", rep, prepares)
+			res.URL, res.Method = targetURL, reqF.method
+			res.Adaptive = &report.Adaptive{
+				Controller: controller,
+				TargetP95:  ms(targetP95),
+				IntervalMS: interval.Milliseconds(),
+			}
+			for _, s := range steps {
+				res.Adaptive.Trajectory = append(res.Adaptive.Trajectory, report.AdaptiveStep{
+					AtMS:        s.At.Milliseconds(),
+					Concurrency: float64(s.Concurrency),
+					Requests:    s.Requests,
+					RPS:         s.RPS,
+					Failed:      s.Failed,
+					P95:         ms(s.P95),
+				})
+			}
+			res.Adaptive.Settle()
+			res.Config = testConfig(cmd, "target-p95", "duration", "interval", "controller", "start-concurrency",
+				"min-concurrency", "max-concurrency", "method")
+			res.CheckThresholds(ths)
+			if err := outs.write(cmd.OutOrStdout(), res, report.Options{}); err != nil {
+				return err
+			}
+			if sinkErr != nil {
+				return sinkErr
+			}
+			return thresholdsError(res)
+		},
+	}
+
+	cmd.Flags().StringVar(&targetURL, "url", "", "Target URL to test")
+	cmd.Flags().DurationVar(&targetP95, "target-p95", 0, "p95 latency to hold (required)")
+	cmd.Flags().DurationVar(&duration, "duration", time.Minute, "Test duration")
+	cmd.Flags().DurationVar(&interval, "interval", time.Second, "Control interval: how often the concurrency is adjusted")
+	cmd.Flags().StringVar(&controller, "controller", "aimd", "Controller: aimd|pid")
+	cmd.Flags().IntVar(&startConcurrency, "start-concurrency", 5, "Concurrency of the first interval")
+	cmd.Flags().IntVar(&minConcurrency, "min-concurrency", 1, "Lowest concurrency")
+	cmd.Flags().IntVar(&maxConcurrency, "max-concurrency", 500, "Highest concurrency")
+	cmd.Flags().IntVar(&increase, "increase", 1, "aimd: workers added after an interval within the target")
+	cmd.Flags().Float64Var(&decrease, "decrease", 0.75, "aimd: concurrency multiplier after an interval over the target")
+	cmd.Flags().Float64Var(&kp, "kp", 0.3, "pid: proportional gain")
+	cmd.Flags().Float64Var(&ki, "ki", 0.3, "pid: integral gain")
+	cmd.Flags().Float64Var(&kd, "kd", 0.05, "pid: derivative gain")
+	reqF.register(cmd)
+	curlF.register(cmd)
+	outF.register(cmd)
+	cmd.Flags().StringArrayVar(&thresholds, "threshold", nil, "Pass/fail criterion such as 'p95<300ms', 'error_rate<1%' or 'rps>=500' (repeatable)")
+	cmd.Flags().StringVar(&output, "output", "text", "Output format: text|json|markdown|csv|junit|html")
+	cmd.Flags().StringVar(&outFile, "out-file", "", "Write the results to file instead of stdout")
+
+	return cmd
+}
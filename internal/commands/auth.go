@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/JeanGrijp/stress-test/internal/auth"
+	"github.com/JeanGrijp/stress-test/internal/runner"
+	"github.com/spf13/cobra"
+)
+
+// authFlags holds the authentication flags shared by run and ramp.
+type authFlags struct {
+	basic             string
+	bearer            string
+	tokenURL          string
+	clientID          string
+	clientSecret      string
+	scopes            []string
+	params            []string
+	credentialsInBody bool
+	refreshBefore     time.Duration
+}
+
+// register adds the authentication flags to cmd.
+func (a *authFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&a.basic, "auth-basic", "", "HTTP basic auth credentials 'user:password'")
+	cmd.Flags().StringVar(&a.bearer, "auth-bearer", "", "Static bearer token (or @file to read it from a file)")
+	cmd.Flags().StringVar(&a.tokenURL, "oauth2-token-url", "", "OAuth2 token endpoint for the client-credentials grant")
+	cmd.Flags().StringVar(&a.clientID, "oauth2-client-id", "", "OAuth2 client ID")
+	cmd.Flags().StringVar(&a.clientSecret, "oauth2-client-secret", "", "OAuth2 client secret (or @file to read it from a file)")
+	cmd.Flags().StringArrayVar(&a.scopes, "oauth2-scope", nil, "OAuth2 scope to request (repeatable)")
+	cmd.Flags().StringArrayVar(&a.params, "oauth2-param", nil, "Extra token request parameter 'key=value', e.g. audience (repeatable)")
+	cmd.Flags().BoolVar(&a.credentialsInBody, "oauth2-credentials-in-body", false, "Send client credentials as form parameters instead of basic auth")
+	cmd.Flags().DurationVar(&a.refreshBefore, "oauth2-refresh-before", 30*time.Second, "Refresh the OAuth2 token this long before it expires")
+}
+
+// hook returns the runner hook for the configured authentication, or nil
+// when no authentication flag is set.
+func (a *authFlags) hook(stdin io.Reader) (runner.Hook, error) {
+	set := 0
+	for _, v := range []string{a.basic, a.bearer, a.tokenURL} {
+		if v != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, errors.New("use only one of --auth-basic, --auth-bearer or --oauth2-token-url")
+	}
+
+	switch {
+	case a.basic != "":
+		user, pass, ok := strings.Cut(a.basic, ":")
+		if !ok || user == "" {
+			return nil, errors.New("invalid --auth-basic (use 'user:password')")
+		}
+		return auth.Basic{Username: user, Password: pass}, nil
+	case a.bearer != "":
+		tok, err := readBodyArg(a.bearer, stdin)
+		if err != nil {
+			return nil, fmt.Errorf("--auth-bearer: %w", err)
+		}
+		return auth.Bearer{Token: strings.TrimSpace(string(tok))}, nil
+	case a.tokenURL != "":
+		if _, err := url.ParseRequestURI(a.tokenURL); err != nil {
+			return nil, fmt.Errorf("invalid --oauth2-token-url: %w", err)
+		}
+		if a.clientID == "" {
+			return nil, errors.New("--oauth2-client-id is required with --oauth2-token-url")
+		}
+		secret, err := readBodyArg(a.clientSecret, stdin)
+		if err != nil {
+			return nil, fmt.Errorf("--oauth2-client-secret: %w", err)
+		}
+		params := url.Values{}
+		for _, p := range a.params {
+			k, v, ok := strings.Cut(p, "=")
+			if !ok || k == "" {
+				return nil, fmt.Errorf("invalid --oauth2-param (use 'key=value'): %q", p)
+			}
+			params.Add(k, v)
+		}
+		return &auth.ClientCredentials{
+			TokenURL:          a.tokenURL,
+			ClientID:          a.clientID,
+			ClientSecret:      strings.TrimSpace(string(secret)),
+			Scopes:            a.scopes,
+			Params:            params,
+			CredentialsInBody: a.credentialsInBody,
+			RefreshBefore:     a.refreshBefore,
+		}, nil
+	default:
+		if a.clientID != "" || a.clientSecret != "" {
+			return nil, errors.New("--oauth2-client-id/--oauth2-client-secret require --oauth2-token-url")
+		}
+		return nil, nil
+	}
+}
+
+// curlArgs returns the curl arguments for the configured authentication,
+// and notes for what curl cannot do.
+func (a *authFlags) curlArgs() (args, notes []string) {
+	switch {
+	case a.basic != "":
+		args = append(args, "-u", a.basic)
+	case strings.HasPrefix(a.bearer, "@"):
+		notes = append(notes, fmt.Sprintf("--auth-bearer reads the token from %s; add -H 'Authorization: Bearer <token>'", a.bearer[1:]))
+	case a.bearer != "":
+		args = append(args, "--oauth2-bearer", a.bearer)
+	case a.tokenURL != "":
+		notes = append(notes, "curl cannot run the OAuth2 client-credentials grant; fetch a token from "+a.tokenURL+" and add -H 'Authorization: Bearer <token>'")
+	}
+	return args, notes
+}
@@ -0,0 +1,110 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// readBodyArg resolves a body argument using curl's conventions:
+//
+//	@-      read the whole payload from stdin
+//	@path   read the whole payload from the file at path
+//	other   use the value literally
+//
+// The payload is read once and returned as raw bytes so it can be shared
+// across workers without re-reading.
+func readBodyArg(arg string, stdin io.Reader) ([]byte, error) {
+	if !strings.HasPrefix(arg, "@") {
+		return []byte(arg), nil
+	}
+	return readBodySource(strings.TrimPrefix(arg, "@"), stdin)
+}
+
+// readBodySource reads a payload from path, or from stdin when path is "-".
+func readBodySource(path string, stdin io.Reader) ([]byte, error) {
+	if path == "" {
+		return nil, errors.New("empty body file name")
+	}
+	if path == "-" {
+		if stdin == nil {
+			return nil, errors.New("stdin is not available")
+		}
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, fmt.Errorf("read body from stdin: %w", err)
+		}
+		return data, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read body file: %w", err)
+	}
+	return data, nil
+}
+
+// loadBody returns the request body configured by --body and --body-file.
+// Only one of them may be set.
+func loadBody(body, bodyFile string, stdin io.Reader) ([]byte, error) {
+	if body != "" && bodyFile != "" {
+		return nil, errors.New("use either --body or --body-file, not both")
+	}
+	if bodyFile != "" {
+		return readBodySource(bodyFile, stdin)
+	}
+	if body == "" {
+		return nil, nil
+	}
+	return readBodyArg(body, stdin)
+}
+
+// stripNewlines removes carriage returns and newlines, mirroring how curl
+// treats files passed with -d @file.
+func stripNewlines(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for _, b := range data {
+		if b == '\r' || b == '\n' {
+			continue
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// urlEncodeDataArg encodes a --data-urlencode argument following curl's rules:
+//
+//	content        URL-encode content
+//	=content       URL-encode content (the leading '=' is dropped)
+//	name=content   URL-encode content and prefix it with name=
+//	@file          URL-encode the contents of file
+//	name@file      URL-encode the contents of file and prefix it with name=
+func urlEncodeDataArg(arg string, stdin io.Reader) (string, error) {
+	eq := strings.IndexByte(arg, '=')
+	at := strings.IndexByte(arg, '@')
+	switch {
+	case eq == 0:
+		return curlEscape(arg[1:]), nil
+	case eq > 0 && (at < 0 || eq < at):
+		return arg[:eq] + "=" + curlEscape(arg[eq+1:]), nil
+	case at >= 0:
+		data, err := readBodySource(arg[at+1:], stdin)
+		if err != nil {
+			return "", err
+		}
+		enc := curlEscape(string(data))
+		if at == 0 {
+			return enc, nil
+		}
+		return arg[:at] + "=" + enc, nil
+	default:
+		return curlEscape(arg), nil
+	}
+}
+
+// curlEscape percent-encodes s the way curl does, using %20 for spaces.
+func curlEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
@@ -0,0 +1,377 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"time"
+
+	"github.com/JeanGrijp/stress-test/internal/cli"
+	"github.com/JeanGrijp/stress-test/internal/report"
+	"github.com/JeanGrijp/stress-test/internal/runner"
+	"github.com/spf13/cobra"
+)
+
+// minProbeRPS is the lowest rate the capacity search probes when it has to
+// go down from --start-rps.
+const minProbeRPS = 1.0
+
+// NewCapacityCmd searches for the highest request rate that still meets
+// the SLOs: it grows the rate geometrically until a probe fails, bisects
+// between the last passing and the first failing rate, then probes the
+// capacity once more.
+// Example:
+//
+//	stress-test capacity --url=https://example.com --slo 'p99<300ms' --slo 'error_rate<1%'
+func NewCapacityCmd() *cobra.Command {
+	var (
+		targetURL      string
+		slos           []string
+		startRPS       float64
+		maxRPS         float64
+		growth         float64
+		precision      float64
+		probeDuration  time.Duration
+		maxProbes      int
+		concurrency    int
+		maxConcurrency int
+		sleepBetween   time.Duration
+		timeout        time.Duration
+		reqF           requestFlags
+		curlF          curlFlags
+		outF           outFlags
+		output         string
+		outFile        string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "capacity",
+		Short: "Search for the highest request rate that meets the SLOs",
+		Long: `Search for the capacity of the target: the highest request rate that still
+meets the SLOs.
+
+Each probe sends requests at a fixed rate for --probe-duration and passes when
+every --slo holds and at least 95% of the rate was delivered. The search:
+	1. explore: starts at --start-rps and multiplies the rate by --growth after
+	   every passing probe (divides it while the first probes fail), until
+	   one passes and one fails or --max-rps passes
+	2. bisect:  halves the interval between the highest passing and the lowest
+	   failing rate until it is within --precision
+	3. verify:  probes the capacity once more
+
+SLOs use the threshold syntax of 'stress-test run --threshold': p50, p90, p95,
+p99, mean and max latencies and the error_rate (default 'p99<500ms' and
+'error_rate<1%').
+
+The report lists every probe, the capacity, the first failing rate above it
+and a confidence: high when the capacity passed again and every percentile and
+error rate SLO holds at its one-sided 95% upper bound; medium when the bracket
+is wider than --precision, the capacity was probed once or an SLO is within
+sampling noise of its limit (longer probes help); low when the capacity failed
+when probed again or no rate met the SLOs. Probes are the phases of the JSON
+result, with their full results.
+
+Flags overview:
+	--slo              SLO such as 'p99<300ms' or 'error_rate<1%' (repeatable)
+	--start-rps        Rate of the first probe
+	--max-rps          Highest rate to probe (0 = no limit)
+	--growth           Rate multiplier while exploring
+	--precision        Relative width of the final bracket (0.05 = 5%)
+	--probe-duration   Duration of every probe
+	--max-probes       Most probes before verification
+	--concurrency      Workers per probe (0 = enough for the rate at the
+	                   slowest latency SLO, up to --max-concurrency)
+
+The request is set up like in ramp: --url, --method, --header, --body, forms,
+authentication, signing, templates and --from-curl. Progress goes to stderr.
+The command exits with status 2 when no rate met the SLOs.`,
+		Example: `# Highest rate with p99 under 300ms and under 1% errors
+stress-test capacity --url https://example.com/api --slo 'p99<300ms' --slo 'error_rate<1%'
+
+# Finer search from 200 rps with 30s probes, never above 5000 rps
+stress-test capacity --url https://example.com/api --start-rps 200 --max-rps 5000 \
+	--probe-duration 30s --precision 0.02 --output json --out-file capacity.json
+
+# Capacity of a request copied from the browser
+stress-test capacity --from-curl @checkout.curl --slo 'p95<800ms'`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// validations
+			if err := curlF.conflicts(cmd); err != nil {
+				return err
+			}
+			var curlReqs []curlRequest
+			if curlF.command != "" {
+				var err error
+				if curlReqs, err = curlF.load(cmd.InOrStdin(), cmd.ErrOrStderr()); err != nil {
+					return err
+				}
+				if len(curlReqs) > 1 {
+					return fmt.Errorf("--from-curl: capacity takes a single curl command, got %d", len(curlReqs))
+				}
+				targetURL, reqF.method = curlReqs[0].URL, curlReqs[0].Method
+			} else {
+				if targetURL == "" {
+					return errors.New("--url or --from-curl is required")
+				}
+				if _, err := url.ParseRequestURI(targetURL); err != nil {
+					return fmt.Errorf("invalid --url: %w", err)
+				}
+			}
+
+			if err := reqF.normalizeMethod(cmd, curlF.command != ""); err != nil {
+				return err
+			}
+			hdr, err := parseHeaderFlags(reqF.headers)
+			if err != nil {
+				return err
+			}
+			if curlF.print {
+				if curlReqs != nil {
+					writeCurl(cmd.OutOrStdout(), cmd.ErrOrStderr(), reqF.curls(curlReqs, hdr))
+				} else {
+					writeCurl(cmd.OutOrStdout(), cmd.ErrOrStderr(), []curlCommand{reqF.curl(targetURL, hdr)})
+				}
+				return nil
+			}
+
+			outs, err := outF.parse(cmd, output, outFile)
+			if err != nil {
+				return err
+			}
+			sloTs, err := parseSLOs(slos)
+			if err != nil {
+				return err
+			}
+			switch {
+			case startRPS <= 0:
+				return errors.New("--start-rps must be > 0")
+			case maxRPS < 0:
+				return errors.New("--max-rps must be >= 0")
+			case maxRPS > 0 && maxRPS < startRPS:
+				return errors.New("--max-rps must be >= --start-rps")
+			case growth <= 1:
+				return errors.New("--growth must be > 1")
+			case precision <= 0 || precision >= 1:
+				return errors.New("--precision must be between 0 and 1 (e.g. 0.05 for 5%)")
+			case probeDuration <= 0:
+				return errors.New("--probe-duration must be > 0")
+			case timeout < probeDuration:
+				return errors.New("--timeout must be >= --probe-duration")
+			case maxProbes <= 0:
+				return errors.New("--max-probes must be > 0")
+			case concurrency < 0:
+				return errors.New("--concurrency must be >= 0")
+			case maxConcurrency <= 0:
+				return errors.New("--max-concurrency must be > 0")
+			}
+
+			var cr *curlRequest
+			if curlReqs != nil {
+				cr = &curlReqs[0]
+			}
+			opts, _, err := reqF.options(hdr, cr, cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+			if err := outs.open(cmd.OutOrStdout()); err != nil {
+				return err
+			}
+			defer outs.close()
+			opts.Sinks = outs.sinks
+
+			// workers needed to keep a rate going at the slowest latency
+			// the SLOs allow
+			budget := time.Second
+			for _, t := range sloTs {
+				if t.Metric != "error_rate" && (t.Op == "<" || t.Op == "<=") {
+					budget = max(budget, time.Duration(t.Limit*float64(time.Millisecond)))
+				}
+			}
+			workers := func(rate float64) int {
+				if concurrency > 0 {
+					return concurrency
+				}
+				return min(max(10, int(math.Ceil(rate*budget.Seconds()))), maxConcurrency)
+			}
+
+			overallStart := time.Now()
+			overall := runner.Report{StatusCounts: map[int]int{}, Start: overallStart}
+			var phases []report.Phase
+			prepares := opts.BodyFunc != nil || len(opts.Hooks) > 0
+			capacity := &report.Capacity{SLOs: slos}
+
+			probe := func(rate float64, stage string) (bool, error) {
+				n := len(phases) + 1
+				if n > 1 && sleepBetween > 0 {
+					time.Sleep(sleepBetween)
+				}
+				c := workers(rate)
+				fmt.Fprintf(cmd.ErrOrStderr(), "Probe %d (%s): rate=%.2frps, concurrency=%d, duration=%s\n", n, stage, rate, c, probeDuration)
+				ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+				rep, err := runner.RunForDurationWithRate(ctx, targetURL, probeDuration, c, opts, rate)
+				cancel()
+				if err != nil {
+					return false, fmt.Errorf("probe %d failed: %w", n, err)
+				}
+				overall.Merge(rep)
+				phases = append(phases, report.Phase{
+					Phase:         n,
+					Concurrency:   c,
+					TargetRPS:     rate,
+					StartMS:       rep.Start.Sub(overallStart).Milliseconds(),
+					DurationMS:    rep.Duration.Milliseconds(),
+					TotalRequests: rep.TotalRequests,
+					RPS:           rep.RPS(),
+					HTTP200:       rep.Succeeded200,
+					Errors:        rep.Errors,
+					Unexpected:    rep.Unexpected,
+					StatusCounts:  statusCountsJSON(rep.StatusCounts),
+					Latency:       summarizeLatency(rep.Latency),
+					Endpoints:     endpointsJSON(rep),
+				})
+				if prepares {
+					phases[n-1].Prepare = summarizeLatency(rep.Prepare)
+				}
+				pr := report.ProbePhase(phases[n-1], stage, sloTs)
+				if pr.Note != "" && pr.Failure() == pr.Note && concurrency == 0 && c == maxConcurrency {
+					// the SLOs held, so the workers were the limit
+					pr.Note += " (raise --max-concurrency)"
+				}
+				capacity.Probes = append(capacity.Probes, pr)
+
+				outcome := "passed"
+				if !pr.Passed {
+					outcome = "FAILED: " + pr.Failure()
+				}
+				fmt.Fprintf(cmd.ErrOrStderr(), "Probe %d: rps=%.2f, http200=%d, errors=%d, p50=%s, p95=%s, p99=%s, %s\n", n, rep.RPS(), rep.Succeeded200, rep.Errors,
+					roundDuration(rep.Latency.Quantile(0.50)), roundDuration(rep.Latency.Quantile(0.95)), roundDuration(rep.Latency.Quantile(0.99)), outcome)
+				return pr.Passed, nil
+			}
+
+			// lo is the highest passing rate, hi the lowest failing one
+			lo, hi := 0.0, 0.0
+			rate := startRPS
+			for lo == 0 || hi == 0 {
+				if len(phases) >= maxProbes {
+					capacity.Stop = "reached --max-probes while exploring"
+					break
+				}
+				passed, err := probe(rate, "explore")
+				if err != nil {
+					return err
+				}
+				if passed {
+					lo = rate
+					if maxRPS > 0 && rate >= maxRPS {
+						capacity.Stop = fmt.Sprintf("reached --max-rps %.2f", maxRPS)
+						break
+					}
+					rate *= growth
+					if maxRPS > 0 {
+						rate = min(rate, maxRPS)
+					}
+					continue
+				}
+				hi = rate
+				if lo == 0 {
+					if rate /= growth; rate < minProbeRPS {
+						capacity.Stop = fmt.Sprintf("no rate down to %.2f rps met the SLOs", hi)
+						break
+					}
+				}
+			}
+			if lo > 0 && hi > 0 {
+				for hi/lo-1 > precision {
+					if len(phases) >= maxProbes {
+						capacity.Stop = "reached --max-probes while bisecting"
+						break
+					}
+					mid := math.Round((lo+hi)/2*100) / 100
+					if mid <= lo || mid >= hi {
+						break
+					}
+					passed, err := probe(mid, "bisect")
+					if err != nil {
+						return err
+					}
+					if passed {
+						lo = mid
+					} else {
+						hi = mid
+					}
+				}
+				if capacity.Stop == "" {
+					capacity.Stop = fmt.Sprintf("bracketed the capacity within %.0f%%", 100*precision)
+				}
+			}
+			if lo > 0 {
+				if _, err := probe(lo, "verify"); err != nil {
+					return err
+				}
+			}
+			capacity.RPS, capacity.FailedRPS = lo, hi
+
+			overall.Duration = time.Since(overallStart)
+			// flush the per-request log and push the final metrics; a
+			// failure is reported once the results are written
+			sinkErr := outs.close()
+
+			res := newResult("capacity", overall, prepares)
+			res.URL, res.Method = targetURL, reqF.method
+			res.Phases = phases
+			capacity.Assess(phases, precision)
+			res.Capacity = capacity
+			res.Config = testConfig(cmd, "slo", "start-rps", "growth", "precision", "probe-duration", "method")
+			if err := outs.write(cmd.OutOrStdout(), res, report.Options{}); err != nil {
+				return err
+			}
+			if sinkErr != nil {
+				return sinkErr
+			}
+			if capacity.RPS == 0 {
+				return &cli.ExitError{Code: 2, Err: errors.New("no probed rate met the SLOs")}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&targetURL, "url", "", "Target URL to test")
+	cmd.Flags().StringArrayVar(&slos, "slo", []string{"p99<500ms", "error_rate<1%"}, "SLO such as 'p99<300ms' or 'error_rate<1%' (repeatable)")
+	cmd.Flags().Float64Var(&startRPS, "start-rps", 10, "Rate of the first probe")
+	cmd.Flags().Float64Var(&maxRPS, "max-rps", 0, "Highest rate to probe (0 = no limit)")
+	cmd.Flags().Float64Var(&growth, "growth", 2, "Rate multiplier between exploring probes")
+	cmd.Flags().Float64Var(&precision, "precision", 0.05, "Stop bisecting when the failing rate is within this fraction above the passing one")
+	cmd.Flags().DurationVar(&probeDuration, "probe-duration", 10*time.Second, "Duration of every probe")
+	cmd.Flags().IntVar(&maxProbes, "max-probes", 15, "Most probes before the verification probe")
+	cmd.Flags().IntVar(&concurrency, "concurrency", 0, "Workers per probe (0 = sized for the rate)")
+	cmd.Flags().IntVar(&maxConcurrency, "max-concurrency", 1000, "Most workers per probe when --concurrency is 0")
+	cmd.Flags().DurationVar(&sleepBetween, "sleep-between", 0, "Sleep duration between probes")
+	cmd.Flags().DurationVar(&timeout, "timeout", 60*time.Second, "Per-probe timeout")
+	reqF.register(cmd)
+	curlF.register(cmd)
+	outF.register(cmd)
+	cmd.Flags().StringVar(&output, "output", "text", "Output format: text|json|markdown|csv|junit|html")
+	cmd.Flags().StringVar(&outFile, "out-file", "", "Write the results to file instead of stdout")
+
+	return cmd
+}
+
+// parseSLOs parses the --slo values. The rate is what capacity searches,
+// so rps is not an SLO.
+func parseSLOs(vals []string) ([]report.Threshold, error) {
+	if len(vals) == 0 {
+		return nil, errors.New("at least one --slo is required")
+	}
+	ts, err := parseThresholds(vals)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range ts {
+		if t.Metric == "rps" {
+			return nil, fmt.Errorf("--slo %s: rps is what capacity searches; use latency or error_rate SLOs", t.Expr)
+		}
+	}
+	return ts, nil
+}
@@ -0,0 +1,147 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/JeanGrijp/stress-test/internal/cli"
+	"github.com/JeanGrijp/stress-test/internal/report"
+	"github.com/spf13/cobra"
+)
+
+// NewCompareCmd diffs two saved results and fails on regressions.
+// Example:
+//
+//	stress-test compare baseline.json candidate.json --tolerance p95=5%
+func NewCompareCmd() *cobra.Command {
+	var (
+		tolerances []string
+		alpha      float64
+		output     string
+		outFile    string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "compare BASELINE CANDIDATE",
+		Short: "Compare two saved JSON results and fail on regressions",
+		Long: `Compare a candidate result with a baseline, both saved with --output json,
+to find out whether a release got slower.
+
+Compared metrics: throughput (rps), latency percentiles (p50, p90, p95, p99)
+and error rate (requests without a response plus 4xx and 5xx responses), for
+the whole test, each ramp phase (matched by number) and each endpoint
+(matched by name).
+
+A metric regresses when it gets worse by more than its tolerance (throughput
+drops, latency or error rate grows) and the change is statistically
+significant. Both a percentile and the error rate are tested with a
+two-proportion z-test: for a percentile, on the share of requests slower
+than the baseline percentile, counted in the saved latency histograms. A
+difference with a p-value at or above --alpha is reported as 'not
+significant'. Throughput, and latency of results saved by versions without
+histograms, are judged on their tolerance alone.
+
+Tolerances (--tolerance METRIC=VALUE, repeatable):
+	10%      relative to the baseline (default for rps and latency)
+	20ms     absolute, for p50, p90, p95, p99 and 'latency' (all four)
+	500      absolute requests/s, for rps
+	0.5pp    absolute percentage points, for error_rate (default 1pp)
+
+Exit status is 0 without regressions, 2 with regressions and 1 on errors.
+
+Flags overview:
+	--tolerance  Repeatable METRIC=VALUE (rps, p50, p90, p95, p99, latency, error_rate)
+	--alpha      Significance level (default 0.05; 1 judges on tolerance alone)
+	--output     text|json (default text)
+	--out-file   Write the output to a file instead of stdout`,
+		Example: `# Fail a pipeline when the release candidate is slower than main
+stress-test run --url https://staging.example.com --requests 20000 --concurrency 50 \
+	--output json --out-file candidate.json
+stress-test compare main.json candidate.json
+
+# Allow 5% on p95, 50ms on p99 and no new errors
+stress-test compare main.json candidate.json \
+	--tolerance p95=5% --tolerance p99=50ms --tolerance error_rate=0
+
+# Machine-readable diff
+stress-test compare main.json candidate.json --output json`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := outputFormat(output, "text", "json")
+			if err != nil {
+				return err
+			}
+			if alpha <= 0 || alpha > 1 {
+				return errors.New("--alpha must be in (0, 1]")
+			}
+			if args[0] == "-" && args[1] == "-" {
+				return errors.New("only one result can be read from stdin")
+			}
+			opts := report.CompareOptions{Tolerances: report.DefaultTolerances(), Alpha: alpha}
+			for _, t := range tolerances {
+				metric, value, ok := strings.Cut(t, "=")
+				if !ok {
+					return fmt.Errorf("invalid --tolerance %q (use METRIC=VALUE, e.g. p95=10%%)", t)
+				}
+				metrics := []string{strings.TrimSpace(metric)}
+				if metrics[0] == "latency" {
+					metrics = []string{"p50", "p90", "p95", "p99"}
+				} else if !slices.Contains(report.CompareMetrics, metrics[0]) {
+					return fmt.Errorf("invalid --tolerance %q: unknown metric %q (use %s or latency)",
+						t, metrics[0], strings.Join(report.CompareMetrics, ", "))
+				}
+				for _, m := range metrics {
+					tol, err := report.ParseTolerance(m, value)
+					if err != nil {
+						return fmt.Errorf("invalid --tolerance %q: %w", t, err)
+					}
+					opts.Tolerances[m] = tol
+				}
+			}
+
+			base, err := readResult(cmd.InOrStdin(), args[0])
+			if err != nil {
+				return err
+			}
+			cand, err := readResult(cmd.InOrStdin(), args[1])
+			if err != nil {
+				return err
+			}
+			c := report.Compare(base, cand, opts)
+			c.Baseline.File, c.Candidate.File = args[0], args[1]
+
+			var buf bytes.Buffer
+			if format == "json" {
+				err = report.WriteJSON(&buf, c)
+			} else {
+				err = report.WriteComparison(&buf, c)
+			}
+			if err != nil {
+				return err
+			}
+			if outFile != "" {
+				err = os.WriteFile(outFile, buf.Bytes(), 0644)
+			} else {
+				_, err = io.Copy(cmd.OutOrStdout(), &buf)
+			}
+			if err != nil {
+				return err
+			}
+			if len(c.Regressions) > 0 {
+				return &cli.ExitError{Code: 2, Err: fmt.Errorf("regression: %s", strings.Join(c.Regressions, ", "))}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&tolerances, "tolerance", nil, "Allowed change as METRIC=VALUE, e.g. p95=10% or p99=50ms (repeatable)")
+	cmd.Flags().Float64Var(&alpha, "alpha", 0.05, "Significance level of the regression tests")
+	cmd.Flags().StringVar(&output, "output", "text", "Output format: text|json")
+	cmd.Flags().StringVar(&outFile, "out-file", "", "Write the output to file instead of stdout")
+	return cmd
+}
@@ -1,15 +1,25 @@
 package commands
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/JeanGrijp/stress-test/internal/auth"
+	"github.com/JeanGrijp/stress-test/internal/cli"
+	"github.com/JeanGrijp/stress-test/internal/form"
+	"github.com/JeanGrijp/stress-test/internal/runner"
 	"github.com/spf13/cobra"
 )
 
@@ -18,29 +28,117 @@ import (
 //
 //	stress-test curl -X POST https://httpbin.org/post -H 'Content-Type: application/json' -d '{"a":1}'
 func NewCurlCmd() *cobra.Command {
-	var showStats bool
-
 	cmd := &cobra.Command{
 		Use:   "curl [curl-args...]",
 		Short: "Execute a curl-style request and print the response",
-		Long: `Execute a single HTTP request using a small subset of curl flags.
+		Long: `Execute a single HTTP request using the common curl flags. Unsupported
+flags are rejected rather than ignored, so a command pasted from the
+browser ("Copy as cURL") either behaves like curl or fails clearly.
 
-Supported flags (subset):
+Request:
   -X, --request METHOD         Set HTTP method
   -H, --header 'K: V'          Add header (repeatable)
-  -d, --data [--data-raw...]   Request body (switches to POST if method not set)
-  -i                           Include response headers in output
+  -d, --data DATA              Request body (switches to POST if method not set,
+                               Content-Type defaults to
+                               application/x-www-form-urlencoded);
+                               @file reads a file (newlines stripped), @- reads stdin
+  --data-binary DATA           Like -d, but @file is sent byte-for-byte
+  --data-raw DATA              Like -d, but '@' has no special meaning
+  --data-urlencode DATA        URL-encode content, name=content, @file or name@file
+  --json DATA                  Like --data-binary, with JSON Content-Type and Accept
+  -G, --get                    Append the -d data to the URL query and use GET
+  -F, --form name=CONTENT      Multipart form part: name=value, name=<file,
+                               name=@file[;type=...][;filename=...]
+  --form-string name=value     Multipart form field taken literally
+  -A, --user-agent UA          Set the User-Agent header
+  -e, --referer URL            Set the Referer header
+  -b, --cookie 'N=V; N2=V2'    Send cookies
+  -u, --user USER:PASSWORD     HTTP basic authentication
+  --oauth2-bearer TOKEN        Bearer token authentication
+  --aws-sigv4 aws:amz:R:S      AWS SigV4 signing for region R and service S,
+                               keys from -u ACCESS:SECRET or $AWS_* variables
+  --hmac-key KEY               HMAC-SHA256 signing (see 'run --help' for the
+                               --hmac-* options)
   -I, --head                   Use HEAD method
-  --url URL                    Explicit URL (or pass URL as the last arg)
+  --url URL                    Explicit URL (or pass URL as an argument)
+
+Connection:
+  -L, --location               Follow redirects
+  --max-redirs N               Redirect limit with -L (default 50)
+  -k, --insecure               Skip TLS certificate verification
+  --compressed                 Request a compressed response and decode it
+                               (gzip and deflate)
+  -m, --max-time SECONDS       Limit the whole transfer (default 60)
+  --connect-timeout SECONDS    Limit connection setup
+  --resolve HOST:PORT:ADDR     Connect to ADDR for HOST:PORT
+  -x, --proxy [scheme://]HOST[:PORT]  Use this proxy
+  --http1.1, --http2           Force HTTP/1.1, or allow HTTP/2 (the default)
+
+Output:
+  -i, --include                Include response headers in output
+  -o, --output FILE            Write the response to FILE instead of stdout
+  -O, --remote-name            Write the response to a file named like the URL
+  -s, --silent                 Do not print errors
+  -S, --show-error             Print errors even with -s
+  -f, --fail                   Exit with code 22 and no output on HTTP errors
+  --fail-with-body             Like -f, but still output the body
+  -v, --verbose                Print the request and response headers to stderr
+  -w, --write-out FORMAT       Print FORMAT after the transfer (@file, @- for
+                               stdin), with %{variable} replaced:
+                                 http_code, response_code, method, url_effective,
+                                 num_redirects, remote_ip, remote_port, content_type,
+                                 time_namelookup, time_connect, time_appconnect,
+                                 time_starttransfer, time_total (seconds),
+                                 size_download, size_upload, size_header (bytes),
+                                 speed_download (bytes/s),
+                                 json (all of the above as a JSON object),
+                                 stderr / stdout (switch the destination)
+  --stats                      Shorthand for a -w summary of time, status and
+                               body size on stderr (useful for piping)
+
+Repeat (stress-test additions, not curl's -n/--netrc and -P/--ftp-port):
+  -n, --repeat N               Send the request N times, discard the responses
+                               and print a latency summary (min/avg/p95/max)
+                               and the status distribution to stderr
+  -P, --parallel P             Keep P requests in flight (default 1)
+
+Short flags can be combined as in curl (-sSL, -XPOST). Exit codes follow curl
+for the common failures: 6 (resolve), 7 (connect), 22 (-f), 28 (timeout),
+47 (too many redirects) and 60 (certificate).
 
-By default, only the response body is written to stdout. Use --stats to
-print timing, status code and body size to stderr (useful for piping).`,
+By default, only the response body is written to stdout.`,
 		Example: `# GET and include headers
 stress-test curl -i https://httpbin.org/get
 
-# POST JSON with headers and show stats to stderr
-stress-test curl -X POST https://httpbin.org/post -H 'Content-Type: application/json' \
-  -d '{"hello":"world"}' --stats`,
+# POST JSON and show stats to stderr
+stress-test curl --json '{"hello":"world"}' https://httpbin.org/post --stats
+
+# Status code and timing breakdown, body discarded
+stress-test curl -s -o /dev/null \
+  -w '%{http_code} dns=%{time_namelookup} connect=%{time_connect} tls=%{time_appconnect} ttfb=%{time_starttransfer} total=%{time_total}\n' \
+  https://httpbin.org/get
+
+# Every measurement as JSON, with the format kept in a file
+stress-test curl -s -o /dev/null -w @format.txt https://httpbin.org/get
+
+# Follow redirects, fail on HTTP errors, quiet unless something breaks
+stress-test curl -sSfL https://httpbin.org/redirect/2
+
+# Query string from data, against a staging address without DNS
+stress-test curl -G -d q=shoes -d page=2 --resolve shop.example.com:443:10.0.0.12 \
+  https://shop.example.com/search
+
+# Upload a binary file as the request body
+stress-test curl --data-binary @image.png -H 'Content-Type: image/png' https://httpbin.org/post
+
+# Multipart upload with a file part
+stress-test curl -F 'title=hello' -F 'file=@photo.jpg;type=image/jpeg' https://httpbin.org/post
+
+# Read the body from stdin
+echo '{"a":1}' | stress-test curl -d @- https://httpbin.org/post
+
+# Quick micro-benchmark: 50 requests, 5 at a time
+stress-test curl -n 50 -P 5 https://httpbin.org/get`,
 		DisableFlagParsing: true, // we'll parse args ourselves
 		Args:               cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -57,138 +155,536 @@ stress-test curl -X POST https://httpbin.org/post -H 'Content-Type: application/
 			if args[0] == "curl" {
 				args = args[1:]
 			}
-			method, target, hdr, body, include, err := parseCurlArgs(args)
+			// --stats is ours, not curl's
+			showStats := slices.Contains(args, "--stats")
+			args = slices.DeleteFunc(args, func(a string) bool { return a == "--stats" })
+
+			cr, err := parseCurlArgs(args, cmd.InOrStdin())
 			if err != nil {
 				return err
 			}
-			if target == "" {
+			if showStats {
+				cr.Client.WriteOut += statsWriteOut
+			}
+			if cr.URL == "" {
 				return errors.New("missing URL in curl arguments")
 			}
-			if _, err := url.ParseRequestURI(target); err != nil {
+			if _, err := url.ParseRequestURI(cr.URL); err != nil {
 				return fmt.Errorf("invalid URL: %w", err)
 			}
-
-			ctx, cancel := context.WithTimeout(cmd.Context(), 60*time.Second)
-			defer cancel()
-
-			req, err := http.NewRequestWithContext(ctx, method, target, strings.NewReader(body))
-			if err != nil {
-				return err
+			if cr.Client.Repeat > 0 || cr.Client.Parallel > 0 {
+				err = benchCurl(cmd.Context(), cmd.ErrOrStderr(), cr)
+			} else {
+				err = sendCurl(cmd, cr)
 			}
-			for k, vals := range hdr {
-				for _, v := range vals {
-					req.Header.Add(k, v)
+			if err != nil && cr.Client.Silent && !cr.Client.ShowError {
+				var exit *cli.ExitError
+				if errors.As(err, &exit) {
+					return &cli.ExitError{Code: exit.Code}
 				}
+				return &cli.ExitError{Code: 1}
 			}
+			return err
+		},
+	}
+	return cmd
+}
 
-			client := &http.Client{}
-			start := time.Now()
-			resp, err := client.Do(req)
-			if err != nil {
-				return err
-			}
-			defer resp.Body.Close()
+// sendCurl sends cr, writes the response like curl and then the
+// --write-out format, even when the transfer failed.
+func sendCurl(cmd *cobra.Command, cr curlRequest) error {
+	maxTime := cr.Client.MaxTime
+	if maxTime <= 0 {
+		maxTime = 60 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(cmd.Context(), maxTime)
+	defer cancel()
 
-			out := cmd.OutOrStdout()
-			if include {
-				// Status line
-				fmt.Fprintf(out, "HTTP/1.1 %d %s\n", resp.StatusCode, http.StatusText(resp.StatusCode))
-				// Headers
-				for k, vals := range resp.Header {
-					for _, v := range vals {
-						fmt.Fprintf(out, "%s: %s\n", k, v)
-					}
-				}
-				fmt.Fprintln(out)
-			}
+	body, contentType, err := cr.payload()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, cr.Method, cr.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, vals := range cr.Headers {
+		for _, v := range vals {
+			req.Header.Add(k, v)
+		}
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for _, h := range cr.hooks() {
+		if err := h.Prepare(req, body); err != nil {
+			return err
+		}
+	}
+	if cr.Client.Compressed && req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", "deflate, gzip")
+	}
 
-			// Copy body to stdout and count bytes written
-			n, copyErr := io.Copy(out, resp.Body)
+	errw := cmd.ErrOrStderr()
+	info := &transferInfo{method: req.Method, url: req.URL.String(), sizeUpload: int64(len(body))}
+	var verbose *curlVerbose
+	if cr.Client.Verbose {
+		verbose = &curlVerbose{w: errw, req: req}
+		ctx = verbose.trace(ctx)
+	}
+	req = req.WithContext(info.trace(ctx))
+	client, err := cr.Client.httpClient(func(next *http.Request) {
+		info.redirects++
+		if verbose != nil {
+			verbose.redirect(next)
+		}
+	})
+	if err != nil {
+		return err
+	}
 
-			// Optionally print stats to stderr to avoid contaminating stdout/pipes
-			if showStats {
-				elapsed := time.Since(start)
-				fmt.Fprintf(cmd.ErrOrStderr(), "\nTime: %s\nStatus: %d\nBody bytes: %d\n", elapsed, resp.StatusCode, n)
+	info.start = time.Now()
+	err = receiveCurl(cmd, cr, client, req, info, verbose)
+	info.total = time.Since(info.start)
+	if cr.Client.WriteOut != "" {
+		if werr := writeOut(cmd.OutOrStdout(), errw, cr.Client.WriteOut, info); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return err
+}
+
+// receiveCurl sends req and writes the response to stdout or the -o file.
+func receiveCurl(cmd *cobra.Command, cr curlRequest, client *http.Client, req *http.Request, info *transferInfo, verbose *curlVerbose) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return curlExitError(err)
+	}
+	defer resp.Body.Close()
+	info.response(resp)
+	if verbose != nil {
+		verbose.response(resp)
+	}
+	if resp.StatusCode >= 400 && cr.Client.Fail && !cr.Client.FailWithBody {
+		return &cli.ExitError{Code: 22, Err: fmt.Errorf("the requested URL returned error: %d", resp.StatusCode)}
+	}
+
+	out := cmd.OutOrStdout()
+	if name, err := cr.Client.outputName(cr.URL); err != nil {
+		return err
+	} else if name != "" && name != "-" {
+		f, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	if cr.Include {
+		fmt.Fprintf(out, "%s %s\n", resp.Proto, resp.Status)
+		for k, vals := range resp.Header {
+			for _, v := range vals {
+				fmt.Fprintf(out, "%s: %s\n", k, v)
 			}
+		}
+		fmt.Fprintln(out)
+	}
 
-			return copyErr
-		},
+	// count the bytes received, before any decoding
+	counted := &countingReader{r: resp.Body}
+	body := io.Reader(counted)
+	if cr.Client.Compressed {
+		if body, err = decodeBody(resp, counted, cmd.ErrOrStderr()); err != nil {
+			return err
+		}
 	}
-	cmd.Flags().BoolVar(&showStats, "stats", false, "Print request time, status and body size to stderr")
-	return cmd
+	_, err = io.Copy(out, body)
+	info.sizeDownload = counted.n
+	if err != nil {
+		return curlExitError(err)
+	}
+	if resp.StatusCode >= 400 && cr.Client.FailWithBody {
+		return &cli.ExitError{Code: 22, Err: fmt.Errorf("the requested URL returned error: %d", resp.StatusCode)}
+	}
+	return nil
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
 }
 
-// parseCurlArgs parses a subset of curl flags: -X/--request, -H/--header, -d/--data*, -i, and URL.
-func parseCurlArgs(args []string) (method string, target string, headers http.Header, body string, include bool, err error) {
-	headers = make(http.Header)
-	method = http.MethodGet
+// curlRequest is the HTTP request described by a set of curl arguments.
+type curlRequest struct {
+	Method  string
+	URL     string
+	Headers http.Header
+	Body    []byte
+	// BodyFunc builds the -F multipart body afresh for every request; Body
+	// is then nil.
+	BodyFunc func() (body []byte, contentType string, err error)
+	Include  bool
+	// Auth applies the credentials (-u/--user, --oauth2-bearer), nil for
+	// none; Signers add the signatures (--hmac-*, --aws-sigv4) after it.
+	Auth    runner.Hook
+	Signers []runner.Hook
+	// Args are the body, form, credential, signing and connection arguments
+	// as given, used to print the request back as a curl command.
+	Args []string
+	// Client holds the connection and output options. run and ramp use
+	// their own HTTP client and only report the ones they ignore.
+	Client curlClient
+}
 
-	var bodies []string
+// curlValueFlags are the curl options that take a value. The single-letter
+// ones may be combined with boolean letters, as in -sSLo FILE or -XPOST.
+var curlValueFlags = flagSet(
+	"-X", "--request", "-H", "--header",
+	"-d", "--data", "--data-ascii", "--data-binary", "--data-raw", "--data-urlencode", "--json",
+	"-F", "--form", "--form-string",
+	"-u", "--user", "--oauth2-bearer",
+	"-A", "--user-agent", "-e", "--referer", "-b", "--cookie",
+	"--url", "-o", "--output", "-w", "--write-out",
+	"-m", "--max-time", "--connect-timeout", "--max-redirs",
+	"--resolve", "-x", "--proxy", "-n", "--repeat", "-P", "--parallel",
+)
+
+func flagSet(names ...string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, n := range names {
+		set[n] = true
+	}
+	return set
+}
+
+// curlBoolShort are the single-letter curl options without a value.
+const curlBoolShort = "sSLkfiIOGv"
+
+// splitShortFlags expands the combined single-letter option at args[i]
+// (-sSL, -XPOST) so that args[i] is a single option, followed by its
+// attached value or by the remaining letters.
+func splitShortFlags(args []string, i int) ([]string, error) {
+	a := args[i]
+	if len(a) <= 2 || a[0] != '-' || a[1] == '-' {
+		return args, nil
+	}
+	flag, rest := a[:2], a[2:]
+	switch {
+	case curlValueFlags[flag]:
+		// attached value
+	case strings.IndexByte(curlBoolShort, a[1]) >= 0:
+		rest = "-" + rest
+	default:
+		return nil, fmt.Errorf("unsupported curl option %q", flag)
+	}
+	out := make([]string, 0, len(args)+1)
+	out = append(out, args[:i]...)
+	out = append(out, flag, rest)
+	return append(out, args[i+1:]...), nil
+}
+
+// parseCurlArgs parses curl arguments: the request options listed in
+// 'stress-test curl --help' plus the connection and output options kept in
+// curlRequest.Client. Unknown options are an error. Data values starting
+// with '@' are read from a file (or stdin for "@-"), except for --data-raw
+// which is always taken literally.
+func parseCurlArgs(args []string, stdin io.Reader) (curlRequest, error) {
+	cr := curlRequest{Headers: make(http.Header), Client: curlClient{MaxRedirects: 50}}
+
+	var (
+		method    string // -X, wins over everything else
+		head, get bool
+		jsonBody  bool
+		bodies    [][]byte
+		dataArgs  []string
+		formParts []form.Part
+		basic     *auth.Basic
+		bearer    *auth.Bearer
+		signF     signFlags
+	)
 
 	for i := 0; i < len(args); i++ {
+		var err error
+		if args, err = splitShortFlags(args, i); err != nil {
+			return curlRequest{}, err
+		}
 		a := args[i]
-		switch a {
-		case "-X", "--request":
+		var val string
+		if curlValueFlags[a] {
 			i++
 			if i >= len(args) {
-				return "", "", nil, "", false, errors.New("-X/--request requires a value")
+				return curlRequest{}, fmt.Errorf("%s requires a value", a)
 			}
-			method = strings.ToUpper(args[i])
+			val = args[i]
+		}
+		switch a {
+		case "-X", "--request":
+			method = strings.ToUpper(val)
 		case "-H", "--header":
-			i++
-			if i >= len(args) {
-				return "", "", nil, "", false, errors.New("-H/--header requires a value")
+			k, v, ok := strings.Cut(val, ":")
+			if !ok {
+				return curlRequest{}, fmt.Errorf("invalid header format: %q", val)
 			}
-			kv := args[i]
-			parts := strings.SplitN(kv, ":", 2)
-			if len(parts) != 2 {
-				return "", "", nil, "", false, fmt.Errorf("invalid header format: %q", kv)
-			}
-			k := strings.TrimSpace(parts[0])
-			v := strings.TrimSpace(parts[1])
+			k, v = strings.TrimSpace(k), strings.TrimSpace(v)
 			if k == "" {
-				return "", "", nil, "", false, fmt.Errorf("invalid header key in: %q", kv)
+				return curlRequest{}, fmt.Errorf("invalid header key in: %q", val)
 			}
-			headers.Add(k, v)
-		case "-d", "--data", "--data-raw", "--data-binary", "--data-ascii":
-			i++
-			if i >= len(args) {
-				return "", "", nil, "", false, errors.New("-d/--data* requires a value")
+			cr.Headers.Add(k, v)
+		case "-d", "--data", "--data-ascii", "--data-binary", "--data-raw", "--data-urlencode", "--json":
+			var data []byte
+			switch a {
+			case "--data-raw":
+				data = []byte(val)
+			case "--data-binary", "--json":
+				data, err = readBodyArg(val, stdin)
+			case "--data-urlencode":
+				var enc string
+				enc, err = urlEncodeDataArg(val, stdin)
+				data = []byte(enc)
+			default:
+				// like curl, -d @file strips carriage returns and newlines
+				data, err = readBodyArg(val, stdin)
+				if err == nil && strings.HasPrefix(val, "@") {
+					data = stripNewlines(data)
+				}
+			}
+			if err != nil {
+				return curlRequest{}, fmt.Errorf("%s: %w", a, err)
 			}
-			bodies = append(bodies, args[i])
-			if method == http.MethodGet {
-				method = http.MethodPost // curl commonly defaults to POST when -d is used
+			bodies = append(bodies, data)
+			jsonBody = jsonBody || a == "--json"
+			dataArgs = append(dataArgs, a, val)
+		case "-F", "--form", "--form-string":
+			var p form.Part
+			if a == "--form-string" {
+				name, value, ok := strings.Cut(val, "=")
+				if !ok || name == "" {
+					return curlRequest{}, fmt.Errorf("invalid --form-string (use 'name=value'): %q", val)
+				}
+				p = form.Part{Name: name, Value: value}
+			} else if p, err = form.ParsePart(val); err != nil {
+				return curlRequest{}, err
+			}
+			formParts = append(formParts, p)
+			cr.Args = append(cr.Args, a, val)
+		case "-u", "--user":
+			user, pass, ok := strings.Cut(val, ":")
+			if !ok {
+				return curlRequest{}, errors.New("-u/--user requires 'user:password' (password prompts are not supported)")
 			}
+			basic = &auth.Basic{Username: user, Password: pass}
+			cr.Args = append(cr.Args, "-u", val)
+		case "--oauth2-bearer":
+			bearer = &auth.Bearer{Token: val}
+			cr.Args = append(cr.Args, a, val)
+		case "--basic":
+			// basic is the only scheme supported by -u
 		case "-A", "--user-agent":
-			i++
-			if i >= len(args) {
-				return "", "", nil, "", false, errors.New("-A/--user-agent requires a value")
+			cr.Headers.Set("User-Agent", val)
+		case "-e", "--referer":
+			// ";auto" asks curl to update the referer on redirects
+			if ref := strings.TrimSuffix(val, ";auto"); ref != "" {
+				cr.Headers.Set("Referer", ref)
+			}
+		case "-b", "--cookie":
+			if !strings.Contains(val, "=") {
+				return curlRequest{}, errors.New("-b/--cookie takes 'name=value' pairs (cookie files are not supported)")
+			}
+			if c := cr.Headers.Get("Cookie"); c != "" {
+				cr.Headers.Set("Cookie", c+"; "+val)
+			} else {
+				cr.Headers.Set("Cookie", val)
 			}
-			headers.Set("User-Agent", args[i])
 		case "-I", "--head":
-			method = http.MethodHead
-		case "-i":
-			include = true
+			head = true
+		case "-G", "--get":
+			get = true
+		case "-i", "--include":
+			cr.Include = true
+		case "-w", "--write-out":
+			format, err := readBodyArg(val, stdin)
+			if err != nil {
+				return curlRequest{}, fmt.Errorf("%s: %w", a, err)
+			}
+			cr.Client.WriteOut = string(format)
 		case "--url":
-			i++
-			if i >= len(args) {
-				return "", "", nil, "", false, errors.New("--url requires a value")
+			if cr.URL != "" {
+				return curlRequest{}, errors.New("only one URL is supported")
 			}
-			target = args[i]
+			cr.URL = withScheme(val)
 		default:
-			// If it looks like a URL and target not yet set, treat as URL.
-			if strings.HasPrefix(a, "http://") || strings.HasPrefix(a, "https://") {
-				if target == "" {
-					target = a
-					continue
+			ok, err := cr.Client.parseFlag(a, val)
+			if err != nil {
+				return curlRequest{}, err
+			}
+			if ok {
+				if arg := cr.Client.arg(a); arg != "" {
+					cr.Args = append(cr.Args, arg)
+					if val != "" {
+						cr.Args = append(cr.Args, val)
+					}
 				}
+				continue
+			}
+			next, ok, err := signF.parseCurlFlag(args, i)
+			if err != nil {
+				return curlRequest{}, err
+			}
+			if ok {
+				cr.Args = append(cr.Args, args[i:next+1]...)
+				i = next
+				continue
+			}
+			if strings.HasPrefix(a, "-") && a != "-" {
+				return curlRequest{}, fmt.Errorf("unsupported curl option %q", a)
+			}
+			if cr.URL != "" {
+				return curlRequest{}, fmt.Errorf("only one URL is supported, got %q and %q", cr.URL, a)
 			}
-			// ignore unrecognized flags for now
+			cr.URL = withScheme(a)
 		}
 	}
 
-	if len(bodies) > 0 {
-		body = strings.Join(bodies, "&")
+	if len(formParts) > 0 {
+		if len(bodies) > 0 {
+			return curlRequest{}, errors.New("-F/--form cannot be combined with -d/--data")
+		}
+		if get {
+			return curlRequest{}, errors.New("-G/--get cannot be combined with -F/--form")
+		}
+		f := &form.Form{Parts: formParts}
+		if err := f.Load(stdin); err != nil {
+			return curlRequest{}, err
+		}
+		cr.BodyFunc = multipartBody(f, cr.Headers.Get("Content-Type"))
+	}
+	switch {
+	case get:
+		// -G moves the data into the query string
+		if len(bodies) > 0 && cr.URL != "" {
+			sep := "?"
+			if strings.Contains(cr.URL, "?") {
+				sep = "&"
+			}
+			cr.URL += sep + string(bytes.Join(bodies, []byte("&")))
+		}
+	case jsonBody:
+		// curl concatenates --json data as is
+		cr.Body = bytes.Join(bodies, nil)
+		cr.Args = append(dataArgs, cr.Args...)
+		if cr.Headers.Get("Content-Type") == "" {
+			cr.Headers.Set("Content-Type", "application/json")
+		}
+		if cr.Headers.Get("Accept") == "" {
+			cr.Headers.Set("Accept", "application/json")
+		}
+	case len(bodies) > 0:
+		cr.Body = bytes.Join(bodies, []byte("&"))
+		cr.Args = append(dataArgs, cr.Args...)
+		if cr.Headers.Get("Content-Type") == "" {
+			cr.Headers.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	switch {
+	case method != "":
+		cr.Method = method
+	case head:
+		cr.Method = http.MethodHead
+	case get:
+		cr.Method = http.MethodGet
+	case len(bodies) > 0 || len(formParts) > 0:
+		cr.Method = http.MethodPost
+	default:
+		cr.Method = http.MethodGet
+	}
+
+	// with --aws-sigv4, curl takes the access and secret keys from -u
+	if signF.awsSigV4 != "" && basic != nil {
+		signF.awsAccessKey, signF.awsSecretKey = basic.Username, basic.Password
+		basic = nil
+	}
+	// like curl, credentials from -u win over --oauth2-bearer but not over an
+	// explicit Authorization header
+	if cr.Headers.Get("Authorization") == "" {
+		switch {
+		case basic != nil:
+			cr.Auth = basic
+		case bearer != nil:
+			cr.Auth = bearer
+		}
+	}
+	signers, err := signF.hooks(stdin)
+	if err != nil {
+		return curlRequest{}, err
+	}
+	cr.Signers = signers
+	return cr, nil
+}
+
+// payload returns the body of one request of cr and the Content-Type it
+// needs, "" to keep the headers.
+func (cr *curlRequest) payload() ([]byte, string, error) {
+	if cr.BodyFunc == nil {
+		return cr.Body, "", nil
+	}
+	return cr.BodyFunc()
+}
+
+// multipartBody returns the BodyFunc of a -F form: every request gets a fresh
+// boundary and fresh @random content. Like curl, a Content-Type given with
+// -H keeps its media type and gets the boundary appended.
+func multipartBody(f *form.Form, contentType string) func() ([]byte, string, error) {
+	return func() ([]byte, string, error) {
+		body, ct, err := f.Multipart()
+		if err != nil || contentType == "" {
+			return body, ct, err
+		}
+		_, params, _ := mime.ParseMediaType(ct)
+		return body, contentType + "; boundary=" + params["boundary"], nil
+	}
+}
+
+// hooks returns the credentials and signers of cr, in that order.
+func (cr *curlRequest) hooks() []runner.Hook {
+	return hookPipeline{}.hooks(cr)
+}
+
+// withScheme adds http:// to a URL without a scheme, like curl.
+func withScheme(u string) string {
+	if strings.Contains(u, "://") {
+		return u
+	}
+	return "http://" + u
+}
+
+// outputName returns the file named by -o, or derived from the URL by -O.
+func (c curlClient) outputName(target string) (string, error) {
+	if !c.RemoteName {
+		return c.Output, nil
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+	name := path.Base(u.Path)
+	if name == "/" || name == "." || name == "" {
+		return "", errors.New("-O/--remote-name: the URL has no file name")
+	}
+	return name, nil
+}
+
+// parseSeconds parses a curl time value in (fractional) seconds.
+func parseSeconds(flag, v string) (time.Duration, error) {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid %s %q (use seconds, e.g. 2.5)", flag, v)
 	}
-	return
+	return time.Duration(f * float64(time.Second)), nil
 }
@@ -0,0 +1,129 @@
+package commands
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseCurlArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		method  string
+		url     string
+		headers http.Header // expected values of these headers only
+		body    string
+		form    bool // a -F body built per request
+		auth    bool
+		follow  bool
+		err     string
+	}{
+		{
+			name:   "bare URL gets a scheme",
+			args:   []string{"example.com/a"},
+			method: "GET", url: "http://example.com/a",
+		},
+		{
+			name:   "header",
+			args:   []string{"-H", "X-A:  1 ", "https://example.com"},
+			method: "GET", url: "https://example.com",
+			headers: http.Header{"X-A": {"1"}},
+		},
+		{
+			name:   "data switches to POST and joins with &",
+			args:   []string{"-d", "a=1", "--data-raw", "@b", "https://example.com"},
+			method: "POST", url: "https://example.com", body: "a=1&@b",
+			headers: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+		},
+		{
+			name:   "json",
+			args:   []string{"--json", `{"a":1}`, "https://example.com"},
+			method: "POST", url: "https://example.com", body: `{"a":1}`,
+			headers: http.Header{"Content-Type": {"application/json"}, "Accept": {"application/json"}},
+		},
+		{
+			name:   "user content type wins",
+			args:   []string{"-H", "Content-Type: text/plain", "-d", "x", "https://example.com"},
+			method: "POST", url: "https://example.com", body: "x",
+			headers: http.Header{"Content-Type": {"text/plain"}},
+		},
+		{
+			name:   "get moves data to the query",
+			args:   []string{"-G", "-d", "q=1", "-d", "r=2", "https://example.com/s?x=0"},
+			method: "GET", url: "https://example.com/s?x=0&q=1&r=2",
+		},
+		{
+			name:   "combined short flags with attached value",
+			args:   []string{"-sSLXPUT", "https://example.com"},
+			method: "PUT", url: "https://example.com", follow: true,
+		},
+		{
+			name:   "request wins over data",
+			args:   []string{"-X", "patch", "-d", "x", "https://example.com"},
+			method: "PATCH", url: "https://example.com", body: "x",
+		},
+		{
+			name:   "head",
+			args:   []string{"-I", "https://example.com"},
+			method: "HEAD", url: "https://example.com",
+		},
+		{
+			name:   "form",
+			args:   []string{"-F", "a=1", "--form-string", "b=@x", "https://example.com"},
+			method: "POST", url: "https://example.com", form: true,
+		},
+		{
+			name:   "basic auth",
+			args:   []string{"-u", "user:pass", "https://example.com"},
+			method: "GET", url: "https://example.com", auth: true,
+		},
+		{
+			name:   "explicit authorization header wins over -u",
+			args:   []string{"-u", "user:pass", "-H", "Authorization: Bearer t", "https://example.com"},
+			method: "GET", url: "https://example.com",
+		},
+		{name: "form with data", args: []string{"-F", "a=1", "-d", "b", "https://example.com"}, err: "cannot be combined with -d"},
+		{name: "get with form", args: []string{"-G", "-F", "a=1", "https://example.com"}, err: "-G/--get cannot be combined"},
+		{name: "unknown option", args: []string{"--no-such-flag", "https://example.com"}, err: "unsupported curl option"},
+		{name: "unknown short option", args: []string{"-sQ", "https://example.com"}, err: "unsupported curl option"},
+		{name: "two URLs", args: []string{"https://a.example", "https://b.example"}, err: "only one URL"},
+		{name: "missing value", args: []string{"https://example.com", "-H"}, err: "requires a value"},
+		{name: "bad header", args: []string{"-H", "nocolon", "https://example.com"}, err: "invalid header format"},
+		{name: "user without password", args: []string{"-u", "user", "https://example.com"}, err: "user:password"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cr, err := parseCurlArgs(tt.args, strings.NewReader(""))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want it to contain %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cr.Method != tt.method || cr.URL != tt.url {
+				t.Errorf("request = %s %s, want %s %s", cr.Method, cr.URL, tt.method, tt.url)
+			}
+			for k, want := range tt.headers {
+				if got := cr.Headers.Values(k); strings.Join(got, "\n") != strings.Join(want, "\n") {
+					t.Errorf("header %s = %q, want %q", k, got, want)
+				}
+			}
+			if string(cr.Body) != tt.body {
+				t.Errorf("body = %q, want %q", cr.Body, tt.body)
+			}
+			if (cr.BodyFunc != nil) != tt.form {
+				t.Errorf("form body = %v, want %v", cr.BodyFunc != nil, tt.form)
+			}
+			if (cr.Auth != nil) != tt.auth {
+				t.Errorf("auth = %v, want %v", cr.Auth != nil, tt.auth)
+			}
+			if cr.Client.Follow != tt.follow {
+				t.Errorf("follow = %v, want %v", cr.Client.Follow, tt.follow)
+			}
+		})
+	}
+}
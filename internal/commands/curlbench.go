@@ -0,0 +1,178 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JeanGrijp/stress-test/internal/cli"
+	"github.com/JeanGrijp/stress-test/internal/runner"
+)
+
+// curlBench holds the results of a -n/--repeat run.
+type curlBench struct {
+	mu       sync.Mutex
+	latency  runner.Histogram
+	statuses map[int]int
+	errors   int
+	firstErr error
+}
+
+// benchCurl sends cr Repeat times with Parallel workers through one curl
+// client, discarding the bodies, and prints a compact summary on errw.
+// Each request gets its own -m/--max-time. It fails like curl when any
+// request failed: with the code of the first transfer error, or 22 for
+// HTTP errors with -f.
+func benchCurl(ctx context.Context, errw io.Writer, cr curlRequest) error {
+	c := cr.Client
+	switch {
+	case cr.Include, c.Output != "", c.RemoteName, c.Verbose, c.WriteOut != "":
+		return errors.New("-n/--repeat and -P/--parallel discard the responses; -i, -o, -O, -v, -w and --stats cannot be used with them")
+	}
+	total, workers := max(c.Repeat, 1), max(c.Parallel, 1)
+	workers = min(workers, total)
+	maxTime := c.MaxTime
+	if maxTime <= 0 {
+		maxTime = 60 * time.Second
+	}
+	client, err := c.httpClient(nil)
+	if err != nil {
+		return err
+	}
+
+	b := &curlBench{statuses: make(map[int]int)}
+	jobs := make(chan struct{})
+	var wg sync.WaitGroup
+	start := time.Now()
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				b.do(ctx, client, cr, maxTime)
+			}
+		}()
+	}
+send:
+	for range total {
+		select {
+		case jobs <- struct{}{}:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	b.write(errw, workers, time.Since(start))
+
+	switch {
+	case b.firstErr != nil:
+		return curlExitError(b.firstErr)
+	case c.Fail || c.FailWithBody:
+		failed := 0
+		for code, n := range b.statuses {
+			if code >= 400 {
+				failed += n
+			}
+		}
+		if failed > 0 {
+			return &cli.ExitError{Code: 22, Err: fmt.Errorf("%d requests returned an HTTP error", failed)}
+		}
+	}
+	return ctx.Err()
+}
+
+// do sends one request and records its outcome. Latency runs from send
+// until the body is fully read, as in run.
+func (b *curlBench) do(ctx context.Context, client *http.Client, cr curlRequest, maxTime time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, maxTime)
+	defer cancel()
+	code, latency, err := sendOnce(ctx, client, cr)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err != nil {
+		b.errors++
+		if b.firstErr == nil {
+			b.firstErr = err
+		}
+		return
+	}
+	b.statuses[code]++
+	b.latency.Record(latency)
+}
+
+func sendOnce(ctx context.Context, client *http.Client, cr curlRequest) (int, time.Duration, error) {
+	body, contentType, err := cr.payload()
+	if err != nil {
+		return 0, 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, cr.Method, cr.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, 0, err
+	}
+	for k, vals := range cr.Headers {
+		for _, v := range vals {
+			req.Header.Add(k, v)
+		}
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	// hooks run per request so that signatures and timestamps stay fresh
+	for _, h := range cr.hooks() {
+		if err := h.Prepare(req, body); err != nil {
+			return 0, 0, err
+		}
+	}
+	if cr.Client.Compressed && req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", "deflate, gzip")
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	_, err = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	return resp.StatusCode, time.Since(start), err
+}
+
+// write prints the summary, e.g.
+//
+//	50 requests, 5 parallel, 1.2s, 41.67 req/s
+//	Status: 200 x48, 503 x2
+//	Latency: min=8.1ms avg=23.4ms p95=61.02ms max=80.3ms
+func (b *curlBench) write(w io.Writer, workers int, elapsed time.Duration) {
+	sent := int(b.latency.Count) + b.errors
+	rps := 0.0
+	if elapsed > 0 {
+		rps = float64(sent) / elapsed.Seconds()
+	}
+	fmt.Fprintf(w, "%d requests, %d parallel, %s, %.2f req/s\n", sent, workers, roundDuration(elapsed), rps)
+	if len(b.statuses) > 0 {
+		codes := make([]int, 0, len(b.statuses))
+		for code := range b.statuses {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		parts := make([]string, len(codes))
+		for i, code := range codes {
+			parts[i] = fmt.Sprintf("%d x%d", code, b.statuses[code])
+		}
+		fmt.Fprintf(w, "Status: %s\n", strings.Join(parts, ", "))
+	}
+	if b.errors > 0 {
+		fmt.Fprintf(w, "Errors: %d (first: %v)\n", b.errors, b.firstErr)
+	}
+	if h := b.latency; h.Count > 0 {
+		fmt.Fprintf(w, "Latency: min=%s avg=%s p95=%s max=%s\n",
+			roundDuration(h.Min), roundDuration(h.Mean()), roundDuration(h.Quantile(0.95)), roundDuration(h.Max))
+	}
+}
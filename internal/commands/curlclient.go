@@ -0,0 +1,357 @@
+package commands
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/JeanGrijp/stress-test/internal/cli"
+)
+
+// curlClient holds the curl options that shape the connection and the
+// output rather than the request itself.
+type curlClient struct {
+	Follow         bool
+	MaxRedirects   int
+	Insecure       bool
+	Compressed     bool
+	MaxTime        time.Duration
+	ConnectTimeout time.Duration
+	// Resolve maps "host:port" to the address to connect to instead.
+	Resolve     map[string]string
+	HTTPVersion string // "1.1" or "2", empty for the default
+	Proxy       string
+
+	Output       string
+	RemoteName   bool
+	Silent       bool
+	ShowError    bool
+	Fail         bool
+	FailWithBody bool
+	Verbose      bool
+	WriteOut     string
+
+	// Repeat and Parallel are stress-test additions: send the request
+	// Repeat times, Parallel at a time, and print a latency summary.
+	Repeat   int
+	Parallel int
+}
+
+// errTooManyRedirects reports that -L hit --max-redirs.
+var errTooManyRedirects = errors.New("maximum redirects followed")
+
+// parseFlag applies the connection or output option a with value val. It
+// reports whether a was one of them.
+func (c *curlClient) parseFlag(a, val string) (bool, error) {
+	var err error
+	switch a {
+	case "-L", "--location":
+		c.Follow = true
+	case "--max-redirs":
+		if c.MaxRedirects, err = strconv.Atoi(val); err != nil || c.MaxRedirects < -1 {
+			return true, fmt.Errorf("invalid --max-redirs %q", val)
+		}
+	case "-k", "--insecure":
+		c.Insecure = true
+	case "--compressed":
+		c.Compressed = true
+	case "-m", "--max-time":
+		c.MaxTime, err = parseSeconds(a, val)
+	case "--connect-timeout":
+		c.ConnectTimeout, err = parseSeconds(a, val)
+	case "--resolve":
+		err = c.addResolve(val)
+	case "--http1.1":
+		c.HTTPVersion = "1.1"
+	case "--http2":
+		c.HTTPVersion = "2"
+	case "-x", "--proxy":
+		c.Proxy = val
+	case "-o", "--output":
+		c.Output = val
+	case "-O", "--remote-name":
+		c.RemoteName = true
+	case "-s", "--silent":
+		c.Silent = true
+	case "-S", "--show-error":
+		c.ShowError = true
+	case "-f", "--fail":
+		c.Fail = true
+	case "--fail-with-body":
+		c.FailWithBody = true
+	case "-v", "--verbose":
+		c.Verbose = true
+	case "-n", "--repeat":
+		if c.Repeat, err = strconv.Atoi(val); err != nil || c.Repeat < 1 {
+			return true, fmt.Errorf("invalid %s %q (must be > 0)", a, val)
+		}
+	case "-P", "--parallel":
+		if c.Parallel, err = strconv.Atoi(val); err != nil || c.Parallel < 1 {
+			return true, fmt.Errorf("invalid %s %q (must be > 0)", a, val)
+		}
+	default:
+		return false, nil
+	}
+	return true, err
+}
+
+// arg returns the argument to keep when the request is printed as a curl
+// command: connection options are kept, output options are not.
+func (c *curlClient) arg(a string) string {
+	switch a {
+	case "-L", "--location", "--max-redirs", "-k", "--insecure", "--compressed", "-m", "--max-time",
+		"--connect-timeout", "--resolve", "--http1.1", "--http2", "-x", "--proxy":
+		return a
+	}
+	return ""
+}
+
+// addResolve parses a --resolve entry "[+]HOST:PORT:ADDR[,ADDR...]"; the
+// first address is used.
+func (c *curlClient) addResolve(v string) error {
+	spec := strings.TrimPrefix(v, "+")
+	host, rest, ok := strings.Cut(spec, ":")
+	port, addrs, ok2 := strings.Cut(rest, ":")
+	if !ok || !ok2 || host == "" || port == "" || addrs == "" || strings.HasPrefix(v, "-") {
+		return fmt.Errorf("invalid --resolve %q (use HOST:PORT:ADDRESS)", v)
+	}
+	addr, _, _ := strings.Cut(addrs, ",")
+	addr = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	if c.Resolve == nil {
+		c.Resolve = make(map[string]string)
+	}
+	c.Resolve[net.JoinHostPort(host, port)] = net.JoinHostPort(addr, port)
+	return nil
+}
+
+// loadIgnored lists the options set in c that run and ramp cannot honor,
+// since they send requests with their own HTTP client.
+func (c *curlClient) loadIgnored() []string {
+	var opts []string
+	if c.Insecure {
+		opts = append(opts, "-k")
+	}
+	if len(c.Resolve) > 0 {
+		opts = append(opts, "--resolve")
+	}
+	if c.Proxy != "" {
+		opts = append(opts, "-x")
+	}
+	if c.HTTPVersion != "" {
+		opts = append(opts, "--http"+c.HTTPVersion)
+	}
+	if c.MaxTime > 0 {
+		opts = append(opts, "-m")
+	}
+	if c.ConnectTimeout > 0 {
+		opts = append(opts, "--connect-timeout")
+	}
+	if c.Repeat > 0 || c.Parallel > 0 {
+		opts = append(opts, "-n/-P")
+	}
+	return opts
+}
+
+// httpClient returns an HTTP client configured like curl with these
+// options. Responses are never decompressed by the transport; see
+// decodeBody. onRedirect, when set, is called for every redirect followed.
+func (c *curlClient) httpClient(onRedirect func(next *http.Request)) (*http.Client, error) {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.DisableCompression = true
+	if c.Insecure {
+		tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if c.ConnectTimeout > 0 {
+		dialer.Timeout = c.ConnectTimeout
+		tr.TLSHandshakeTimeout = c.ConnectTimeout
+	}
+	resolve := c.Resolve
+	tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if to, ok := resolve[addr]; ok {
+			addr = to
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+	if c.Proxy != "" {
+		u, err := url.Parse(withScheme(c.Proxy))
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid -x/--proxy %q", c.Proxy)
+		}
+		tr.Proxy = http.ProxyURL(u)
+	}
+	switch c.HTTPVersion {
+	case "1.1":
+		p := new(http.Protocols)
+		p.SetHTTP1(true)
+		tr.Protocols = p
+	case "2":
+		tr.ForceAttemptHTTP2 = true
+	}
+
+	follow, limit := c.Follow, c.MaxRedirects
+	return &http.Client{
+		Transport: tr,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if !follow {
+				return http.ErrUseLastResponse
+			}
+			if limit >= 0 && len(via) > limit {
+				return fmt.Errorf("%w (%d)", errTooManyRedirects, limit)
+			}
+			if onRedirect != nil {
+				onRedirect(req)
+			}
+			return nil
+		},
+	}, nil
+}
+
+// curlExitError maps common transfer failures to curl's exit codes.
+func curlExitError(err error) error {
+	var (
+		netErr  net.Error
+		dnsErr  *net.DNSError
+		opErr   *net.OpError
+		certErr *tls.CertificateVerificationError
+		code    int
+	)
+	switch {
+	case errors.Is(err, errTooManyRedirects):
+		code = 47
+	case errors.As(err, &certErr):
+		code = 60
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		code = 28
+	case errors.As(err, &dnsErr):
+		code = 6
+	case errors.Is(err, syscall.ECONNREFUSED), errors.As(err, &opErr) && opErr.Op == "dial":
+		code = 7
+	default:
+		return err
+	}
+	return &cli.ExitError{Code: code, Err: err}
+}
+
+// decodeBody returns body decoded according to the Content-Encoding of
+// resp, as curl does with --compressed. Unknown encodings are returned as
+// received, with a warning on w.
+func decodeBody(resp *http.Response, body io.Reader, w io.Writer) (io.Reader, error) {
+	enc := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	switch enc {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(body)
+		if errors.Is(err, io.EOF) {
+			return strings.NewReader(""), nil // no body, e.g. HEAD
+		}
+		return zr, err
+	case "deflate":
+		// servers send zlib-wrapped or raw deflate; try zlib first
+		data, err := io.ReadAll(body)
+		if err != nil || len(data) == 0 {
+			return bytes.NewReader(data), err
+		}
+		if zr, err := zlib.NewReader(bytes.NewReader(data)); err == nil {
+			return zr, nil
+		}
+		return flate.NewReader(bytes.NewReader(data)), nil
+	default:
+		fmt.Fprintf(w, "warning: cannot decode Content-Encoding %q; writing the body as received\n", enc)
+		return body, nil
+	}
+}
+
+// curlVerbose prints a -v style trace of the exchange: connection details
+// (*), request headers as sent (>) and response headers (<).
+type curlVerbose struct {
+	w       io.Writer
+	req     *http.Request
+	proto   string
+	started bool
+}
+
+// redirect switches the request line to the next hop.
+func (v *curlVerbose) redirect(req *http.Request) {
+	v.req = req
+	v.started = false
+}
+
+// trace returns ctx with an httptrace hook writing the trace.
+func (v *curlVerbose) trace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		ConnectDone: func(network, addr string, err error) {
+			if err == nil {
+				fmt.Fprintf(v.w, "* Connected to %s (%s)\n", v.req.URL.Host, addr)
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				fmt.Fprintf(v.w, "* Re-using connection to %s\n", info.Conn.RemoteAddr())
+			}
+		},
+		TLSHandshakeDone: func(cs tls.ConnectionState, err error) {
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(v.w, "* TLS connection using %s / %s\n", tls.VersionName(cs.Version), tls.CipherSuiteName(cs.CipherSuite))
+			if cs.NegotiatedProtocol != "" {
+				fmt.Fprintf(v.w, "* ALPN: server accepted %s\n", cs.NegotiatedProtocol)
+			}
+			if cs.NegotiatedProtocol == "h2" {
+				v.proto = "HTTP/2"
+			}
+		},
+		WroteHeaderField: func(key string, values []string) {
+			if !v.started {
+				v.started = true
+				proto := v.proto
+				if proto == "" {
+					proto = "HTTP/1.1"
+				}
+				fmt.Fprintf(v.w, "> %s %s %s\n", v.req.Method, v.req.URL.RequestURI(), proto)
+			}
+			if strings.HasPrefix(key, ":") {
+				return // HTTP/2 pseudo-headers, shown in the request line
+			}
+			for _, val := range values {
+				fmt.Fprintf(v.w, "> %s: %s\n", key, val)
+			}
+		},
+		WroteHeaders: func() {
+			fmt.Fprintln(v.w, ">")
+		},
+	})
+}
+
+// response writes the status line and headers of resp.
+func (v *curlVerbose) response(resp *http.Response) {
+	fmt.Fprintf(v.w, "< %s %s\n", resp.Proto, resp.Status)
+	keys := make([]string, 0, len(resp.Header))
+	for k := range resp.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, val := range resp.Header[k] {
+			fmt.Fprintf(v.w, "< %s: %s\n", k, val)
+		}
+	}
+	fmt.Fprintln(v.w, "<")
+}
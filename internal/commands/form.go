@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/JeanGrijp/stress-test/internal/form"
+)
+
+// formArg is one --form or --form-string value; literal marks --form-string.
+type formArg struct {
+	spec    string
+	literal bool
+}
+
+// formValue is the pflag.Value of --form (or --form-string when literal).
+// Both flags append to the same slice so parts keep their command-line
+// order, as with curl.
+type formValue struct {
+	args    *[]formArg
+	literal bool
+}
+
+func (v *formValue) Set(s string) error {
+	*v.args = append(*v.args, formArg{spec: s, literal: v.literal})
+	return nil
+}
+
+func (v *formValue) Type() string { return "stringArray" }
+
+func (v *formValue) String() string {
+	vals := v.GetSlice()
+	if len(vals) == 0 {
+		return "" // no "(default [])" in the help
+	}
+	return "[" + strings.Join(vals, ",") + "]"
+}
+
+// GetSlice returns the values of this flag only, for testConfig.
+func (v *formValue) GetSlice() []string {
+	var out []string
+	for _, a := range *v.args {
+		if a.literal == v.literal {
+			out = append(out, a.spec)
+		}
+	}
+	return out
+}
+
+func (v *formValue) Append(s string) error { return v.Set(s) }
+
+func (v *formValue) Replace(vals []string) error {
+	kept := (*v.args)[:0]
+	for _, a := range *v.args {
+		if a.literal != v.literal {
+			kept = append(kept, a)
+		}
+	}
+	*v.args = kept
+	for _, s := range vals {
+		if err := v.Set(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildFormBody turns the --form/--form-string flags into a per-request body
+// builder for runner.Options.BodyFunc. It returns nil when no form is set.
+// Parts keep their command-line order. Files are read once; multipart bodies
+// get a fresh boundary (and fresh random content for @random parts) on every
+// request.
+func buildFormBody(args []formArg, encoding string, stdin io.Reader) (func() ([]byte, string, error), error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	f := &form.Form{}
+	for _, a := range args {
+		if !a.literal {
+			p, err := form.ParsePart(a.spec)
+			if err != nil {
+				return nil, err
+			}
+			f.Parts = append(f.Parts, p)
+			continue
+		}
+		name, value, ok := strings.Cut(a.spec, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid --form-string (use 'name=value'): %q", a.spec)
+		}
+		f.Add(strings.TrimSpace(name), value)
+	}
+	if err := f.Load(stdin); err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "multipart":
+		return f.Multipart, nil
+	case "urlencoded":
+		return f.URLEncoded, nil
+	default:
+		return nil, fmt.Errorf("unsupported --form-encoding: %s (use multipart|urlencoded)", encoding)
+	}
+}
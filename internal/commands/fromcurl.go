@@ -0,0 +1,259 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/JeanGrijp/stress-test/internal/runner"
+	"github.com/JeanGrijp/stress-test/internal/tmpl"
+	"github.com/spf13/cobra"
+)
+
+// curlFlags holds the flags that convert between curl commands and the
+// request flags of run and ramp.
+type curlFlags struct {
+	command string
+	print   bool
+}
+
+func (f *curlFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.command, "from-curl", "", "Take the request from a curl command line, or @file/@- with one or more curl commands")
+	cmd.Flags().BoolVar(&f.print, "print-curl", false, "Print the configured request as a curl command and exit")
+}
+
+// conflicts returns an error when --from-curl is combined with flags that
+// also define the request.
+func (f *curlFlags) conflicts(cmd *cobra.Command) error {
+	if f.command == "" {
+		return nil
+	}
+	for _, name := range []string{"url", "har", "method", "body", "body-file", "form", "form-string"} {
+		if fl := cmd.Flags().Lookup(name); fl != nil && fl.Changed {
+			return fmt.Errorf("--from-curl cannot be combined with --%s", name)
+		}
+	}
+	return nil
+}
+
+// load parses the --from-curl commands. A value starting with '@' names a
+// file (or stdin for "@-") holding one or more commands, separated by
+// newlines or ';' as in a shell script. Connection options that load tests
+// cannot honor are reported on errw.
+func (f *curlFlags) load(stdin io.Reader, errw io.Writer) ([]curlRequest, error) {
+	text := f.command
+	if strings.HasPrefix(text, "@") {
+		data, err := readBodyArg(text, stdin)
+		if err != nil {
+			return nil, fmt.Errorf("--from-curl: %w", err)
+		}
+		text = string(data)
+	}
+	cmds, err := splitCommands(text)
+	if err != nil {
+		return nil, fmt.Errorf("--from-curl: %w", err)
+	}
+	if len(cmds) == 0 {
+		return nil, errors.New("--from-curl: no curl command found")
+	}
+	reqs := make([]curlRequest, 0, len(cmds))
+	for i, words := range cmds {
+		if words[0] == "curl" {
+			words = words[1:]
+		} else if len(cmds) > 1 {
+			return nil, fmt.Errorf("--from-curl: command %d is not a curl command: %q", i+1, words[0])
+		}
+		cr, err := parseCurlArgs(words, stdin)
+		if err != nil {
+			return nil, fmt.Errorf("--from-curl: command %d: %w", i+1, err)
+		}
+		if cr.URL == "" {
+			return nil, fmt.Errorf("--from-curl: command %d has no URL", i+1)
+		}
+		if _, err := url.ParseRequestURI(cr.URL); err != nil {
+			return nil, fmt.Errorf("--from-curl: command %d: invalid URL: %w", i+1, err)
+		}
+		if ignored := cr.Client.loadIgnored(); len(ignored) > 0 {
+			fmt.Fprintf(errw, "warning: --from-curl: command %d: %s ignored, load tests use their own HTTP client\n", i+1, strings.Join(ignored, ", "))
+		}
+		reqs = append(reqs, cr)
+	}
+	return reqs, nil
+}
+
+// runCurlMix sends total requests over several --from-curl commands, each
+// request picking one at random with equal weight, labeled 'METHOD /path'
+// for the per-endpoint report. base holds the options built from the other
+// flags; its headers win over curl headers with the same name. pipe places
+// the credentials and signers of each command in the stages of the flag
+// hooks.
+func runCurlMix(ctx context.Context, reqs []curlRequest, base runner.Options, pipe hookPipeline, total, concurrency int) (runner.Report, error) {
+	steps := make([]runner.Step, 0, len(reqs))
+	for i, cr := range reqs {
+		for k, vals := range cr.Headers {
+			for _, v := range vals {
+				if tmpl.Parse(v).Dynamic() {
+					return runner.Report{}, fmt.Errorf("--from-curl: command %d: header %s uses placeholders, which need a single command (use --header for shared ones)", i+1, k)
+				}
+			}
+		}
+		opts := base
+		opts.Method = cr.Method
+		opts.Headers = mergeHeaders(cr.Headers, base.Headers)
+		opts.Body, opts.BodyFunc = cr.Body, cr.BodyFunc
+		opts.Hooks = pipe.hooks(&cr)
+		path := "/"
+		if u, err := url.Parse(cr.URL); err == nil && u.Path != "" {
+			path = u.Path
+		}
+		opts.Label = cr.Method + " " + path
+		steps = append(steps, runner.Step{URL: cr.URL, Options: opts, Weight: 1})
+	}
+	return runner.RunWeighted(ctx, steps, total, concurrency)
+}
+
+// mergeHeaders returns the curl headers with the --header values applied on
+// top: a key given with --header replaces every curl value for that key.
+func mergeHeaders(curl, override http.Header) http.Header {
+	hdr := curl.Clone()
+	if hdr == nil {
+		hdr = make(http.Header)
+	}
+	for k, vals := range override {
+		hdr[k] = append([]string(nil), vals...)
+	}
+	return hdr
+}
+
+// curlCommand describes a request to print as a curl command line.
+type curlCommand struct {
+	Method  string
+	URL     string
+	Headers http.Header
+	// Args are extra arguments such as body, form, credential and signing
+	// flags, in order.
+	Args []string
+	// Notes explain settings that curl cannot reproduce.
+	Notes []string
+}
+
+// String renders the command with one option per line, quoted for a POSIX
+// shell.
+func (c curlCommand) String() string {
+	parts := []string{"curl " + shellQuote(c.URL)}
+	hasBody := false
+	for _, a := range c.Args {
+		if strings.HasPrefix(a, "-d") || strings.HasPrefix(a, "--data") || strings.HasPrefix(a, "-F") || strings.HasPrefix(a, "--form") {
+			hasBody = true
+		}
+	}
+	switch {
+	case c.Method == http.MethodHead:
+		parts = append(parts, "-I")
+	case c.Method == "" || (c.Method == http.MethodGet && !hasBody) || (c.Method == http.MethodPost && hasBody):
+		// implied by curl
+	default:
+		parts = append(parts, "-X "+c.Method)
+	}
+
+	keys := make([]string, 0, len(c.Headers))
+	for k := range c.Headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range c.Headers[k] {
+			parts = append(parts, "-H "+shellQuote(k+": "+v))
+		}
+	}
+	for i := 0; i < len(c.Args); i++ {
+		a := c.Args[i]
+		if strings.HasPrefix(a, "-") && i+1 < len(c.Args) && !strings.HasPrefix(c.Args[i+1], "-") {
+			parts = append(parts, a+" "+shellQuote(c.Args[i+1]))
+			i++
+			continue
+		}
+		parts = append(parts, shellQuote(a))
+	}
+	return strings.Join(parts, " \\\n  ")
+}
+
+// requestCurl builds the curl command for the request flags shared by run
+// and ramp. Values are taken as given, so @file arguments stay file
+// references.
+func requestCurl(method, target string, hdr http.Header, body, bodyFile string, forms []formArg, formEncoding string, af *authFlags, sf *signFlags, tf *templateFlags) curlCommand {
+	c := curlCommand{Method: method, URL: target, Headers: hdr}
+	switch {
+	case bodyFile != "":
+		c.Args = append(c.Args, "--data-binary", "@"+bodyFile)
+	case strings.HasPrefix(body, "@"):
+		c.Args = append(c.Args, "--data-binary", body)
+	case body != "":
+		c.Args = append(c.Args, "--data-raw", body)
+	}
+
+	urlencoded := strings.EqualFold(strings.TrimSpace(formEncoding), "urlencoded")
+	for _, a := range forms {
+		if !a.literal && strings.Contains(a.spec, "=@random:") {
+			c.Notes = append(c.Notes, fmt.Sprintf("form part %q generates random content per request; curl needs a real file", a.spec))
+		}
+		name, value, _ := strings.Cut(a.spec, "=")
+		switch {
+		case a.literal && urlencoded:
+			c.Args = append(c.Args, "--data-urlencode", name+"="+value)
+		case a.literal:
+			c.Args = append(c.Args, "--form-string", a.spec)
+		case !urlencoded:
+			c.Args = append(c.Args, "-F", a.spec)
+		case strings.HasPrefix(value, "@") || strings.HasPrefix(value, "<"):
+			file, _, _ := strings.Cut(value[1:], ";")
+			c.Args = append(c.Args, "--data-urlencode", name+"@"+file)
+		default:
+			c.Args = append(c.Args, "--data-urlencode", name+"="+value)
+		}
+	}
+
+	args, notes := af.curlArgs()
+	c.Args, c.Notes = append(c.Args, args...), append(c.Notes, notes...)
+	args, notes = sf.curlArgs()
+	c.Args, c.Notes = append(c.Args, args...), append(c.Notes, notes...)
+	c.Notes = append(c.Notes, tf.curlNotes(hdr)...)
+	return c
+}
+
+// curlCommands returns the --from-curl requests as curl commands, with the
+// --header values and the auth, signing and template flags of run or ramp
+// applied.
+func curlCommands(reqs []curlRequest, hdr http.Header, af *authFlags, sf *signFlags, tf *templateFlags) []curlCommand {
+	cmds := make([]curlCommand, 0, len(reqs))
+	for _, cr := range reqs {
+		c := curlCommand{Method: cr.Method, URL: cr.URL, Headers: mergeHeaders(cr.Headers, hdr)}
+		c.Args = append(c.Args, cr.Args...)
+		args, notes := af.curlArgs()
+		c.Args, c.Notes = append(c.Args, args...), append(c.Notes, notes...)
+		args, notes = sf.curlArgs()
+		c.Args, c.Notes = append(c.Args, args...), append(c.Notes, notes...)
+		c.Notes = append(c.Notes, tf.curlNotes(c.Headers)...)
+		cmds = append(cmds, c)
+	}
+	return cmds
+}
+
+// writeCurl prints commands to w, separated by blank lines, and their notes
+// to errw.
+func writeCurl(w, errw io.Writer, cmds []curlCommand) {
+	for i, c := range cmds {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, c.String())
+		for _, n := range c.Notes {
+			fmt.Fprintf(errw, "note: %s\n", n)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/JeanGrijp/stress-test/internal/runner"
+	"github.com/JeanGrijp/stress-test/internal/scenario"
+	"github.com/spf13/cobra"
+)
+
+// harFlags holds the flags that take requests from a HAR file.
+type harFlags struct {
+	path          string
+	mode          string
+	domains       []string
+	methods       []string
+	includeStatic bool
+	timing        bool
+}
+
+func (f *harFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.path, "har", "", "HAR file whose requests replace --url ('-' for stdin)")
+	cmd.Flags().StringVar(&f.mode, "har-mode", "weighted", "How HAR entries are sent: weighted|flow")
+	cmd.Flags().StringArrayVar(&f.domains, "har-domain", nil, "Keep only HAR entries for this domain and its subdomains (repeatable)")
+	cmd.Flags().StringArrayVar(&f.methods, "har-method", nil, "Keep only HAR entries with this method (repeatable)")
+	cmd.Flags().BoolVar(&f.includeStatic, "har-include-static", false, "Keep static assets (images, fonts, CSS, JS, media)")
+	cmd.Flags().BoolVar(&f.timing, "har-timing", false, "In flow mode, wait the recorded time between requests")
+}
+
+// run loads the HAR file and sends its entries with base options: total
+// weighted requests, or total flow iterations.
+func (f *harFlags) run(ctx context.Context, stdin io.Reader, base runner.Options, total, concurrency int) (runner.Report, error) {
+	mode := strings.ToLower(strings.TrimSpace(f.mode))
+	if mode != "weighted" && mode != "flow" {
+		return runner.Report{}, fmt.Errorf("unsupported --har-mode: %s (use weighted|flow)", f.mode)
+	}
+	if f.timing && mode != "flow" {
+		return runner.Report{}, errors.New("--har-timing requires --har-mode flow")
+	}
+	reqs, err := scenario.LoadHAR(f.path, stdin, scenario.HARFilter{
+		Domains:       f.domains,
+		Methods:       f.methods,
+		IncludeStatic: f.includeStatic,
+	})
+	if err != nil {
+		return runner.Report{}, err
+	}
+	if len(reqs) == 0 {
+		return runner.Report{}, fmt.Errorf("%s: no entries left after filtering", f.path)
+	}
+	if mode == "weighted" {
+		reqs = scenario.Weighted(reqs)
+	}
+	steps, err := scenario.Steps(reqs, base, 1, nil)
+	if err != nil {
+		return runner.Report{}, err
+	}
+	if mode == "flow" {
+		return runner.RunFlow(ctx, steps, total, concurrency, f.timing)
+	}
+	return runner.RunWeighted(ctx, steps, total, concurrency)
+}
@@ -0,0 +1,408 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/JeanGrijp/stress-test/internal/history"
+	"github.com/JeanGrijp/stress-test/internal/report"
+	"github.com/spf13/cobra"
+)
+
+// historyEnv names the default history directory; setting it turns the
+// history on for run and ramp.
+const historyEnv = "STRESS_TEST_HISTORY"
+
+// historyFlags holds the flags that save a result in a history directory.
+type historyFlags struct {
+	dir  string
+	tags []string
+}
+
+func (f *historyFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.dir, "history", "", "Save the result in this history directory (default $"+historyEnv+")")
+	cmd.Flags().StringArrayVar(&f.tags, "tag", nil, "Label the result 'key=value', e.g. env=staging or service=api (repeatable)")
+}
+
+// tag labels res with the --tag values and, when it is saved to a
+// history, the current git commit unless a git tag was given.
+func (f *historyFlags) tag(res *report.Result) error {
+	tags, err := parseTags(f.tags)
+	if err != nil {
+		return err
+	}
+	if firstNonEmpty(f.dir, os.Getenv(historyEnv)) != "" && tags["git"] == "" {
+		if sha, err := exec.Command("git", "rev-parse", "--short=12", "HEAD").Output(); err == nil {
+			if tags == nil {
+				tags = make(map[string]string)
+			}
+			tags["git"] = strings.TrimSpace(string(sha))
+		}
+	}
+	res.Tags = tags
+	return nil
+}
+
+// save stores res in the history, if one is set, and reports its id on w.
+func (f *historyFlags) save(w io.Writer, res *report.Result) error {
+	dir := firstNonEmpty(f.dir, os.Getenv(historyEnv))
+	if dir == "" {
+		return nil
+	}
+	store, err := history.Open(dir)
+	if err != nil {
+		return err
+	}
+	e, err := store.Save(res)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Saved to history %s as %s\n", dir, e.ID)
+	return nil
+}
+
+// parseTags parses 'key=value' tags.
+func parseTags(vals []string) (map[string]string, error) {
+	if len(vals) == 0 {
+		return nil, nil
+	}
+	tags := make(map[string]string, len(vals))
+	for _, v := range vals {
+		k, val, ok := strings.Cut(v, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid --tag %q (use key=value)", v)
+		}
+		tags[k] = strings.TrimSpace(val)
+	}
+	return tags, nil
+}
+
+// NewHistoryCmd lists, shows and follows results saved with --history.
+// Example:
+//
+//	stress-test history trend --metric p95 --tag env=staging
+func NewHistoryCmd() *cobra.Command {
+	var dir string
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "List, show and follow the trend of saved results",
+		Long: `Browse a local history of results. 'run' and 'ramp' save their result
+in the history when --history DIR is set, or when $` + historyEnv + ` is,
+labeled with --tag key=value (e.g. env=staging, service=api) and with the
+current git commit (tag 'git') when run inside a git work tree.
+
+A history is a directory holding every result as results/<id>.json plus an
+index (index.jsonl) of their targets, tags and headline metrics; it can be
+kept in CI caches or artifacts and copied around. The saved results are
+regular JSON results: 'report' and 'compare' read them too.
+
+Subcommands:
+	list   List the saved results, most recent last
+	show   Render a saved result in any 'report' format
+	trend  Follow a metric over time and flag outliers
+
+Flags overview:
+	--dir  History directory (default $` + historyEnv + `)`,
+		Example: `# Save every run of a CI job with its environment and service
+export ` + historyEnv + `=.stress-history
+stress-test run --url https://staging.example.com/api --requests 5000 --concurrency 50 \
+	--tag env=staging --tag service=api
+
+stress-test history list --tag service=api
+stress-test history show latest --output markdown
+stress-test history trend --metric p95 --tag env=staging --tag service=api`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+	cmd.PersistentFlags().StringVar(&dir, "dir", "", "History directory (default $"+historyEnv+")")
+
+	open := func() (*history.Store, error) {
+		d := firstNonEmpty(dir, os.Getenv(historyEnv))
+		if d == "" {
+			return nil, errors.New("no history directory: set --dir or $" + historyEnv)
+		}
+		if _, err := os.Stat(d); err != nil {
+			return nil, fmt.Errorf("history: %w", err)
+		}
+		return &history.Store{Dir: d}, nil
+	}
+
+	cmd.AddCommand(newHistoryListCmd(open), newHistoryShowCmd(open), newHistoryTrendCmd(open))
+	return cmd
+}
+
+// historyFilter holds the flags that select history entries.
+type historyFilter struct {
+	command string
+	target  string
+	tags    []string
+	limit   int
+}
+
+func (f *historyFilter) register(cmd *cobra.Command, limit int) {
+	cmd.Flags().StringVar(&f.command, "command", "", "Only results of this command (run, ramp, ...)")
+	cmd.Flags().StringVar(&f.target, "target", "", "Only results against this URL, HAR file or source")
+	cmd.Flags().StringArrayVar(&f.tags, "tag", nil, "Only results tagged 'key=value' (repeatable, all must match)")
+	cmd.Flags().IntVar(&f.limit, "limit", limit, "Show the N most recent results (0 = all)")
+}
+
+// list returns the most recent matching entries, oldest first.
+func (f *historyFilter) list(store *history.Store) ([]history.Entry, error) {
+	if f.limit < 0 {
+		return nil, errors.New("--limit must be >= 0")
+	}
+	tags, err := parseTags(f.tags)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := store.List(history.Filter{Command: f.command, Target: f.target, Tags: tags})
+	if err != nil {
+		return nil, err
+	}
+	if f.limit > 0 && len(entries) > f.limit {
+		entries = entries[len(entries)-f.limit:]
+	}
+	return entries, nil
+}
+
+func newHistoryListCmd(open func() (*history.Store, error)) *cobra.Command {
+	var (
+		filter historyFilter
+		output string
+	)
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the saved results, most recent last",
+		Example: `stress-test history list --tag env=staging --limit 50
+stress-test history list --command ramp --output json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := outputFormat(output, "text", "json")
+			if err != nil {
+				return err
+			}
+			store, err := open()
+			if err != nil {
+				return err
+			}
+			entries, err := filter.list(store)
+			if err != nil {
+				return err
+			}
+			w := cmd.OutOrStdout()
+			if format == "json" {
+				if entries == nil {
+					entries = []history.Entry{}
+				}
+				return report.WriteJSON(w, entries)
+			}
+			if len(entries) == 0 {
+				fmt.Fprintln(w, "No results.")
+				return nil
+			}
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tTime\tCommand\tTarget\tTags\tRequests\tRPS\tp95\tErrors")
+			for _, e := range entries {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%.2f\t%s\t%.2f%%\n", e.ID, e.Time.UTC().Format("2006-01-02 15:04"),
+					e.Command, e.Target, formatTags(e.Tags), e.Requests, e.RPS, formatHistoryMetric("p95", e.P95), e.ErrorRate)
+			}
+			return tw.Flush()
+		},
+	}
+	filter.register(cmd, 20)
+	cmd.Flags().StringVar(&output, "output", "text", "Output format: text|json")
+	return cmd
+}
+
+func newHistoryShowCmd(open func() (*history.Store, error)) *cobra.Command {
+	var (
+		output  string
+		outFile string
+		top     int
+	)
+	cmd := &cobra.Command{
+		Use:   "show ID",
+		Short: "Render a saved result in any report format",
+		Long: `Render a saved result like 'stress-test report'. ID may be a unique prefix
+of an id, or 'latest' for the most recent result.`,
+		Example: `stress-test history show latest
+stress-test history show 20261018-1509 --out-file result.html`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !cmd.Flags().Changed("output") && outFile != "" {
+				if f := report.FormatForFile(outFile); f != "" {
+					output = f
+				}
+			}
+			format, err := outputFormat(output, append(report.Formats, "md", "xml")...)
+			if err != nil {
+				return err
+			}
+			if top < 0 {
+				return errors.New("--top must be >= 0")
+			}
+			store, err := open()
+			if err != nil {
+				return err
+			}
+			e, err := store.Find(args[0])
+			if err != nil {
+				return err
+			}
+			res, err := store.Load(e)
+			if err != nil {
+				return fmt.Errorf("%s: %w", e.ID, err)
+			}
+			return writeResult(cmd.OutOrStdout(), outFile, format, res, report.Options{TopEndpoints: top})
+		},
+	}
+	cmd.Flags().StringVar(&output, "output", "text", "Output format: text|json|markdown|csv|html|junit")
+	cmd.Flags().StringVar(&outFile, "out-file", "", "Write the report to file instead of stdout")
+	cmd.Flags().IntVar(&top, "top", 20, "Number of endpoints shown in text and Markdown output (0 = all)")
+	return cmd
+}
+
+func newHistoryTrendCmd(open func() (*history.Store, error)) *cobra.Command {
+	var (
+		filter historyFilter
+		metric string
+		output string
+	)
+	cmd := &cobra.Command{
+		Use:   "trend",
+		Short: "Follow a metric over time and flag outliers",
+		Long: `Print a metric of the matching results in time order, with its change from
+the previous result, and flag outliers: results far from the median of the
+shown ones (modified z-score above 3.5, from the median absolute deviation).
+Outliers need at least 3 results.
+
+Metrics (--metric): rps, p50, p90, p95, p99, error_rate, requests.
+
+Mixing targets or environments makes a trend meaningless; narrow it down
+with --target, --command and --tag.`,
+		Example: `stress-test history trend --metric p95 --tag env=staging --tag service=api
+stress-test history trend --metric rps --target https://staging.example.com/api --limit 100`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := outputFormat(output, "text", "json")
+			if err != nil {
+				return err
+			}
+			metric = strings.TrimSpace(metric)
+			if !slices.Contains(history.Metrics, metric) {
+				return fmt.Errorf("unsupported --metric: %s (use %s)", metric, strings.Join(history.Metrics, ", "))
+			}
+			store, err := open()
+			if err != nil {
+				return err
+			}
+			entries, err := filter.list(store)
+			if err != nil {
+				return err
+			}
+			points := history.Trend(entries, metric)
+			w := cmd.OutOrStdout()
+			if format == "json" {
+				return report.WriteJSON(w, points)
+			}
+			if len(points) == 0 {
+				fmt.Fprintln(w, "No results.")
+				return nil
+			}
+			targets := make(map[string]bool)
+			for _, p := range points {
+				targets[p.Entry.Target] = true
+			}
+			if len(targets) > 1 {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Note: the results target %d different URLs or files; narrow them down with --target\n", len(targets))
+			}
+			writeTrend(w, metric, points)
+			return nil
+		},
+	}
+	filter.register(cmd, 30)
+	cmd.Flags().StringVar(&metric, "metric", "p95", "Metric to follow: "+strings.Join(history.Metrics, "|"))
+	cmd.Flags().StringVar(&output, "output", "text", "Output format: text|json")
+	return cmd
+}
+
+// trendBarWidth is the width of the bar of the largest value.
+const trendBarWidth = 30
+
+func writeTrend(w io.Writer, metric string, points []history.Point) {
+	values := make([]float64, len(points))
+	var maxV float64
+	for i, p := range points {
+		values[i] = p.Value
+		maxV = max(maxV, p.Value)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Time\tID\tTags\t%s\tChange\t\tOutlier\n", metric)
+	var outliers []string
+	for i, p := range points {
+		change := "-"
+		if i > 0 && values[i-1] != 0 {
+			change = fmt.Sprintf("%+.1f%%", 100*(p.Value-values[i-1])/values[i-1])
+		}
+		bar := ""
+		if maxV > 0 {
+			bar = strings.Repeat("#", int(p.Value/maxV*trendBarWidth+0.5))
+		}
+		if p.Outlier != "" {
+			outliers = append(outliers, p.Entry.ID)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", p.Entry.Time.UTC().Format("2006-01-02 15:04"), p.Entry.ID,
+			formatTags(p.Entry.Tags), formatHistoryMetric(metric, p.Value), change, bar, p.Outlier)
+	}
+	_ = tw.Flush()
+
+	med := report.Median(values)
+	last := values[len(values)-1]
+	fmt.Fprintf(w, "\n%s over %d results: median %s, min %s, max %s, last %s", metric, len(values),
+		formatHistoryMetric(metric, med), formatHistoryMetric(metric, slices.Min(values)),
+		formatHistoryMetric(metric, slices.Max(values)), formatHistoryMetric(metric, last))
+	if med != 0 {
+		fmt.Fprintf(w, " (%+.1f%% from the median)", 100*(last-med)/med)
+	}
+	fmt.Fprintln(w)
+	if len(outliers) > 0 {
+		fmt.Fprintf(w, "Outliers: %s\n", strings.Join(outliers, ", "))
+	}
+}
+
+func formatHistoryMetric(metric string, v float64) string {
+	switch metric {
+	case "rps":
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	case "error_rate":
+		return fmt.Sprintf("%.2f%%", v)
+	case "requests":
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	return roundDuration(time.Duration(v * float64(time.Millisecond))).String()
+}
+
+// formatTags joins tags as sorted key=value pairs.
+func formatTags(tags map[string]string) string {
+	if len(tags) == 0 {
+		return "-"
+	}
+	parts := make([]string, 0, len(tags))
+	for _, k := range slices.Sorted(maps.Keys(tags)) {
+		parts = append(parts, k+"="+tags[k])
+	}
+	return strings.Join(parts, ",")
+}
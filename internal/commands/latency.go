@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"maps"
+	"time"
+
+	"github.com/JeanGrijp/stress-test/internal/report"
+	"github.com/JeanGrijp/stress-test/internal/runner"
+)
+
+// summarizeLatency converts h to its JSON summary, or nil when it is empty.
+func summarizeLatency(h runner.Histogram) *report.Latency {
+	if h.Count == 0 {
+		return nil
+	}
+	return &report.Latency{
+		Count: h.Count,
+		Min:   ms(h.Min),
+		Mean:  ms(h.Mean()),
+		P50:   ms(h.Quantile(0.50)),
+		P90:   ms(h.Quantile(0.90)),
+		P95:   ms(h.Quantile(0.95)),
+		P99:   ms(h.Quantile(0.99)),
+		Max:   ms(h.Max),
+		// bucket indexes are the runner's; keep them for compare
+		Buckets: maps.Clone(h.Buckets),
+	}
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// roundDuration keeps three significant digits for readability.
+func roundDuration(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond / 10)
+	}
+}
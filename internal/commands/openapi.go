@@ -0,0 +1,304 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/JeanGrijp/stress-test/internal/feeder"
+	"github.com/JeanGrijp/stress-test/internal/openapi"
+	"github.com/JeanGrijp/stress-test/internal/scenario"
+	"github.com/spf13/cobra"
+)
+
+// NewOpenAPICmd generates a scenario file from an OpenAPI 3 document.
+// Example:
+//
+//	stress-test openapi api.yaml --out scenario.jsonl
+func NewOpenAPICmd() *cobra.Command {
+	var (
+		outPath      string
+		baseURL      string
+		tags         []string
+		operations   []string
+		feederPath   string
+		optional     bool
+		deprecated   bool
+		skipNoExpect bool
+		run          bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "openapi SPEC [-- REPLAY FLAGS]",
+		Short: "Generate a load scenario from an OpenAPI 3 document",
+		Long: `Read an OpenAPI 3 document (JSON or YAML) and write a scenario file with
+one request per operation, ready for 'stress-test replay'.
+
+For every operation:
+	URL           --base-url (default: the first server in the document)
+	              plus the path with parameters filled in
+	parameters    required path, query and header parameters get their
+	              example, or a value generated from their schema;
+	              parameters named like a --feeder column become
+	              {feed:column} placeholders, rendered per request by replay
+	body          the example of the preferred media type (JSON first), or
+	              one generated from its schema
+	expect        the 2xx/3xx response codes declared in the spec; other
+	              statuses are reported as unexpected
+	label         the operationId (or 'METHOD /path'), used to report
+	              latency per operation
+
+Flags overview:
+	--out                 Scenario file to write (default stdout)
+	--base-url            Base URL for every request
+	--tag                 Repeatable: keep operations with this tag
+	--operation           Repeatable: keep operations with this operationId
+	--feeder              Data file whose columns provide path/query parameters
+	--optional-params     Also fill optional query and header parameters
+	--include-deprecated  Keep deprecated operations (skipped by default)
+	--require-success-response
+	                      Skip operations that declare no 2xx/3xx response
+	--run                 Replay the requests right away (replay flags after --)
+
+Requests get no offsets, so replay them with --speed max, e.g.
+'stress-test replay scenario.jsonl --speed max --loops 100 --feeder ids.csv'.
+--run does both steps at once: it replays the requests at --speed max with
+the same --feeder, passing the flags after -- to replay; --out then only
+keeps a copy of the scenario.`,
+		Example: `# Every operation of the spec against staging
+stress-test openapi api.yaml --base-url https://staging.example.com --out api.jsonl
+stress-test replay api.jsonl --speed max --loops 50 --concurrency 20
+
+# Only the 'orders' tag, with order IDs from a CSV (column 'orderId')
+stress-test openapi api.yaml --tag orders --feeder orders.csv --out orders.jsonl
+stress-test replay orders.jsonl --speed max --loops 200 --feeder orders.csv --feeder-mode random
+
+# Generate and run in one step, failing CI when p95 goes over 300ms
+stress-test openapi api.yaml --base-url https://staging.example.com --run -- \
+	--loops 50 --concurrency 20 --threshold 'p95<300ms'`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			n := cmd.ArgsLenAtDash()
+			if n < 0 {
+				n = len(args)
+			}
+			return cobra.ExactArgs(1)(cmd, args[:n])
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 1 && !run {
+				return errors.New("flags after -- are passed to replay and need --run")
+			}
+			if run && outPath == "-" {
+				return errors.New("--run writes its results to stdout; use --out FILE to keep the scenario")
+			}
+			spec, err := openapi.Load(args[0])
+			if err != nil {
+				return err
+			}
+			base := baseURL
+			if base == "" {
+				servers := spec.Servers()
+				if len(servers) == 0 || !strings.HasPrefix(servers[0], "http") {
+					return errors.New("the document declares no absolute server URL; set --base-url")
+				}
+				base = servers[0]
+			}
+			if u, err := url.Parse(base); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("invalid base URL (use http(s)://host[:port][/prefix]): %q", base)
+			}
+			var columns []string
+			if feederPath != "" {
+				f, err := feeder.Load(feederPath, "")
+				if err != nil {
+					return err
+				}
+				columns = f.Columns()
+			}
+
+			var reqs []scenario.Request
+			for _, op := range spec.Operations() {
+				if op.Deprecated && !deprecated {
+					continue
+				}
+				if len(operations) > 0 && !slices.Contains(operations, op.ID) {
+					continue
+				}
+				if len(tags) > 0 && !slices.ContainsFunc(op.Tags, func(t string) bool { return containsFold(tags, t) }) {
+					continue
+				}
+				if skipNoExpect && len(op.Statuses) == 0 {
+					continue
+				}
+				req, err := operationRequest(op, base, columns, optional)
+				if err != nil {
+					return err
+				}
+				reqs = append(reqs, req)
+			}
+			if len(reqs) == 0 {
+				return errors.New("no operations match the filters")
+			}
+
+			if outPath != "" && outPath != "-" {
+				f, err := os.Create(outPath)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				if err := writeScenario(f, reqs); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.ErrOrStderr(), "Wrote %d requests to %s\n", len(reqs), outPath)
+			} else if !run {
+				return writeScenario(cmd.OutOrStdout(), reqs)
+			}
+			if !run {
+				return nil
+			}
+
+			replay := newReplayCmd(func(string, io.Reader) ([]scenario.Request, error) { return reqs, nil })
+			replayArgs := []string{args[0], "--speed", "max"}
+			if feederPath != "" {
+				replayArgs = append(replayArgs, "--feeder", feederPath)
+			}
+			replay.SetArgs(append(replayArgs, args[1:]...))
+			replay.SetIn(cmd.InOrStdin())
+			replay.SetOut(cmd.OutOrStdout())
+			replay.SetErr(cmd.ErrOrStderr())
+			replay.SilenceUsage, replay.SilenceErrors = true, true
+			return replay.ExecuteContext(cmd.Context())
+		},
+	}
+
+	cmd.Flags().StringVar(&outPath, "out", "", "Scenario file to write (default stdout)")
+	cmd.Flags().StringVar(&baseURL, "base-url", "", "Base URL for every request (default: first server in the document)")
+	cmd.Flags().StringArrayVar(&tags, "tag", nil, "Keep operations with this tag (repeatable)")
+	cmd.Flags().StringArrayVar(&operations, "operation", nil, "Keep the operation with this operationId (repeatable)")
+	cmd.Flags().StringVar(&feederPath, "feeder", "", "Data feeder file whose columns provide parameter values as {feed:column}")
+	cmd.Flags().BoolVar(&optional, "optional-params", false, "Also fill optional query and header parameters")
+	cmd.Flags().BoolVar(&deprecated, "include-deprecated", false, "Keep deprecated operations")
+	cmd.Flags().BoolVar(&skipNoExpect, "require-success-response", false, "Skip operations that declare no 2xx/3xx response")
+	cmd.Flags().BoolVar(&run, "run", false, "Replay the generated requests at --speed max; flags after -- go to replay")
+	return cmd
+}
+
+// writeScenario writes reqs to w as a scenario file.
+func writeScenario(w io.Writer, reqs []scenario.Request) error {
+	sw := scenario.NewWriter(w)
+	for _, r := range reqs {
+		if err := sw.Write(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// operationRequest builds the scenario request for op.
+func operationRequest(op openapi.Operation, base string, columns []string, optional bool) (scenario.Request, error) {
+	value := func(p openapi.Parameter) (string, bool) {
+		if slices.Contains(columns, p.Name) {
+			return "{feed:" + p.Name + "}", true
+		}
+		if p.Example == nil {
+			return "", false
+		}
+		return scalarString(p.Example), false
+	}
+
+	path := op.Path
+	query := []string{}
+	hdr := make(http.Header)
+	for _, p := range op.Params {
+		if !p.Required && !optional {
+			continue
+		}
+		v, placeholder := value(p)
+		switch p.In {
+		case "path":
+			if v == "" {
+				v = "1"
+			}
+			if !placeholder {
+				v = url.PathEscape(v)
+			}
+			path = strings.ReplaceAll(path, "{"+p.Name+"}", v)
+		case "query":
+			if v == "" && !p.Required {
+				continue
+			}
+			if !placeholder {
+				v = url.QueryEscape(v)
+			}
+			query = append(query, url.QueryEscape(p.Name)+"="+v)
+		case "header":
+			if v != "" {
+				hdr.Set(p.Name, v)
+			}
+		}
+	}
+	sort.Strings(query)
+	target := strings.TrimSuffix(base, "/") + path
+	if len(query) > 0 {
+		target += "?" + strings.Join(query, "&")
+	}
+
+	req := scenario.Request{Method: op.Method, URL: target, Expect: op.Statuses, Label: op.ID}
+	if req.Label == "" {
+		req.Label = op.Method + " " + op.Path
+	}
+	if b := op.Body; b != nil && b.Example != nil {
+		body, err := encodeExample(b.ContentType, b.Example)
+		if err != nil {
+			return scenario.Request{}, fmt.Errorf("%s: %w", req.Label, err)
+		}
+		req.SetPayload(body)
+		hdr.Set("Content-Type", b.ContentType)
+	}
+	if len(hdr) > 0 {
+		req.Headers = hdr
+	}
+	return req, nil
+}
+
+// encodeExample serializes a body example for its media type.
+func encodeExample(contentType string, v any) ([]byte, error) {
+	ct := strings.ToLower(contentType)
+	switch {
+	case ct == "application/x-www-form-urlencoded":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return []byte(scalarString(v)), nil
+		}
+		form := url.Values{}
+		for k, e := range obj {
+			form.Set(k, scalarString(e))
+		}
+		return []byte(form.Encode()), nil
+	case strings.Contains(ct, "json"):
+		return json.Marshal(v)
+	default:
+		if s, ok := v.(string); ok {
+			return []byte(s), nil
+		}
+		return json.Marshal(v)
+	}
+}
+
+// scalarString formats an example value for a URL, header or form field;
+// composite values are JSON encoded.
+func scalarString(v any) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case map[string]any, []any:
+		b, _ := json.Marshal(t)
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
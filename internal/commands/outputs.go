@@ -0,0 +1,175 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/JeanGrijp/stress-test/internal/report"
+	"github.com/JeanGrijp/stress-test/internal/runner"
+	"github.com/JeanGrijp/stress-test/internal/sink"
+	"github.com/spf13/cobra"
+)
+
+// prometheusPushInterval is how often --out prometheus pushes while the
+// test runs.
+const prometheusPushInterval = 10 * time.Second
+
+// outFlags holds the repeatable --out outputs of a test and the rotation
+// of its per-request log.
+type outFlags struct {
+	outs       []string
+	logMaxSize string
+	logKeep    int
+}
+
+func (f *outFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&f.outs, "out", nil, "Additional output 'TYPE=PATH': a result format, ndjson or prometheus=URL ('-' is stdout, repeatable)")
+	cmd.Flags().StringVar(&f.logMaxSize, "log-max-size", "0", "Rotate the --out ndjson file when it reaches this size, e.g. 100MiB (0 = never)")
+	cmd.Flags().IntVar(&f.logKeep, "log-keep", 5, "Rotated --out ndjson files to keep (FILE.1 is the most recent)")
+}
+
+// outputs are where the results of a test go: result formats written when
+// it ends and sinks that follow it while it runs.
+type outputs struct {
+	results    []resultOut
+	ndjson     string // path of the per-request log, "-" for stdout
+	logMaxSize int64  // rotation size of the log, 0 to never rotate
+	logKeep    int
+	pushURL    string // Prometheus Pushgateway
+
+	sinks   []runner.Sink
+	closers []func() error
+}
+
+type resultOut struct {
+	format string
+	path   string // "" for stdout
+}
+
+// parse validates --out together with --output and --out-file, which are
+// a shorthand for one more result output. They are dropped when --out
+// names a result format and neither is set.
+func (f *outFlags) parse(cmd *cobra.Command, output, outFile string) (*outputs, error) {
+	o := &outputs{}
+	for _, v := range f.outs {
+		typ, path, _ := strings.Cut(v, "=")
+		typ, path = strings.ToLower(strings.TrimSpace(typ)), strings.TrimSpace(path)
+		switch {
+		case slices.Contains(report.Formats, typ):
+			if path == "-" {
+				path = ""
+			}
+			o.results = append(o.results, resultOut{typ, path})
+		case typ == "ndjson":
+			if o.ndjson != "" {
+				return nil, errors.New("--out ndjson may be set only once")
+			}
+			o.ndjson = firstNonEmpty(path, "-")
+		case typ == "prometheus":
+			if path == "" {
+				return nil, errors.New("--out prometheus needs the Pushgateway URL, e.g. prometheus=http://localhost:9091")
+			}
+			if o.pushURL != "" {
+				return nil, errors.New("--out prometheus may be set only once")
+			}
+			o.pushURL = path
+		default:
+			return nil, fmt.Errorf("unsupported --out type %q (use %s, ndjson or prometheus)", typ, strings.Join(report.Formats, ", "))
+		}
+	}
+	var err error
+	if o.logMaxSize, err = parseSizeFlag("--log-max-size", f.logMaxSize); err != nil {
+		return nil, err
+	}
+	if o.logMaxSize > 0 && (o.ndjson == "" || o.ndjson == "-") {
+		return nil, errors.New("--log-max-size needs --out ndjson=FILE")
+	}
+	if f.logKeep < 0 {
+		return nil, errors.New("--log-keep must be >= 0")
+	}
+	o.logKeep = f.logKeep
+	if len(o.results) == 0 || cmd.Flags().Changed("output") || cmd.Flags().Changed("out-file") {
+		format, err := outputFormat(output, report.Formats...)
+		if err != nil {
+			return nil, err
+		}
+		o.results = append([]resultOut{{format, outFile}}, o.results...)
+	}
+
+	seen := make(map[string]bool)
+	switch o.ndjson {
+	case "":
+	case "-":
+		seen[""] = true
+	default:
+		seen[o.ndjson] = true
+	}
+	for _, r := range o.results {
+		if seen[r.path] {
+			if r.path == "" {
+				return nil, errors.New("only one output can go to stdout; write the others to files")
+			}
+			return nil, fmt.Errorf("%s is the destination of more than one output", r.path)
+		}
+		seen[r.path] = true
+	}
+	return o, nil
+}
+
+// open starts the sinks; stdout is used for '-'.
+func (o *outputs) open(stdout io.Writer) error {
+	if o.ndjson != "" {
+		var s *sink.NDJSON
+		switch {
+		case o.ndjson == "-":
+			s = sink.NewNDJSON(struct{ io.Writer }{stdout}) // never closes stdout
+		case o.logMaxSize > 0:
+			var err error
+			if s, err = sink.NewRotatingNDJSON(o.ndjson, o.logMaxSize, o.logKeep); err != nil {
+				return err
+			}
+		default:
+			f, err := os.Create(o.ndjson)
+			if err != nil {
+				return err
+			}
+			s = sink.NewNDJSON(f)
+		}
+		o.sinks = append(o.sinks, s)
+		o.closers = append(o.closers, s.Close)
+	}
+	if o.pushURL != "" {
+		s := sink.NewPrometheus(o.pushURL, prometheusPushInterval)
+		o.sinks = append(o.sinks, s)
+		o.closers = append(o.closers, s.Close)
+	}
+	return nil
+}
+
+// close stops the sinks and returns the first error; it may be called
+// more than once.
+func (o *outputs) close() error {
+	var first error
+	for _, c := range o.closers {
+		if err := c(); err != nil && first == nil {
+			first = err
+		}
+	}
+	o.closers = nil
+	return first
+}
+
+// write renders res to every result output.
+func (o *outputs) write(stdout io.Writer, res *report.Result, opts report.Options) error {
+	for _, r := range o.results {
+		if err := writeResult(stdout, r.path, r.format, res, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/JeanGrijp/stress-test/internal/runner"
+)
+
+// hookPipeline holds the per-request hooks of each stage. They run in this
+// order: templated headers first, then authentication, then signers (so the
+// signature covers the final headers).
+type hookPipeline struct {
+	tmpl    runner.Hook
+	auth    runner.Hook
+	signers []runner.Hook
+}
+
+// requestPipeline builds the hooks of the template, authentication and
+// signing flags. It returns the static headers left after removing
+// templated ones.
+func requestPipeline(hdr http.Header, tf *templateFlags, af *authFlags, sf *signFlags, stdin io.Reader) (http.Header, hookPipeline, error) {
+	var p hookPipeline
+	static, tmplHook, err := tf.hook(hdr, stdin)
+	if err != nil {
+		return nil, p, err
+	}
+	p.tmpl = tmplHook
+	if p.auth, err = af.hook(stdin); err != nil {
+		return nil, p, err
+	}
+	if p.signers, err = sf.hooks(stdin); err != nil {
+		return nil, p, err
+	}
+	return static, p, nil
+}
+
+// hooks returns the hooks in stage order. The credentials and signers of a
+// --from-curl request cr (nil for none) join their stages: its credentials
+// are used unless the flags set some, and its signers run before the flag
+// ones, so a flag signer has the last word.
+func (p hookPipeline) hooks(cr *curlRequest) []runner.Hook {
+	var hooks []runner.Hook
+	if p.tmpl != nil {
+		hooks = append(hooks, p.tmpl)
+	}
+	switch {
+	case p.auth != nil:
+		hooks = append(hooks, p.auth)
+	case cr != nil && cr.Auth != nil:
+		hooks = append(hooks, cr.Auth)
+	}
+	if cr != nil {
+		hooks = append(hooks, cr.Signers...)
+	}
+	return append(hooks, p.signers...)
+}
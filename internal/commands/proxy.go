@@ -0,0 +1,157 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/JeanGrijp/stress-test/internal/proxy"
+	"github.com/JeanGrijp/stress-test/internal/server"
+	"github.com/spf13/cobra"
+)
+
+// NewProxyCmd starts a fault-injecting reverse proxy in front of a target.
+// Example:
+//
+//	stress-test proxy --upstream http://127.0.0.1:8080 --route '/api:latency=100ms,error-rate=5%'
+func NewProxyCmd() *cobra.Command {
+	var (
+		addr          string
+		upstream      string
+		routes        []string
+		statsPath     string
+		latency       time.Duration
+		jitter        time.Duration
+		bandwidth     string
+		errorRate     string
+		errorStatus   int
+		resetRate     string
+		truncateRate  string
+		truncateAfter string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "proxy",
+		Short: "Start a fault-injecting reverse proxy in front of an upstream",
+		Long: `Start a reverse proxy that forwards to --upstream while injecting faults, so
+you can point run/ramp at it and see how clients and services behave under
+latency, slow links, errors, connection resets and truncated responses.
+
+Flags overview:
+	--addr           Address to listen on (default 127.0.0.1:8081)
+	--upstream       Upstream base URL (required)
+	--route          Per-route faults, repeatable:
+	                 '[METHOD ]PREFIX[:key=value,...]'
+	                 keys: latency, jitter, bandwidth, error-rate, error-status,
+	                 reset-rate, truncate-rate, truncate-after
+	--latency, --jitter, --bandwidth, --error-rate, --error-status,
+	--reset-rate, --truncate-rate, --truncate-after
+	                 Defaults for every request; routes inherit them and
+	                 override individual keys
+	--stats-path     Path of the proxy's own JSON counters (default /__proxy/stats)
+
+Routes match by longest path prefix; a route with a method wins over one
+without for the same prefix; a prefix may contain ':' (/v1/items:batch).
+Rates accept fractions (0.05) or percentages (5%) and may add up to at most
+1: for each request a single random draw picks at most one fault, in the
+order reset, error, truncate:
+	reset      the connection is closed without a response
+	error      error-status (default 503) is returned without contacting the upstream
+	truncate   the body is cut after truncate-after bytes (default: half of
+	           Content-Length) and the connection is closed
+Latency and jitter are added before forwarding; bandwidth (bytes/second,
+e.g. 64KiB) throttles response bodies. Stop with Ctrl+C.`,
+		Example: `# 100ms extra latency and 5% 503s on /api, everything else untouched
+stress-test proxy --upstream http://127.0.0.1:8080 --route '/api:latency=100ms,error-rate=5%'
+
+# Slow 3G-like link for every route, plus resets on uploads
+stress-test proxy --upstream https://staging.example.com \
+	--latency 300ms --jitter 100ms --bandwidth 50KiB \
+	--route 'POST /upload:reset-rate=2%'
+
+# Drive load through the proxy and check what was injected
+stress-test run --url http://127.0.0.1:8081/api/orders --requests 1000 --concurrency 20
+curl -s http://127.0.0.1:8081/__proxy/stats`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if upstream == "" {
+				return errors.New("--upstream is required")
+			}
+			u, err := url.Parse(upstream)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("invalid --upstream (use http(s)://host[:port]): %q", upstream)
+			}
+			if latency < 0 || jitter < 0 {
+				return errors.New("--latency and --jitter must be >= 0")
+			}
+
+			base := proxy.Rule{Prefix: "/", Latency: latency, Jitter: jitter, ErrorStatus: errorStatus}
+			if base.Bandwidth, err = parseSizeFlag("--bandwidth", bandwidth); err != nil {
+				return err
+			}
+			if base.TruncateAfter, err = parseSizeFlag("--truncate-after", truncateAfter); err != nil {
+				return err
+			}
+			for _, r := range []struct {
+				name string
+				val  string
+				dst  *float64
+			}{
+				{"--error-rate", errorRate, &base.ErrorRate},
+				{"--reset-rate", resetRate, &base.ResetRate},
+				{"--truncate-rate", truncateRate, &base.TruncateRate},
+			} {
+				if *r.dst, err = proxy.ParseRate(r.val); err != nil {
+					return fmt.Errorf("invalid %s: %w", r.name, err)
+				}
+			}
+			if err := base.CheckRates(); err != nil {
+				return fmt.Errorf("--reset-rate, --error-rate and --truncate-rate: %w", err)
+			}
+			if errorStatus < 100 || errorStatus > 999 {
+				return fmt.Errorf("invalid --error-status: %d", errorStatus)
+			}
+
+			rules := make([]proxy.Rule, 0, len(routes))
+			for _, spec := range routes {
+				r, err := proxy.ParseRule(spec, base)
+				if err != nil {
+					return err
+				}
+				rules = append(rules, r)
+			}
+
+			p, err := proxy.New(proxy.Config{Upstream: u, Rules: rules, Default: base, StatsPath: statsPath})
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			return server.Serve(ctx, server.ListenConfig{Addr: addr}, p, func(bound string) {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Proxying http://%s -> %s (Ctrl+C to stop)\n", bound, u)
+				if statsPath != "" {
+					fmt.Fprintf(cmd.ErrOrStderr(), "Stats: http://%s%s\n", bound, statsPath)
+				}
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&addr, "addr", "127.0.0.1:8081", "Address to listen on (use port 0 for a random port)")
+	cmd.Flags().StringVar(&upstream, "upstream", "", "Upstream base URL, e.g. http://127.0.0.1:8080")
+	cmd.Flags().StringArrayVar(&routes, "route", nil, "Per-route faults '[METHOD ]PREFIX[:key=value,...]' (repeatable)")
+	cmd.Flags().StringVar(&statsPath, "stats-path", proxy.DefaultStatsPath, "Path serving proxy counters as JSON (empty to disable)")
+	cmd.Flags().DurationVar(&latency, "latency", 0, "Latency added to every request before forwarding")
+	cmd.Flags().DurationVar(&jitter, "jitter", 0, "Random extra latency in [0, jitter)")
+	cmd.Flags().StringVar(&bandwidth, "bandwidth", "0", "Response body bandwidth limit in bytes/second, e.g. 64KiB (0 = unlimited)")
+	cmd.Flags().StringVar(&errorRate, "error-rate", "0", "Fraction of requests answered with --error-status (e.g. 0.05 or 5%)")
+	cmd.Flags().IntVar(&errorStatus, "error-status", 503, "Status code for injected errors")
+	cmd.Flags().StringVar(&resetRate, "reset-rate", "0", "Fraction of connections reset without a response")
+	cmd.Flags().StringVar(&truncateRate, "truncate-rate", "0", "Fraction of responses whose body is cut short")
+	cmd.Flags().StringVar(&truncateAfter, "truncate-after", "0", "Body bytes sent before truncating (0 = half of Content-Length)")
+	return cmd
+}
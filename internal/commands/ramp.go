@@ -2,15 +2,12 @@ package commands
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
 	"net/url"
-	"os"
-	"strings"
 	"time"
 
+	"github.com/JeanGrijp/stress-test/internal/report"
 	"github.com/JeanGrijp/stress-test/internal/runner"
 	"github.com/spf13/cobra"
 )
@@ -30,11 +27,13 @@ func NewRampCmd() *cobra.Command {
 		perStepDuration  time.Duration
 		sleepBetween     time.Duration
 		timeout          time.Duration
-		method           string
-		headers          []string
-		body             string
+		reqF             requestFlags
+		curlF            curlFlags
+		histF            historyFlags
+		outF             outFlags
 		rps              float64
 		stepRps          float64
+		thresholds       []string
 		output           string
 		outFile          string
 	)
@@ -52,8 +51,36 @@ You can choose one of three modes per phase:
 Per-phase concurrency is computed as: start + i*step for i in [0..steps-1].
 Between phases you may sleep with --sleep-between.
 
-Printed metrics include per-phase summaries and an overall final summary.
-You can export the final summary as JSON.
+Authentication (--auth-basic, --auth-bearer or --oauth2-*) is shared by all
+phases; OAuth2 tokens are cached and refreshed before they expire. Requests
+can also be signed per request with --hmac-* or --aws-sigv4, and header
+values may use per-request placeholders ({feed:column}, {jwt}, {uuid},
+{unix}, {unix_ms}) backed by --feeder and --jwt-*.
+
+--from-curl takes the request from a curl command line (or @file holding
+one), e.g. copied with "Copy as cURL" from the browser, instead of --url,
+--method and the body; --header replaces curl headers with the same name.
+--print-curl prints the configured request as a curl command and exits.
+
+Each phase reports its results on stderr as it completes; the output then
+holds a per-phase table and the overall summary. The phases are analysed for
+the saturation knee: the last phase before one where added load no longer
+turned into throughput (less than half of its relative increase) while p95
+rose by 10% or more, or the error rate by a point. You can export the results
+as JSON (overall summary, full per-phase results, the knee, per-second
+timeline and the options of the test) or as a single offline HTML page with
+charts and a per-phase table.
+For CI, --threshold sets pass/fail criteria on the overall results (e.g.
+'p95<300ms', 'error_rate<=1%'; see 'stress-test run --help') and the command
+exits with status 2 when one fails; --output junit makes the test, every
+phase, endpoint and threshold a JUnit test case and --output markdown writes
+a summary for pull request comments and $GITHUB_STEP_SUMMARY.
+Repeatable --out TYPE=PATH combines outputs in one test: any result format,
+ndjson for a per-request log and prometheus=URL for a Pushgateway (see
+'stress-test run --help').
+With --history DIR (or $STRESS_TEST_HISTORY) the result is also kept in a
+local history, labeled with --tag key=value and the git commit, to follow
+with 'stress-test history'.
 
 Important combinations:
 	- Requests mode: do not set --per-step-duration or --rps
@@ -70,14 +97,61 @@ stress-test ramp --url https://example.com --steps 2 --start-concurrency 10 \
 # Rate mode: 3 phases of 20s, start 50 rps and +25 rps per phase
 stress-test ramp --url https://example.com --steps 3 --start-concurrency 20 \
 	--step-concurrency 0 --per-step-duration 20s --rps 50 --step-rps 25 \
-	--requests-per-step 0 --output json`,
+	--requests-per-step 0 --output json
+
+# Ramp a request copied from the browser
+stress-test ramp --steps 4 --start-concurrency 10 --step-concurrency 10 \
+	--per-step-duration 30s --from-curl @checkout.curl`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// validations
-			if targetURL == "" {
-				return errors.New("--url is required")
+			if err := curlF.conflicts(cmd); err != nil {
+				return err
+			}
+			var curlReqs []curlRequest
+			if curlF.command != "" {
+				var err error
+				if curlReqs, err = curlF.load(cmd.InOrStdin(), cmd.ErrOrStderr()); err != nil {
+					return err
+				}
+				if len(curlReqs) > 1 {
+					return fmt.Errorf("--from-curl: ramp takes a single curl command, got %d", len(curlReqs))
+				}
+				targetURL, reqF.method = curlReqs[0].URL, curlReqs[0].Method
+			} else {
+				if targetURL == "" {
+					return errors.New("--url or --from-curl is required")
+				}
+				if _, err := url.ParseRequestURI(targetURL); err != nil {
+					return fmt.Errorf("invalid --url: %w", err)
+				}
+			}
+
+			if err := reqF.normalizeMethod(cmd, curlF.command != ""); err != nil {
+				return err
+			}
+			hdr, err := parseHeaderFlags(reqF.headers)
+			if err != nil {
+				return err
+			}
+			if curlF.print {
+				if curlReqs != nil {
+					writeCurl(cmd.OutOrStdout(), cmd.ErrOrStderr(), reqF.curls(curlReqs, hdr))
+				} else {
+					writeCurl(cmd.OutOrStdout(), cmd.ErrOrStderr(), []curlCommand{reqF.curl(targetURL, hdr)})
+				}
+				return nil
 			}
-			if _, err := url.ParseRequestURI(targetURL); err != nil {
-				return fmt.Errorf("invalid --url: %w", err)
+
+			outs, err := outF.parse(cmd, output, outFile)
+			if err != nil {
+				return err
+			}
+			ths, err := parseThresholds(thresholds)
+			if err != nil {
+				return err
+			}
+			if _, err := parseTags(histF.tags); err != nil {
+				return err
 			}
 			if steps <= 0 {
 				return errors.New("--steps must be > 0")
@@ -99,47 +173,36 @@ stress-test ramp --url https://example.com --steps 3 --start-concurrency 20 \
 				return errors.New("must set either --requests-per-step (>0) or --per-step-duration (>0)")
 			}
 
-			// normalize method
-			method = strings.ToUpper(strings.TrimSpace(method))
-			if method == "" {
-				method = http.MethodGet
+			var cr *curlRequest
+			if curlReqs != nil {
+				cr = &curlReqs[0]
 			}
-			switch method {
-			case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead, http.MethodOptions:
-			default:
-				return fmt.Errorf("unsupported --method: %s", method)
+			opts, _, err := reqF.options(hdr, cr, cmd.InOrStdin())
+			if err != nil {
+				return err
 			}
-
-			// parse headers "Key: Value"
-			hdr := make(http.Header)
-			for _, h := range headers {
-				parts := strings.SplitN(h, ":", 2)
-				if len(parts) != 2 {
-					return fmt.Errorf("invalid --header format (use 'Key: Value'): %q", h)
-				}
-				key := strings.TrimSpace(parts[0])
-				val := strings.TrimSpace(parts[1])
-				if key == "" {
-					return fmt.Errorf("invalid --header key in: %q", h)
-				}
-				hdr.Add(key, val)
+			if err := outs.open(cmd.OutOrStdout()); err != nil {
+				return err
 			}
-
-			opts := runner.Options{Method: method, Headers: hdr, Body: []byte(body)}
+			defer outs.close()
+			opts.Sinks = outs.sinks
 
 			overallStart := time.Now()
-			overall := runner.Report{StatusCounts: map[int]int{}}
+			overall := runner.Report{StatusCounts: map[int]int{}, Start: overallStart}
+			var phases []report.Phase
+			prepares := opts.BodyFunc != nil || len(opts.Hooks) > 0
 
 			for i := 0; i < steps; i++ {
 				concurrency := startConcurrency + i*stepConcurrency
 				ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
 				var rep runner.Report
 				var err error
+				rpsPhase := 0.0
 				if requestsPerStep > 0 {
 					fmt.Fprintf(cmd.ErrOrStderr(), "Phase %d/%d: concurrency=%d, requests=%d\n", i+1, steps, concurrency, requestsPerStep)
 					rep, err = runner.RunWithOptions(ctx, targetURL, requestsPerStep, concurrency, opts)
 				} else {
-					rpsPhase := rps + float64(i)*stepRps
+					rpsPhase = rps + float64(i)*stepRps
 					if rpsPhase > 0 {
 						fmt.Fprintf(cmd.ErrOrStderr(), "Phase %d/%d: concurrency=%d, duration=%s, rate=%.2frps\n", i+1, steps, concurrency, perStepDuration, rpsPhase)
 						rep, err = runner.RunForDurationWithRate(ctx, targetURL, perStepDuration, concurrency, opts, rpsPhase)
@@ -153,15 +216,29 @@ stress-test ramp --url https://example.com --steps 3 --start-concurrency 20 \
 					return fmt.Errorf("phase %d failed: %w", i+1, err)
 				}
 
-				// print per-phase summary
-				fmt.Fprintf(cmd.OutOrStdout(), "Phase %d: time=%s, rps=%.2f, http200=%d, errors=%d\n", i+1, rep.Duration, rep.RPS(), rep.Succeeded200, rep.Errors)
+				// progress; the phases are also part of the final output
+				fmt.Fprintf(cmd.ErrOrStderr(), "Phase %d: time=%s, rps=%.2f, http200=%d, errors=%d, p50=%s, p95=%s, p99=%s\n", i+1, rep.Duration, rep.RPS(), rep.Succeeded200, rep.Errors,
+					roundDuration(rep.Latency.Quantile(0.50)), roundDuration(rep.Latency.Quantile(0.95)), roundDuration(rep.Latency.Quantile(0.99)))
 
 				// aggregate results
-				overall.TotalRequests += rep.TotalRequests
-				overall.Succeeded200 += rep.Succeeded200
-				overall.Errors += rep.Errors
-				for code, count := range rep.StatusCounts {
-					overall.StatusCounts[code] += count
+				overall.Merge(rep)
+				phases = append(phases, report.Phase{
+					Phase:         i + 1,
+					Concurrency:   concurrency,
+					TargetRPS:     rpsPhase,
+					StartMS:       rep.Start.Sub(overallStart).Milliseconds(),
+					DurationMS:    rep.Duration.Milliseconds(),
+					TotalRequests: rep.TotalRequests,
+					RPS:           rep.RPS(),
+					HTTP200:       rep.Succeeded200,
+					Errors:        rep.Errors,
+					Unexpected:    rep.Unexpected,
+					StatusCounts:  statusCountsJSON(rep.StatusCounts),
+					Latency:       summarizeLatency(rep.Latency),
+					Endpoints:     endpointsJSON(rep),
+				})
+				if prepares {
+					phases[len(phases)-1].Prepare = summarizeLatency(rep.Prepare)
 				}
 
 				if sleepBetween > 0 && i < steps-1 {
@@ -170,89 +247,45 @@ stress-test ramp --url https://example.com --steps 3 --start-concurrency 20 \
 			}
 
 			overall.Duration = time.Since(overallStart)
+			// flush the per-request log and push the final metrics; a
+			// failure is reported once the results are written
+			sinkErr := outs.close()
 
-			switch strings.ToLower(strings.TrimSpace(output)) {
-			case "", "text":
-				// final summary (text)
-				fmt.Fprintln(cmd.OutOrStdout(), "---")
-				fmt.Fprintf(cmd.OutOrStdout(), "Overall time: %s\n", overall.Duration)
-				fmt.Fprintf(cmd.OutOrStdout(), "Total requests: %d\n", overall.TotalRequests)
-				fmt.Fprintf(cmd.OutOrStdout(), "Overall RPS: %.2f\n", overall.RPS())
-				fmt.Fprintf(cmd.OutOrStdout(), "HTTP 200: %d\n", overall.Succeeded200)
-				if len(overall.StatusCounts) > 0 {
-					fmt.Fprintln(cmd.OutOrStdout(), "Other status codes:")
-					for code, count := range overall.StatusCounts {
-						if code == 200 {
-							continue
-						}
-						fmt.Fprintf(cmd.OutOrStdout(), "- %d: %d\n", code, count)
-					}
-				}
-				if overall.Errors > 0 {
-					fmt.Fprintf(cmd.OutOrStdout(), "Errors: %d\n", overall.Errors)
-				}
-				return nil
-			case "json":
-				type jsonOut struct {
-					URL           string         `json:"url"`
-					Steps         int            `json:"steps"`
-					StartConc     int            `json:"start_concurrency"`
-					StepConc      int            `json:"step_concurrency"`
-					Mode          string         `json:"mode"`
-					PerStep       string         `json:"per_step"`
-					Method        string         `json:"method"`
-					DurationMS    int64          `json:"duration_ms"`
-					TotalRequests int            `json:"total_requests"`
-					RPS           float64        `json:"rps"`
-					HTTP200       int            `json:"http_200"`
-					Errors        int            `json:"errors"`
-					StatusCounts  map[string]int `json:"status_counts"`
-					Timestamp     string         `json:"timestamp"`
-				}
-				sc := make(map[string]int, len(overall.StatusCounts))
-				for k, v := range overall.StatusCounts {
-					sc[fmt.Sprintf("%d", k)] = v
-				}
-				mode := ""
-				per := ""
-				if requestsPerStep > 0 {
-					mode = "requests"
-					per = fmt.Sprintf("requests_per_step=%d", requestsPerStep)
-				} else if rps > 0 || stepRps > 0 {
-					mode = "duration+rate"
-					per = fmt.Sprintf("per_step_duration=%s,rps_start=%.2f,step_rps=%.2f", perStepDuration, rps, stepRps)
-				} else {
-					mode = "duration"
-					per = fmt.Sprintf("per_step_duration=%s", perStepDuration)
-				}
-				payload := jsonOut{
-					URL:           targetURL,
-					Steps:         steps,
-					StartConc:     startConcurrency,
-					StepConc:      stepConcurrency,
-					Mode:          mode,
-					PerStep:       per,
-					Method:        method,
-					DurationMS:    overall.Duration.Milliseconds(),
-					TotalRequests: overall.TotalRequests,
-					RPS:           overall.RPS(),
-					HTTP200:       overall.Succeeded200,
-					Errors:        overall.Errors,
-					StatusCounts:  sc,
-					Timestamp:     time.Now().UTC().Format(time.RFC3339),
-				}
-				data, err := json.MarshalIndent(payload, "", "  ")
-				if err != nil {
-					return err
-				}
-				if outFile != "" {
-					return os.WriteFile(outFile, data, 0644)
-				}
-				_, _ = cmd.OutOrStdout().Write(append(data, '\n'))
-				return nil
-			default:
-				return fmt.Errorf("unsupported --output: %s", output)
+			res := newResult("ramp", overall, prepares)
+			res.URL, res.Method = targetURL, reqF.method
+			res.Ramp = &report.Ramp{
+				Steps:            steps,
+				StartConcurrency: startConcurrency,
+				StepConcurrency:  stepConcurrency,
+			}
+			if requestsPerStep > 0 {
+				res.Mode = "requests"
+				res.PerStep = fmt.Sprintf("requests_per_step=%d", requestsPerStep)
+			} else if rps > 0 || stepRps > 0 {
+				res.Mode = "duration+rate"
+				res.PerStep = fmt.Sprintf("per_step_duration=%s,rps_start=%.2f,step_rps=%.2f", perStepDuration, rps, stepRps)
+			} else {
+				res.Mode = "duration"
+				res.PerStep = fmt.Sprintf("per_step_duration=%s", perStepDuration)
+			}
+			res.Phases = phases
+			res.Knee = report.FindKnee(phases)
+			res.Config = testConfig(cmd, "steps", "start-concurrency", "step-concurrency", "requests-per-step",
+				"per-step-duration", "timeout", "method")
+			if err := histF.tag(res); err != nil {
+				return err
+			}
+			res.CheckThresholds(ths)
+			if err := outs.write(cmd.OutOrStdout(), res, report.Options{}); err != nil {
+				return err
+			}
+			if err := histF.save(cmd.ErrOrStderr(), res); err != nil {
+				return err
+			}
+			if sinkErr != nil {
+				return sinkErr
 			}
+			return thresholdsError(res)
 		},
 	}
 
@@ -264,14 +297,15 @@ stress-test ramp --url https://example.com --steps 3 --start-concurrency 20 \
 	cmd.Flags().DurationVar(&perStepDuration, "per-step-duration", 0, "Per-phase duration (alternative to requests-per-step)")
 	cmd.Flags().DurationVar(&sleepBetween, "sleep-between", 0, "Sleep duration between phases")
 	cmd.Flags().DurationVar(&timeout, "timeout", 60*time.Second, "Per-phase timeout")
-	cmd.Flags().StringVar(&method, "method", http.MethodGet, "HTTP method (GET, POST, PUT, PATCH, DELETE, HEAD, OPTIONS)")
-	cmd.Flags().StringArrayVar(&headers, "header", nil, "HTTP header in 'Key: Value' format (repeatable)")
-	cmd.Flags().StringVar(&body, "body", "", "HTTP request body (string)")
+	reqF.register(cmd)
+	curlF.register(cmd)
+	histF.register(cmd)
+	outF.register(cmd)
 	cmd.Flags().Float64Var(&rps, "rps", 0, "Target requests per second per phase (requires --per-step-duration)")
 	cmd.Flags().Float64Var(&stepRps, "step-rps", 0, "RPS increment per phase")
-	cmd.Flags().StringVar(&output, "output", "text", "Output format: text|json")
-	cmd.Flags().StringVar(&outFile, "out-file", "", "Write final summary to file (only for --output=json by default)")
-	_ = cmd.MarkFlagRequired("url")
+	cmd.Flags().StringArrayVar(&thresholds, "threshold", nil, "Pass/fail criterion such as 'p95<300ms', 'error_rate<1%' or 'rps>=500' (repeatable)")
+	cmd.Flags().StringVar(&output, "output", "text", "Output format: text|json|markdown|csv|junit|html")
+	cmd.Flags().StringVar(&outFile, "out-file", "", "Write the results to file instead of stdout")
 
 	return cmd
 }
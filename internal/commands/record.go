@@ -0,0 +1,156 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/JeanGrijp/stress-test/internal/scenario"
+	"github.com/JeanGrijp/stress-test/internal/server"
+	"github.com/spf13/cobra"
+)
+
+// NewRecordCmd starts a capturing proxy that records traffic to a scenario file.
+// Example:
+//
+//	stress-test record --out session.jsonl
+func NewRecordCmd() *cobra.Command {
+	var (
+		addr        string
+		outPath     string
+		target      string
+		dropHeaders []string
+		maxBodySize string
+		quiet       bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "record",
+		Short: "Record HTTP traffic through a local proxy into a scenario file",
+		Long: `Start a local HTTP proxy that forwards traffic and records every request
+(method, URL, headers, body and timing) to a JSONL scenario file that
+'stress-test replay' turns into a load test.
+
+Two modes:
+	forward proxy  point clients at it with HTTP_PROXY=http://ADDR (or a
+	               browser proxy setting); plain HTTP requests are recorded
+	--target URL   act as the target itself: send requests to
+	               http://ADDR/path and they are forwarded to URL/path;
+	               use this for HTTPS services
+
+HTTPS requests sent through the forward proxy are tunneled (CONNECT) and
+cannot be recorded without intercepting TLS; they are reported on stderr.
+
+Flags overview:
+	--addr           Address to listen on (default 127.0.0.1:8888)
+	--out            Scenario file to write (required, '-' for stdout)
+	--target         Reverse-proxy mode: forward to this base URL
+	--drop-header    Repeatable header name not to record (e.g. Cookie)
+	--max-body-size  Larger request bodies are forwarded but not recorded (default 10MiB)
+	--quiet          Do not log each recorded request
+
+Each line of the file is one request:
+	{"method":"POST","url":"http://api.local/items","headers":{...},"body":"...","offset_ms":120.5}
+Binary bodies are stored base64 encoded ("body_encoding":"base64"). Stop
+recording with Ctrl+C.`,
+		Example: `# Record a CLI or test suite through the forward proxy
+stress-test record --out session.jsonl &
+HTTP_PROXY=http://127.0.0.1:8888 ./integration-tests.sh
+
+# Record HTTPS traffic by pointing the client at the recorder instead
+stress-test record --target https://api.example.com --out session.jsonl --drop-header Cookie
+curl http://127.0.0.1:8888/v1/items
+
+# Replay it at 10x the original pace
+stress-test replay session.jsonl --speed 10x`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if outPath == "" {
+				return errors.New("--out is required")
+			}
+			var targetURL *url.URL
+			if target != "" {
+				u, err := url.Parse(target)
+				if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+					return fmt.Errorf("invalid --target (use http(s)://host[:port]): %q", target)
+				}
+				targetURL = u
+			}
+			maxBody, err := parseSizeFlag("--max-body-size", maxBodySize)
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			if outPath != "-" {
+				f, err := os.Create(outPath)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				out = f
+			}
+
+			logw := cmd.ErrOrStderr()
+			var (
+				mu       sync.Mutex
+				recorded int
+				tunneled = make(map[string]bool)
+			)
+			c := &scenario.Capture{
+				Out:         scenario.NewWriter(out),
+				Target:      targetURL,
+				MaxBodySize: maxBody,
+				DropHeaders: dropHeaders,
+				OnRecord: func(r scenario.Request) {
+					mu.Lock()
+					defer mu.Unlock()
+					recorded++
+					if !quiet {
+						fmt.Fprintf(logw, "%8.0fms  %s %s\n", r.OffsetMS, r.Method, r.URL)
+					}
+				},
+				OnTunnel: func(host string) {
+					mu.Lock()
+					defer mu.Unlock()
+					if !tunneled[host] {
+						tunneled[host] = true
+						fmt.Fprintf(logw, "warning: HTTPS to %s is tunneled and not recorded (use --target)\n", host)
+					}
+				},
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			err = server.Serve(ctx, server.ListenConfig{Addr: addr}, c, func(bound string) {
+				if targetURL != nil {
+					fmt.Fprintf(logw, "Recording http://%s -> %s (Ctrl+C to stop)\n", bound, targetURL)
+				} else {
+					fmt.Fprintf(logw, "Recording proxy on http://%s, set HTTP_PROXY=http://%s (Ctrl+C to stop)\n", bound, bound)
+				}
+			})
+			if err != nil {
+				return err
+			}
+			if err := c.Err(); err != nil {
+				return fmt.Errorf("writing %s: %w", outPath, err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			fmt.Fprintf(logw, "Recorded %d requests to %s\n", recorded, outPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&addr, "addr", "127.0.0.1:8888", "Address to listen on")
+	cmd.Flags().StringVar(&outPath, "out", "", "Scenario file to write ('-' for stdout)")
+	cmd.Flags().StringVar(&target, "target", "", "Reverse-proxy mode: forward requests to this base URL")
+	cmd.Flags().StringArrayVar(&dropHeaders, "drop-header", nil, "Header name not to record (repeatable)")
+	cmd.Flags().StringVar(&maxBodySize, "max-body-size", "10MiB", "Largest request body to record")
+	cmd.Flags().BoolVar(&quiet, "quiet", false, "Do not log each recorded request")
+	return cmd
+}
@@ -0,0 +1,244 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JeanGrijp/stress-test/internal/feeder"
+	"github.com/JeanGrijp/stress-test/internal/report"
+	"github.com/JeanGrijp/stress-test/internal/runner"
+	"github.com/JeanGrijp/stress-test/internal/scenario"
+	"github.com/spf13/cobra"
+)
+
+// NewReplayCmd replays a recorded scenario file as a load test.
+// Example:
+//
+//	stress-test replay session.jsonl --speed 2x
+func NewReplayCmd() *cobra.Command {
+	return newReplayCmd(scenario.Load)
+}
+
+// newReplayCmd builds the replay command around load, which reads the
+// requests of its FILE argument; openapi --run passes the generated ones.
+func newReplayCmd(load func(path string, stdin io.Reader) ([]scenario.Request, error)) *cobra.Command {
+	var (
+		speed       string
+		concurrency int
+		loops       int
+		timeout     time.Duration
+		baseURL     string
+		headers     []string
+		feederPath  string
+		feederMode  string
+		outF        outFlags
+		thresholds  []string
+		output      string
+		outFile     string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "replay FILE",
+		Short: "Replay a recorded scenario file as a load test",
+		Long: `Replay the requests of a scenario file (written by 'stress-test record')
+and report the results like 'run'.
+
+Pacing (--speed):
+	1        original pacing: each request is sent at its recorded offset
+	2x, 10x  the same sequence, compressed 2 or 10 times
+	max      ignore timing and send as fast as --concurrency allows
+
+Flags overview:
+	--speed        Replay speed: a factor (1, 2x, 0.5) or 'max' (default 1)
+	--concurrency  Maximum requests in flight (default 50)
+	--loops        Repeat the recording N times (default 1)
+	--timeout      Overall limit (default none)
+	--base-url     Send every request to this scheme://host[/prefix] instead
+	--header       Repeatable 'Key: Value' added to or overriding recorded headers
+	--feeder       CSV/JSON data file for {feed:column} placeholders in URLs and headers
+	--threshold    Repeatable pass/fail criterion, e.g. 'p95<300ms' (exit status 2)
+	--output       text|json|markdown|csv|junit|html (default text)
+	--out-file     Write the output to a file instead of stdout
+	--out          Repeatable extra output TYPE=PATH, as in run
+
+Scenario URLs and header values may contain {feed:column}, {uuid}, {unix}
+and {unix_ms} placeholders (see 'stress-test openapi'), rendered per request.
+Values substituted into URLs are path-escaped.
+
+Results, thresholds and --out outputs (result formats, an ndjson
+per-request log, prometheus=URL) work as in 'stress-test run'.
+
+In paced modes a request waits for a free worker when all --concurrency
+workers are busy, so a slow target stretches the replay.`,
+		Example: `# Replay at the recorded pace
+stress-test replay session.jsonl
+
+# Ten times faster, three times in a row, against staging
+stress-test replay session.jsonl --speed 10x --loops 3 --base-url https://staging.example.com
+
+# Maximum throughput with 100 workers and a fresh token
+stress-test replay session.jsonl --speed max --concurrency 100 \
+	--header "Authorization: Bearer $TOKEN" --output json
+
+# Gate CI on the replay and keep a per-request log
+stress-test replay session.jsonl --threshold 'p95<300ms' --output junit \
+	--out-file replay.xml --out ndjson=requests.ndjson`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			outs, err := outF.parse(cmd, output, outFile)
+			if err != nil {
+				return err
+			}
+			ths, err := parseThresholds(thresholds)
+			if err != nil {
+				return err
+			}
+			factor, paced, err := parseSpeed(speed)
+			if err != nil {
+				return err
+			}
+			if concurrency <= 0 {
+				return errors.New("--concurrency must be > 0")
+			}
+			if loops <= 0 {
+				return errors.New("--loops must be > 0")
+			}
+			hdr, err := parseHeaderFlags(headers)
+			if err != nil {
+				return err
+			}
+
+			reqs, err := load(args[0], cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+			if len(reqs) == 0 {
+				return fmt.Errorf("%s: no requests", args[0])
+			}
+			if baseURL != "" {
+				if err := rebaseRequests(reqs, baseURL); err != nil {
+					return err
+				}
+			}
+			var feed *feeder.Feeder
+			if feederPath != "" {
+				if feed, err = feeder.Load(feederPath, feederMode); err != nil {
+					return err
+				}
+			}
+			if err := outs.open(cmd.OutOrStdout()); err != nil {
+				return err
+			}
+			defer outs.close()
+			steps, err := scenario.Steps(reqs, runner.Options{Headers: hdr, Sinks: outs.sinks}, factor, feed)
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+			rep, err := runner.RunSteps(ctx, steps, concurrency, paced, loops)
+			if err != nil {
+				return err
+			}
+			// flush the per-request log and push the final metrics; a
+			// failure is reported once the results are written
+			sinkErr := outs.close()
+
+			res := newResult("replay", rep, false)
+			res.Source = args[0]
+			res.Config = testConfig(cmd, "concurrency", "speed")
+			res.CheckThresholds(ths)
+			if err := outs.write(cmd.OutOrStdout(), res, report.Options{TopEndpoints: 0}); err != nil {
+				return err
+			}
+			if sinkErr != nil {
+				return sinkErr
+			}
+			return thresholdsError(res)
+		},
+	}
+
+	cmd.Flags().StringVar(&speed, "speed", "1", "Replay speed: factor (1, 2x, 0.5) or 'max'")
+	cmd.Flags().IntVar(&concurrency, "concurrency", 50, "Maximum requests in flight")
+	cmd.Flags().IntVar(&loops, "loops", 1, "Number of times to replay the recording")
+	cmd.Flags().DurationVar(&timeout, "timeout", 0, "Overall replay timeout (0 = none)")
+	cmd.Flags().StringVar(&baseURL, "base-url", "", "Send requests to this scheme://host[/prefix] instead of the recorded one")
+	cmd.Flags().StringArrayVar(&headers, "header", nil, "HTTP header in 'Key: Value' format, overrides recorded headers (repeatable)")
+	cmd.Flags().StringVar(&feederPath, "feeder", "", "Data feeder file (.csv, .json, .jsonl) used by {feed:column} placeholders")
+	cmd.Flags().StringVar(&feederMode, "feeder-mode", "sequential", "Feeder row selection: sequential|random")
+	outF.register(cmd)
+	cmd.Flags().StringArrayVar(&thresholds, "threshold", nil, "Pass/fail criterion such as 'p95<300ms', 'error_rate<1%' or 'rps>=500' (repeatable)")
+	cmd.Flags().StringVar(&output, "output", "text", "Output format: text|json|markdown|csv|junit|html")
+	cmd.Flags().StringVar(&outFile, "out-file", "", "Write the output to file instead of stdout")
+	return cmd
+}
+
+// parseSpeed parses --speed: "max" disables pacing, otherwise a positive
+// factor with an optional "x" suffix.
+func parseSpeed(s string) (factor float64, paced bool, err error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "max" {
+		return 0, false, nil
+	}
+	f, err := strconv.ParseFloat(strings.TrimSuffix(s, "x"), 64)
+	if err != nil || f <= 0 {
+		return 0, false, fmt.Errorf("invalid --speed %q (use a factor like 1, 2x, 0.5 or 'max')", s)
+	}
+	return f, true, nil
+}
+
+// parseHeaderFlags parses repeatable 'Key: Value' flags.
+func parseHeaderFlags(headers []string) (http.Header, error) {
+	hdr := make(http.Header)
+	for _, h := range headers {
+		key, val, ok := strings.Cut(h, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid --header format (use 'Key: Value'): %q", h)
+		}
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("invalid --header key in: %q", h)
+		}
+		hdr.Add(key, strings.TrimSpace(val))
+	}
+	return hdr, nil
+}
+
+// rebaseRequests points every request at base, keeping its path and query.
+// A path in base is used as a prefix. URLs are handled as strings so
+// placeholders such as {feed:id} are not escaped.
+func rebaseRequests(reqs []scenario.Request, base string) error {
+	b, err := url.Parse(base)
+	if err != nil || (b.Scheme != "http" && b.Scheme != "https") || b.Host == "" {
+		return fmt.Errorf("invalid --base-url (use http(s)://host[:port][/prefix]): %q", base)
+	}
+	prefix := b.Scheme + "://" + b.Host + strings.TrimSuffix(b.EscapedPath(), "/")
+	for i := range reqs {
+		target := reqs[i].URL
+		if k := strings.Index(target, "://"); k >= 0 {
+			rest := target[k+3:]
+			if j := strings.IndexAny(rest, "/?#"); j >= 0 {
+				target = rest[j:]
+			} else {
+				target = "/"
+			}
+		}
+		if !strings.HasPrefix(target, "/") {
+			target = "/" + target
+		}
+		reqs[i].URL = prefix + target
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/JeanGrijp/stress-test/internal/report"
+	"github.com/JeanGrijp/stress-test/internal/runner"
+	"github.com/JeanGrijp/stress-test/internal/scenario"
+	"github.com/spf13/cobra"
+)
+
+// NewReplayLogCmd replays requests parsed from web server access logs.
+// Example:
+//
+//	stress-test replay-log access.log --base-url https://staging.example.com --speed 10x
+func NewReplayLogCmd() *cobra.Command {
+	var (
+		baseURL     string
+		format      string
+		speed       string
+		concurrency int
+		timeout     time.Duration
+		rewrites    []string
+		headers     []string
+		methods     []string
+		limit       int
+		top         int
+		outF        outFlags
+		thresholds  []string
+		output      string
+		outFile     string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "replay-log FILE",
+		Short: "Replay requests from nginx/Apache/JSON access logs against a base URL",
+		Long: `Replay the requests recorded in a web server access log against --base-url,
+keeping their relative timing, and report latency per path pattern.
+
+Formats (--format):
+	combined  nginx default and Apache combined log format
+	common    Apache common log format
+	json      one JSON object per line with time/timestamp, method and
+	          path/uri (or a "request" line such as "GET /x HTTP/1.1")
+	auto      JSON lines are parsed as json, others as common/combined
+
+Logs only carry the method, path and query (and the user agent in combined
+logs), so requests are replayed without bodies. Entries are ordered by
+timestamp; entries within the same second are spread evenly over it. The
+replay starts with the first entry kept by --method and --limit.
+
+Results are grouped by path pattern: the query is dropped and numeric, UUID
+and long hex segments become {id}, {uuid} and {hex}.
+
+Flags overview:
+	--base-url     Target scheme://host[/prefix] (required)
+	--format       auto|combined|common|json (default auto)
+	--speed        Replay speed: a factor (1, 10x, 0.5) or 'max' (default 1)
+	--concurrency  Maximum requests in flight (default 50)
+	--timeout      Overall limit (default none)
+	--rewrite      Repeatable 'REGEX=>REPLACEMENT' applied to each path+query
+	--header       Repeatable 'Key: Value' added to every request
+	--method       Repeatable method filter (e.g. GET)
+	--limit        Replay at most N entries (0 = all)
+	--top          Path patterns shown in text output (default 20, 0 = all)
+	--threshold    Repeatable pass/fail criterion, e.g. 'p95<300ms' (exit status 2)
+	--output       text|json|markdown|csv|junit|html (default text)
+	--out-file     Write the output to a file instead of stdout
+	--out          Repeatable extra output TYPE=PATH, as in run
+
+Results, thresholds and --out outputs (result formats, an ndjson
+per-request log, prometheus=URL) work as in 'stress-test run'.`,
+		Example: `# Replay an hour of production GET traffic against staging, 10x faster
+stress-test replay-log access.log --base-url https://staging.example.com \
+	--method GET --speed 10x --header 'X-Load-Test: 1'
+
+# Move an API version and replay JSON logs as fast as possible
+stress-test replay-log app.jsonl --format json --base-url http://127.0.0.1:8080 \
+	--rewrite '^/api/v1/=>/api/v2/' --speed max --concurrency 100
+
+# Read from stdin
+zcat access.log.gz | stress-test replay-log - --base-url https://staging.example.com
+
+# Gate CI on replayed traffic and write an HTML report
+stress-test replay-log access.log --base-url https://staging.example.com --speed max \
+	--threshold 'p95<300ms' --threshold 'error_rate<1%' --out html=replay.html`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			outs, err := outF.parse(cmd, output, outFile)
+			if err != nil {
+				return err
+			}
+			ths, err := parseThresholds(thresholds)
+			if err != nil {
+				return err
+			}
+			if baseURL == "" {
+				return errors.New("--base-url is required")
+			}
+			factor, paced, err := parseSpeed(speed)
+			if err != nil {
+				return err
+			}
+			if concurrency <= 0 {
+				return errors.New("--concurrency must be > 0")
+			}
+			hdr, err := parseHeaderFlags(headers)
+			if err != nil {
+				return err
+			}
+			type rewrite struct {
+				re   *regexp.Regexp
+				repl string
+			}
+			var rws []rewrite
+			for _, r := range rewrites {
+				from, to, ok := strings.Cut(r, "=>")
+				if !ok {
+					return fmt.Errorf("invalid --rewrite (use 'REGEX=>REPLACEMENT'): %q", r)
+				}
+				re, err := regexp.Compile(from)
+				if err != nil {
+					return fmt.Errorf("invalid --rewrite regex %q: %w", from, err)
+				}
+				rws = append(rws, rewrite{re, to})
+			}
+
+			all, stats, err := scenario.LoadAccessLog(args[0], cmd.InOrStdin(), strings.ToLower(strings.TrimSpace(format)))
+			if err != nil {
+				return err
+			}
+			if stats.Skipped > 0 {
+				fmt.Fprintf(cmd.ErrOrStderr(), "warning: skipped %d of %d lines that could not be parsed\n", stats.Skipped, stats.Lines)
+			}
+			reqs := all[:0]
+			for _, r := range all {
+				if len(methods) > 0 && !containsFold(methods, r.Method) {
+					continue
+				}
+				// group by the original path, before any rewrite
+				r.Label = scenario.PathPattern(r.URL)
+				for _, rw := range rws {
+					r.URL = rw.re.ReplaceAllString(r.URL, rw.repl)
+				}
+				reqs = append(reqs, r)
+				if limit > 0 && len(reqs) == limit {
+					break
+				}
+			}
+			if len(reqs) == 0 {
+				return fmt.Errorf("%s: no requests to replay", args[0])
+			}
+			// the replay starts with the first kept entry, not the first line
+			start := reqs[0].OffsetMS
+			for i := range reqs {
+				reqs[i].OffsetMS -= start
+			}
+			if err := rebaseRequests(reqs, baseURL); err != nil {
+				return err
+			}
+			if err := outs.open(cmd.OutOrStdout()); err != nil {
+				return err
+			}
+			defer outs.close()
+			steps, err := scenario.Steps(reqs, runner.Options{Headers: hdr, Sinks: outs.sinks}, factor, nil)
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+			rep, err := runner.RunSteps(ctx, steps, concurrency, paced, 1)
+			if err != nil {
+				return err
+			}
+			// flush the per-request log and push the final metrics; a
+			// failure is reported once the results are written
+			sinkErr := outs.close()
+
+			res := newResult("replay-log", rep, false)
+			res.Source = args[0]
+			res.Config = testConfig(cmd, "concurrency", "speed")
+			res.CheckThresholds(ths)
+			if err := outs.write(cmd.OutOrStdout(), res, report.Options{TopEndpoints: top}); err != nil {
+				return err
+			}
+			if sinkErr != nil {
+				return sinkErr
+			}
+			return thresholdsError(res)
+		},
+	}
+
+	cmd.Flags().StringVar(&baseURL, "base-url", "", "Target scheme://host[/prefix] the logged paths are sent to")
+	cmd.Flags().StringVar(&format, "format", scenario.LogAuto, "Log format: auto|combined|common|json")
+	cmd.Flags().StringVar(&speed, "speed", "1", "Replay speed: factor (1, 10x, 0.5) or 'max'")
+	cmd.Flags().IntVar(&concurrency, "concurrency", 50, "Maximum requests in flight")
+	cmd.Flags().DurationVar(&timeout, "timeout", 0, "Overall replay timeout (0 = none)")
+	cmd.Flags().StringArrayVar(&rewrites, "rewrite", nil, "Rewrite path+query with 'REGEX=>REPLACEMENT' (repeatable, applied in order)")
+	cmd.Flags().StringArrayVar(&headers, "header", nil, "HTTP header in 'Key: Value' format added to every request (repeatable)")
+	cmd.Flags().StringArrayVar(&methods, "method", nil, "Replay only this method (repeatable)")
+	cmd.Flags().IntVar(&limit, "limit", 0, "Replay at most N log entries (0 = all)")
+	cmd.Flags().IntVar(&top, "top", 20, "Number of path patterns shown in text output (0 = all)")
+	outF.register(cmd)
+	cmd.Flags().StringArrayVar(&thresholds, "threshold", nil, "Pass/fail criterion such as 'p95<300ms', 'error_rate<1%' or 'rps>=500' (repeatable)")
+	cmd.Flags().StringVar(&output, "output", "text", "Output format: text|json|markdown|csv|junit|html")
+	cmd.Flags().StringVar(&outFile, "out-file", "", "Write the output to file instead of stdout")
+	return cmd
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(strings.TrimSpace(v), s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/JeanGrijp/stress-test/internal/report"
+	"github.com/spf13/cobra"
+)
+
+// NewReportCmd re-renders a result saved with --output json.
+// Example:
+//
+//	stress-test report result.json --out-file report.html
+func NewReportCmd() *cobra.Command {
+	var (
+		output  string
+		outFile string
+		top     int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "report FILE",
+		Short: "Render a saved JSON result as text, Markdown, CSV, HTML or JUnit",
+		Long: `Render a result saved with 'run', 'ramp', 'replay' or 'replay-log'
+--output json in another format, without running the test again.
+
+Formats (--output):
+	text      the summary printed by the test commands
+	json      the result document itself, upgraded to the current version
+	markdown  tables for pull requests, wikis and CI job summaries
+	csv       one row for the total, each phase and each endpoint
+	html      a single offline page with charts of throughput, latency
+	          percentiles and error rate over time
+	junit     JUnit XML: the total, each phase and each endpoint are test
+	          cases, failing on transport errors, 5xx or unexpected statuses
+
+When --output is not set the format follows the --out-file extension (.txt,
+.json, .md, .csv, .html, .xml), and is text otherwise. Charts need the
+per-second timeline written by this version of stress-test; older results
+are rendered without them.
+
+Flags overview:
+	--output     text|json|markdown|csv|html|junit (default text)
+	--out-file   Write the report to a file instead of stdout
+	--top        Endpoints shown in text and Markdown (default 20, 0 = all)
+
+FILE may be '-' to read the result from stdin.`,
+		Example: `# Save a result, then turn it into a page to share
+stress-test run --url https://example.com --requests 5000 --concurrency 50 \
+	--output json --out-file result.json
+stress-test report result.json --out-file report.html
+
+# Post the result of a CI job as Markdown
+stress-test report result.json --output markdown >> "$GITHUB_STEP_SUMMARY"
+
+# Straight from a pipe
+stress-test ramp --url https://example.com --steps 4 --per-step-duration 30s \
+	--requests-per-step 0 --output json | stress-test report - --output html > ramp.html`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !cmd.Flags().Changed("output") && outFile != "" {
+				if f := report.FormatForFile(outFile); f != "" {
+					output = f
+				}
+			}
+			format, err := outputFormat(output, append(report.Formats, "md", "xml")...)
+			if err != nil {
+				return err
+			}
+			if top < 0 {
+				return errors.New("--top must be >= 0")
+			}
+			res, err := readResult(cmd.InOrStdin(), args[0])
+			if err != nil {
+				return err
+			}
+			res.Version = report.SchemaVersion
+			return writeResult(cmd.OutOrStdout(), outFile, format, res, report.Options{TopEndpoints: top})
+		},
+	}
+
+	cmd.Flags().StringVar(&output, "output", "text", "Output format: text|json|markdown|csv|html|junit")
+	cmd.Flags().StringVar(&outFile, "out-file", "", "Write the report to file instead of stdout")
+	cmd.Flags().IntVar(&top, "top", 20, "Number of endpoints shown in text and Markdown output (0 = all)")
+	return cmd
+}
+
+// readResult reads the result saved at path, or from stdin when path is
+// "-".
+func readResult(stdin io.Reader, path string) (*report.Result, error) {
+	in := stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		in = f
+	}
+	res, err := report.Read(in)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return res, nil
+}
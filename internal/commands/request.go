@@ -0,0 +1,100 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/JeanGrijp/stress-test/internal/runner"
+	"github.com/spf13/cobra"
+)
+
+// requestFlags describe the request a load test sends to --url: method,
+// headers, body or form, and the authentication, signing and template
+// flags applied to it.
+type requestFlags struct {
+	method       string
+	headers      []string
+	body         string
+	bodyFile     string
+	forms        []formArg
+	formEncoding string
+	auth         authFlags
+	sign         signFlags
+	tmpl         templateFlags
+}
+
+func (f *requestFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.method, "method", http.MethodGet, "HTTP method (GET, POST, PUT, PATCH, DELETE, HEAD, OPTIONS)")
+	cmd.Flags().StringArrayVar(&f.headers, "header", nil, "HTTP header in 'Key: Value' format (repeatable)")
+	cmd.Flags().StringVar(&f.body, "body", "", "HTTP request body (string, @file or @- for stdin)")
+	cmd.Flags().StringVar(&f.bodyFile, "body-file", "", "Read the HTTP request body from file ('-' for stdin)")
+	cmd.Flags().Var(&formValue{args: &f.forms}, "form", "Form part 'name=value', 'name=<file', 'name=@file[;type=...][;filename=...]' or 'name=@random:SIZE' (repeatable)")
+	cmd.Flags().Var(&formValue{args: &f.forms, literal: true}, "form-string", "Literal form field 'name=value' (repeatable)")
+	cmd.Flags().StringVar(&f.formEncoding, "form-encoding", "multipart", "Form encoding: multipart|urlencoded")
+	f.auth.register(cmd)
+	f.sign.register(cmd)
+	f.tmpl.register(cmd)
+}
+
+// normalizeMethod upper-cases --method, defaulting to POST for forms like
+// curl -F. Methods taken from curl commands (fromCurl) are not checked.
+func (f *requestFlags) normalizeMethod(cmd *cobra.Command, fromCurl bool) error {
+	if len(f.forms) > 0 && !cmd.Flags().Changed("method") {
+		f.method = http.MethodPost
+	}
+	f.method = strings.ToUpper(strings.TrimSpace(f.method))
+	if f.method == "" {
+		f.method = http.MethodGet
+	}
+	if fromCurl {
+		return nil
+	}
+	switch f.method {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead, http.MethodOptions:
+		return nil
+	}
+	return fmt.Errorf("unsupported --method: %s", f.method)
+}
+
+// curl returns the request to target as a curl command for --print-curl.
+func (f *requestFlags) curl(target string, hdr http.Header) curlCommand {
+	return requestCurl(f.method, target, hdr, f.body, f.bodyFile, f.forms, f.formEncoding, &f.auth, &f.sign, &f.tmpl)
+}
+
+// curls returns --from-curl requests as curl commands for --print-curl,
+// with the other flags applied.
+func (f *requestFlags) curls(reqs []curlRequest, hdr http.Header) []curlCommand {
+	return curlCommands(reqs, hdr, &f.auth, &f.sign, &f.tmpl)
+}
+
+// options builds the runner options of the request. The body is loaded
+// once and shared by all workers; forms are built per request. cr, when
+// set, is a single --from-curl request whose headers, body, credentials and
+// signers are merged in. The hook pipeline is also returned for the
+// requests of a --from-curl mix.
+func (f *requestFlags) options(hdr http.Header, cr *curlRequest, stdin io.Reader) (runner.Options, hookPipeline, error) {
+	payload, err := loadBody(f.body, f.bodyFile, stdin)
+	if err != nil {
+		return runner.Options{}, hookPipeline{}, err
+	}
+	formBody, err := buildFormBody(f.forms, f.formEncoding, stdin)
+	if err != nil {
+		return runner.Options{}, hookPipeline{}, err
+	}
+	if formBody != nil && payload != nil {
+		return runner.Options{}, hookPipeline{}, errors.New("--form cannot be combined with --body or --body-file")
+	}
+
+	if cr != nil {
+		hdr, payload, formBody = mergeHeaders(cr.Headers, hdr), cr.Body, cr.BodyFunc
+	}
+	hdr, pipe, err := requestPipeline(hdr, &f.tmpl, &f.auth, &f.sign, stdin)
+	if err != nil {
+		return runner.Options{}, hookPipeline{}, err
+	}
+	opts := runner.Options{Method: f.method, Headers: hdr, Body: payload, BodyFunc: formBody, Hooks: pipe.hooks(cr)}
+	return opts, pipe, nil
+}
@@ -2,15 +2,12 @@ package commands
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
 	"net/url"
-	"os"
-	"strings"
 	"time"
 
+	"github.com/JeanGrijp/stress-test/internal/report"
 	"github.com/JeanGrijp/stress-test/internal/runner"
 	"github.com/spf13/cobra"
 )
@@ -18,39 +15,129 @@ import (
 // NewRunCmd returns the `run` subcommand to execute a simple HTTP load test.
 func NewRunCmd() *cobra.Command {
 	var (
-		targetURL   string
-		total       int
-		concurrency int
-		timeout     time.Duration
-		method      string
-		headers     []string
-		body        string
-		output      string
-		outFile     string
+		targetURL       string
+		total           int
+		duration        time.Duration
+		concurrency     int
+		timeout         time.Duration
+		window          time.Duration
+		checkpoint      string
+		checkpointEvery time.Duration
+		failOnDrift     bool
+		reqF            requestFlags
+		harF            harFlags
+		curlF           curlFlags
+		histF           historyFlags
+		outF            outFlags
+		thresholds      []string
+		output          string
+		outFile         string
 	)
 
 	cmd := &cobra.Command{
 		Use:   "run",
 		Short: "Run a load test against a target URL",
-		Long: `Run a fixed number of HTTP requests with a given concurrency.
+		Long: `Run a fixed number of HTTP requests, or requests for a fixed duration,
+with a given concurrency.
 
 By default, requests use GET and no body. You can change the method, add
 headers, or send a body. Results can be printed in human-readable text or
 exported as JSON for automation.
 
 Key metrics: total time, total requests, requests/sec (RPS), 200 OK count,
-per-status counts and error count.
+per-status counts, error count and latency percentiles. When requests are
+generated per request (forms, templates, JWTs, signatures), the time spent
+preparing them is reported separately and excluded from latency.
+
+JSON output also holds a timeline of throughput, latency and errors and
+the options of the test (credentials masked); --output html draws it as
+an offline page with charts.
+
+For CI, --threshold sets pass/fail criteria on the totals and the
+command exits with status 2 when one fails. --output junit and markdown
+suit test reports and job summaries, and --history keeps the result in a
+local history for 'stress-test history'.
+
+--duration runs for a fixed time instead, up to soak tests of many hours
+in bounded memory. At the end, drift detection fits a trend to p95,
+error rate and throughput and flags those that degrade significantly.
+
+Header values may contain placeholders rendered per request:
+{feed:column} (a column of the --feeder row), {jwt} (a token minted with
+--jwt-key), {uuid}, {unix} and {unix_ms}.
 
 Flags overview:
-	--url            Target URL (required)
-	--requests       Total number of requests (required)
+	--url            Target URL (required unless --har or --from-curl is set)
+	--requests       Total number of requests (or --duration)
+	--duration       Run for this long instead of --requests, e.g. 8h
 	--concurrency    Number of worker goroutines (default 10)
-	--timeout        Overall test timeout
+	--timeout        Overall test timeout (default 60s; none with --duration)
+	--window         Timeline window of a --duration test (default automatic)
+	--checkpoint     Save the JSON result so far to a file while the test runs
+	--checkpoint-every  How often to save --checkpoint (default 5m)
+	--fail-on-drift  Exit with status 2 when a trend degrades
 	--method         HTTP method (GET, POST, PUT, PATCH, DELETE, HEAD, OPTIONS)
 	--header         Repeatable HTTP header in 'Key: Value' format
-	--body           Request body (string)
-	--output         text|json (default text)
-	--out-file       If set with --output=json, write JSON to file`,
+	--body           Request body (string, @file or @- to read stdin)
+	--body-file      Read the request body from a file ('-' for stdin)
+	--form           Repeatable form part (see --form-encoding)
+	--form-string    Repeatable literal form field 'name=value'
+	--form-encoding  multipart|urlencoded (default multipart)
+	--auth-basic     HTTP basic auth 'user:password'
+	--auth-bearer    Static bearer token (or @file)
+	--oauth2-*       OAuth2 client-credentials: token URL, client ID/secret,
+	                 scopes; the token is cached, shared and refreshed
+	--hmac-*         HMAC-SHA256 request signing
+	--aws-sigv4      AWS SigV4 request signing ('aws:amz:REGION:SERVICE')
+	--feeder         CSV/JSON data file for {feed:column} placeholders
+	--jwt-*          Mint a JWT per request (HS256/RS256/ES256)
+	--har            Use the requests of a HAR file instead of --url
+	--har-mode       weighted|flow (default weighted, see below)
+	--har-domain     Repeatable domain filter (subdomains included)
+	--har-method     Repeatable method filter
+	--har-include-static  Keep images, fonts, CSS, JS and media
+	--har-timing     In flow mode, keep the recorded gaps between requests
+	--from-curl      Take the request from a curl command (or @file)
+	--print-curl     Print the configured request as a curl command and exit
+	--threshold      Repeatable pass/fail criterion, e.g. 'p95<300ms'
+	--output         text|json|markdown|csv|junit|html (default text)
+	--out-file       Write the output to a file instead of stdout
+	--out            Repeatable extra output 'TYPE=PATH' (see below)
+	--log-max-size   Rotate the --out ndjson file at this size, e.g. 100MiB
+	--log-keep       Rotated --out ndjson files to keep (default 5)
+	--history        Also save the result in this history directory
+	                 (default $STRESS_TEST_HISTORY, see 'stress-test history')
+	--tag            Repeatable 'key=value' label of the saved result
+
+HAR files (browser "Save all as HAR") replace --url, --method and the body:
+	weighted  identical requests are merged and each of the --requests picks
+	          one at random in proportion to how often it was recorded
+	flow      each of --concurrency virtual users runs the whole session in
+	          order; --requests is the number of sessions to run
+--header and the auth, signing and template flags apply to every entry.
+
+--from-curl takes a curl command line, e.g. one copied with "Copy as cURL"
+from the browser developer tools, instead of --url, --method and the body.
+The curl flags understood by 'stress-test curl' are supported, including
+quoting, line continuations and $'...' strings. With @file (or @- for
+stdin) the file may hold several curl commands; each of the --requests then
+picks one of them at random and results are also reported per command.
+--header replaces curl headers with the same name, and the auth, signing and
+template flags apply to every command: auth flags replace its credentials
+and signing flags sign after its own signers.
+
+--print-curl goes the other way: it prints the request configured by the
+other flags as a curl command (notes about what curl cannot reproduce, such
+as OAuth2 or per-request placeholders, go to stderr) and sends nothing.
+
+--out combines outputs in one test. TYPE is a result format written when
+the test ends (text, json, markdown, csv, junit, html), ndjson for a log
+of every request as it finishes (one JSON object per line: time, endpoint,
+method, URL, status, latency or error), or prometheus=URL to push request
+counters and a latency summary to a Prometheus Pushgateway every 10s and
+at the end. PATH '-' is stdout, which only one output may use. --output and
+--out-file count as one more output, dropped when --out names a result
+format and neither is set.`,
 		Example: `# 100 requests with concurrency 10
 stress-test run --url https://example.com --requests 100 --concurrency 10
 
@@ -58,144 +145,269 @@ stress-test run --url https://example.com --requests 100 --concurrency 10
 stress-test run --url https://httpbin.org/post --requests 50 --concurrency 5 \
 	--method POST --header 'Content-Type: application/json' --body '{"a":1}'
 
+# Send a large JSON fixture or a binary payload read from disk
+stress-test run --url https://httpbin.org/post --requests 50 --method POST \
+	--header 'Content-Type: application/octet-stream' --body-file payload.bin
+
+# Upload a multipart form with a 256KiB random file per request
+stress-test run --url https://httpbin.org/post --requests 100 --concurrency 10 \
+	--form 'title=report' --form 'file=@random:256KiB;type=application/octet-stream'
+
+# OAuth2 client credentials; the token is refreshed before it expires
+stress-test run --url https://api.example.com/orders --requests 10000 --concurrency 50 \
+	--oauth2-token-url https://auth.example.com/oauth/token \
+	--oauth2-client-id load-test --oauth2-client-secret @secret.txt --oauth2-scope orders:read
+
+# Sign every request with HMAC-SHA256 over method, path, timestamp and body hash
+stress-test run --url https://api.example.com/v1/items --requests 500 \
+	--hmac-key @hmac.key --hmac-timestamp-header X-Timestamp \
+	--hmac-canonical '{method}\n{path}\n{timestamp}\n{body_sha256}'
+
+# PUT objects into an S3-compatible store with SigV4
+stress-test run --url http://localhost:9000/bucket/object --requests 200 --method PUT \
+	--body-file object.bin --aws-sigv4 aws:amz:us-east-1:s3
+
+# Fresh RS256 JWT per request, subject taken from a CSV data feeder
+stress-test run --url https://gateway.example.com/me --requests 5000 --concurrency 50 \
+	--feeder users.csv --jwt-key @private.pem --jwt-alg RS256 \
+	--jwt-claim 'sub={feed:user_id}' --jwt-claim 'roles:=["reader"]' \
+	--header 'Authorization: Bearer {jwt}' --header 'X-Request-Id: {uuid}'
+
+# Replay a browser session exported as HAR, API calls only, 20 users at a time
+stress-test run --har session.har --har-mode flow --har-domain api.example.com \
+	--har-timing --requests 100 --concurrency 20
+
+# Load test a request copied from the browser with "Copy as cURL"
+stress-test run --requests 500 --concurrency 20 --from-curl "$(pbpaste)"
+
+# A mix of requests, one curl command per line
+stress-test run --requests 1000 --concurrency 50 --from-curl @requests.sh
+
+# Print the equivalent curl command to try a single request by hand
+stress-test run --url https://httpbin.org/post --method POST --body @payload.json \
+	--header 'Content-Type: application/json' --auth-basic user:pass --print-curl
+
 # Save machine-readable output
 stress-test run --url https://example.com --requests 200 --concurrency 20 \
-	--output json --out-file result.json`,
+	--output json --out-file result.json
+
+# Shareable HTML report with charts
+stress-test run --url https://example.com --requests 5000 --concurrency 50 \
+	--output html --out-file report.html
+
+# The same page later from a saved JSON result
+stress-test report result.json --out-file report.html
+
+# Fail a CI job on slow or failing responses and show the results in GitHub
+stress-test run --url https://example.com --requests 5000 --concurrency 50 \
+	--threshold 'p95<300ms' --threshold 'error_rate<1%' \
+	--output markdown >> "$GITHUB_STEP_SUMMARY"
+
+# Text on stdout, JSON and a per-request log to files, live metrics to Prometheus
+stress-test run --url https://example.com --requests 20000 --concurrency 50 \
+	--out text=- --out json=result.json --out ndjson=requests.ndjson \
+	--out prometheus=http://localhost:9091
+
+# 8-hour soak test: bounded request log, checkpoint every 10 minutes, fail on drift
+stress-test run --url https://example.com --duration 8h --concurrency 20 \
+	--out json=soak.json --out ndjson=requests.ndjson --log-max-size 500MiB \
+	--checkpoint soak.partial.json --checkpoint-every 10m --fail-on-drift
+
+# JUnit XML for CI test reports
+stress-test run --url https://example.com --requests 5000 --concurrency 50 \
+	--threshold 'p99<1s' --output junit --out-file stress-test.xml`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if targetURL == "" {
-				return errors.New("--url is required")
+			if err := curlF.conflicts(cmd); err != nil {
+				return err
+			}
+			var curlReqs []curlRequest
+			switch {
+			case curlF.command != "":
+				var err error
+				if curlReqs, err = curlF.load(cmd.InOrStdin(), cmd.ErrOrStderr()); err != nil {
+					return err
+				}
+				if len(curlReqs) == 1 {
+					targetURL, reqF.method = curlReqs[0].URL, curlReqs[0].Method
+				}
+			case harF.path == "":
+				if targetURL == "" {
+					return errors.New("--url, --har or --from-curl is required")
+				}
+				if _, err := url.ParseRequestURI(targetURL); err != nil {
+					return fmt.Errorf("invalid --url: %w", err)
+				}
+			case targetURL != "":
+				return errors.New("--url and --har cannot be combined")
 			}
-			if _, err := url.ParseRequestURI(targetURL); err != nil {
-				return fmt.Errorf("invalid --url: %w", err)
+
+			if err := reqF.normalizeMethod(cmd, curlF.command != ""); err != nil {
+				return err
+			}
+			hdr, err := parseHeaderFlags(reqF.headers)
+			if err != nil {
+				return err
 			}
-			if total <= 0 {
+
+			if curlF.print {
+				switch {
+				case curlReqs != nil:
+					writeCurl(cmd.OutOrStdout(), cmd.ErrOrStderr(), reqF.curls(curlReqs, hdr))
+				case harF.path != "":
+					return errors.New("--print-curl does not support --har")
+				default:
+					writeCurl(cmd.OutOrStdout(), cmd.ErrOrStderr(), []curlCommand{reqF.curl(targetURL, hdr)})
+				}
+				return nil
+			}
+			switch {
+			case duration < 0:
+				return errors.New("--duration must be > 0")
+			case duration > 0 && cmd.Flags().Changed("requests"):
+				return errors.New("--requests and --duration cannot be combined")
+			case duration > 0 && (harF.path != "" || len(curlReqs) > 1):
+				return errors.New("--duration does not support --har or several --from-curl commands")
+			case harF.path != "" && (reqF.body != "" || reqF.bodyFile != "" || len(reqF.forms) > 0 || cmd.Flags().Changed("method")):
+				return errors.New("--har cannot be combined with --method, --body, --body-file or --form")
+			case duration == 0 && total <= 0:
 				return errors.New("--requests must be > 0")
+			case duration == 0 && (cmd.Flags().Changed("window") || failOnDrift):
+				return errors.New("--window and --fail-on-drift need --duration")
+			}
+			if duration > 0 {
+				if window == 0 {
+					window = soakWindow(duration)
+				}
+				if window < time.Second || window%time.Second != 0 {
+					return errors.New("--window must be a whole number of seconds")
+				}
+			}
+			if checkpoint != "" && checkpointEvery <= 0 {
+				return errors.New("--checkpoint-every must be > 0")
+			}
+			outs, err := outF.parse(cmd, output, outFile)
+			if err != nil {
+				return err
+			}
+			ths, err := parseThresholds(thresholds)
+			if err != nil {
+				return err
+			}
+			if _, err := parseTags(histF.tags); err != nil {
+				return err
 			}
 			if concurrency <= 0 {
 				return errors.New("--concurrency must be > 0")
 			}
 
-			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+			ctx, cancel := context.WithCancel(cmd.Context())
 			defer cancel()
+			// a --duration test ends on its own; --timeout only bounds it when set
+			if duration == 0 || cmd.Flags().Changed("timeout") {
+				var stop context.CancelFunc
+				ctx, stop = context.WithTimeout(ctx, timeout)
+				defer stop()
+			}
 
-			// normalize and validate method
-			method = strings.ToUpper(strings.TrimSpace(method))
-			if method == "" {
-				method = http.MethodGet
+			var cr *curlRequest
+			if len(curlReqs) == 1 {
+				cr = &curlReqs[0]
 			}
-			switch method {
-			case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead, http.MethodOptions:
-			default:
-				return fmt.Errorf("unsupported --method: %s", method)
+			opts, pipe, err := reqF.options(hdr, cr, cmd.InOrStdin())
+			if err != nil {
+				return err
 			}
-
-			// parse headers "Key: Value"
-			hdr := make(http.Header)
-			for _, h := range headers {
-				parts := strings.SplitN(h, ":", 2)
-				if len(parts) != 2 {
-					return fmt.Errorf("invalid --header format (use 'Key: Value'): %q", h)
+			prepares := opts.BodyFunc != nil || len(opts.Hooks) > 0
+			result := func(rep runner.Report) *report.Result {
+				res := newResult("run", rep, prepares)
+				res.URL, res.HAR, res.Method = targetURL, harF.path, reqF.method
+				res.Config = testConfig(cmd, "requests", "concurrency", "timeout", "method")
+				if duration > 0 {
+					res.Drift = report.AnalyzeDrift(res)
 				}
-				key := strings.TrimSpace(parts[0])
-				val := strings.TrimSpace(parts[1])
-				if key == "" {
-					return fmt.Errorf("invalid --header key in: %q", h)
+				res.CheckThresholds(ths)
+				return res
+			}
+			if duration > 0 {
+				opts.Resolution = window
+			}
+			if checkpoint != "" {
+				opts.Checkpoint = func(rep runner.Report) {
+					res := result(rep)
+					printCheckpoint(cmd.ErrOrStderr(), res, writeCheckpoint(checkpoint, res))
 				}
-				hdr.Add(key, val)
+				opts.CheckpointEvery = checkpointEvery
 			}
-
-			opts := runner.Options{
-				Method:  method,
-				Headers: hdr,
-				Body:    []byte(body),
+			if err := outs.open(cmd.OutOrStdout()); err != nil {
+				return err
 			}
+			defer outs.close()
+			opts.Sinks = outs.sinks
 
-			rep, err := runner.RunWithOptions(ctx, targetURL, total, concurrency, opts)
+			var rep runner.Report
+			if len(curlReqs) > 1 {
+				reqF.method = "" // every command has its own
+				rep, err = runCurlMix(ctx, curlReqs, opts, pipe, total, concurrency)
+			} else if harF.path != "" {
+				reqF.method = "" // every entry has its own
+				rep, err = harF.run(ctx, cmd.InOrStdin(), opts, total, concurrency)
+			} else if duration > 0 {
+				rep, err = runner.RunForDuration(ctx, targetURL, duration, concurrency, opts)
+			} else {
+				rep, err = runner.RunWithOptions(ctx, targetURL, total, concurrency, opts)
+			}
 			if err != nil {
 				return err
 			}
+			// flush the per-request log and push the final metrics; a
+			// failure is reported once the results are written
+			sinkErr := outs.close()
 
-			// Output
-			switch strings.ToLower(strings.TrimSpace(output)) {
-			case "", "text":
-				// human-readable
-				fmt.Fprintf(cmd.OutOrStdout(), "Total time: %s\n", rep.Duration)
-				fmt.Fprintf(cmd.OutOrStdout(), "Total requests: %d\n", rep.TotalRequests)
-				fmt.Fprintf(cmd.OutOrStdout(), "Requests/sec: %.2f\n", rep.RPS())
-				fmt.Fprintf(cmd.OutOrStdout(), "HTTP 200: %d\n", rep.Succeeded200)
-				if len(rep.StatusCounts) > 0 {
-					fmt.Fprintln(cmd.OutOrStdout(), "Other status codes:")
-					for code, count := range rep.StatusCounts {
-						if code == 200 { // already printed separately
-							continue
-						}
-						fmt.Fprintf(cmd.OutOrStdout(), "- %d: %d\n", code, count)
-					}
-				}
-				if rep.Errors > 0 {
-					fmt.Fprintf(cmd.OutOrStdout(), "Errors: %d\n", rep.Errors)
-				}
-				return nil
-			case "json":
-				// machine-readable
-				type jsonOut struct {
-					URL           string         `json:"url"`
-					Method        string         `json:"method"`
-					DurationMS    int64          `json:"duration_ms"`
-					TotalRequests int            `json:"total_requests"`
-					RPS           float64        `json:"rps"`
-					HTTP200       int            `json:"http_200"`
-					Errors        int            `json:"errors"`
-					StatusCounts  map[string]int `json:"status_counts"`
-					Timestamp     string         `json:"timestamp"`
-				}
-				sc := make(map[string]int, len(rep.StatusCounts))
-				for k, v := range rep.StatusCounts {
-					sc[fmt.Sprintf("%d", k)] = v
-				}
-				payload := jsonOut{
-					URL:           targetURL,
-					Method:        method,
-					DurationMS:    rep.Duration.Milliseconds(),
-					TotalRequests: rep.TotalRequests,
-					RPS:           rep.RPS(),
-					HTTP200:       rep.Succeeded200,
-					Errors:        rep.Errors,
-					StatusCounts:  sc,
-					Timestamp:     time.Now().UTC().Format(time.RFC3339),
-				}
-				data, err := json.MarshalIndent(payload, "", "  ")
-				if err != nil {
+			res := result(rep)
+			if err := histF.tag(res); err != nil {
+				return err
+			}
+			if err := outs.write(cmd.OutOrStdout(), res, report.Options{}); err != nil {
+				return err
+			}
+			if checkpoint != "" {
+				// the last checkpoint becomes the final result
+				if err := writeCheckpoint(checkpoint, res); err != nil {
 					return err
 				}
-				if outFile != "" {
-					return os.WriteFile(outFile, data, 0644)
-				}
-				_, _ = cmd.OutOrStdout().Write(append(data, '\n'))
-				return nil
-			default:
-				return fmt.Errorf("unsupported --output: %s", output)
 			}
+			if err := histF.save(cmd.ErrOrStderr(), res); err != nil {
+				return err
+			}
+			if sinkErr != nil {
+				return sinkErr
+			}
+			if err := thresholdsError(res); err != nil {
+				return err
+			}
+			if failOnDrift {
+				return driftError(res)
+			}
+			return nil
 		},
 	}
 
 	cmd.Flags().StringVar(&targetURL, "url", "", "Target URL to test")
 	cmd.Flags().IntVar(&total, "requests", 0, "Total number of requests")
+	cmd.Flags().DurationVar(&duration, "duration", 0, "Run for this long instead of --requests, e.g. 30m or 8h")
 	cmd.Flags().IntVar(&concurrency, "concurrency", 10, "Number of concurrent workers")
-	cmd.Flags().DurationVar(&timeout, "timeout", 60*time.Second, "Overall test timeout")
-	cmd.Flags().StringVar(&method, "method", http.MethodGet, "HTTP method (GET, POST, PUT, PATCH, DELETE, HEAD, OPTIONS)")
-	cmd.Flags().StringArrayVar(&headers, "header", nil, "HTTP header in 'Key: Value' format (repeatable)")
-	cmd.Flags().StringVar(&body, "body", "", "HTTP request body (string)")
-	cmd.Flags().StringVar(&output, "output", "text", "Output format: text|json")
-	cmd.Flags().StringVar(&outFile, "out-file", "", "Write output to file (only for --output=json by default)")
-	err := cmd.MarkFlagRequired("url")
-	if err != nil {
-		return nil
-	}
-	err = cmd.MarkFlagRequired("requests")
-	if err != nil {
-		return nil
-	}
-
+	cmd.Flags().DurationVar(&timeout, "timeout", 60*time.Second, "Overall test timeout (not applied to --duration unless set)")
+	cmd.Flags().DurationVar(&window, "window", 0, "Timeline window of a --duration test, a whole number of seconds (0 = automatic: 1s up to 15m, then longer to keep at most 900 windows, e.g. 1m for 8h)")
+	cmd.Flags().StringVar(&checkpoint, "checkpoint", "", "Save the JSON result so far to this file every --checkpoint-every, and the final result at the end, so a killed test keeps its results")
+	cmd.Flags().DurationVar(&checkpointEvery, "checkpoint-every", 5*time.Minute, "Interval between --checkpoint saves")
+	cmd.Flags().BoolVar(&failOnDrift, "fail-on-drift", false, "Exit with status 2 when drift detection finds a degrading trend: significant (p<0.01) and over +20% p95, -10% throughput or +1 point of error rate across the test")
+	reqF.register(cmd)
+	harF.register(cmd)
+	curlF.register(cmd)
+	histF.register(cmd)
+	outF.register(cmd)
+	cmd.Flags().StringArrayVar(&thresholds, "threshold", nil, "Pass/fail criterion METRIC OP LIMIT: rps, mean, p50, p90, p95, p99, max or error_rate with <, <=, > or >=, e.g. 'p95<300ms' or 'error_rate<=1%' (repeatable)")
+	cmd.Flags().StringVar(&output, "output", "text", "Output format: text|json|markdown|csv|junit|html")
+	cmd.Flags().StringVar(&outFile, "out-file", "", "Write the output to file instead of stdout")
 	return cmd
 }
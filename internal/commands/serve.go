@@ -0,0 +1,114 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/JeanGrijp/stress-test/internal/server"
+	"github.com/spf13/cobra"
+)
+
+// NewServeCmd starts a local target server for calibration and testing.
+// Example:
+//
+//	stress-test serve --addr 127.0.0.1:8080 --latency 5ms
+func NewServeCmd() *cobra.Command {
+	var (
+		addr        string
+		useTLS      bool
+		certFile    string
+		keyFile     string
+		h2c         bool
+		latency     time.Duration
+		jitter      time.Duration
+		maxBodySize string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Start a local HTTP(S) target server for calibration and testing",
+		Long: `Start a configurable local HTTP server to sanity-check stress-test itself,
+measure its overhead, or write integration tests without a real service.
+
+Endpoints (parameters go in the path or query string):
+	/ok                                   200 with a tiny body
+	/delay/{d}                            fixed latency, e.g. /delay/150ms (bare numbers are ms)
+	/random?min=10ms&max=200ms            uniform random latency
+	/latency?dist=normal&mean=&stddev=    normal latency distribution
+	/latency?dist=exp&mean=               exponential latency distribution
+	/latency?dist=lognormal&median=&p99=  log-normal latency (long tail)
+	/status/{code}                        fixed status code
+	/status?mix=200:90,500:5,503:5        weighted status code mix
+	/echo                                 echo method, URL, headers and body as JSON
+	/bytes/{size}                         large body, e.g. /bytes/10MiB (?random=true)
+	/stream?chunks=10&interval=100ms&size=1KiB  chunked streaming response
+	/slow?size=1MiB&rate=64KiB            body trickled at rate bytes/second
+	/drop                                 abort the connection without a response
+	/drop?after=512                       send headers and 512 bytes, then abort
+	/stats                                server-side counters (JSON)
+
+Every endpoint also accepts ?delay=, ?jitter= and ?status= (e.g.
+/echo?delay=20ms&status=201). Compare /stats mean handling time with the
+latency reported by run/ramp to estimate client overhead.
+
+Protocols: plain HTTP/1.1 by default, HTTPS with HTTP/2 (ALPN) with --tls
+(a self-signed certificate is generated unless --cert/--key are given),
+and HTTP/2 cleartext with --h2c. Stop with Ctrl+C.`,
+		Example: `# Start on the default address and hammer a 20ms endpoint
+stress-test serve &
+stress-test run --url http://127.0.0.1:8080/delay/20ms --requests 1000 --concurrency 20
+
+# Calibrate against a realistic long-tail latency distribution
+stress-test ramp --url 'http://127.0.0.1:8080/latency?dist=lognormal&median=30ms&p99=400ms' \
+	--steps 4 --start-concurrency 10 --step-concurrency 10 --per-step-duration 10s --requests-per-step 0
+
+# HTTPS + HTTP/2 with a generated self-signed certificate
+stress-test serve --addr 127.0.0.1:8443 --tls
+
+# 5% server errors and 10ms base latency on every endpoint
+stress-test serve --latency 10ms
+stress-test run --url 'http://127.0.0.1:8080/status?mix=200:95,503:5' --requests 500`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if latency < 0 || jitter < 0 {
+				return errors.New("--latency and --jitter must be >= 0")
+			}
+			if (certFile == "") != (keyFile == "") {
+				return errors.New("--cert and --key must be set together")
+			}
+			if certFile != "" {
+				useTLS = true
+			}
+			maxBody, err := parseSizeFlag("--max-body-size", maxBodySize)
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			h := server.New(server.Config{Latency: latency, Jitter: jitter, MaxBodySize: maxBody})
+			lc := server.ListenConfig{Addr: addr, TLS: useTLS, CertFile: certFile, KeyFile: keyFile, H2C: h2c}
+			return server.Serve(ctx, lc, h, func(bound string) {
+				scheme := "http"
+				if useTLS {
+					scheme = "https"
+				}
+				fmt.Fprintf(cmd.ErrOrStderr(), "Listening on %s://%s (Ctrl+C to stop)\n", scheme, bound)
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&addr, "addr", "127.0.0.1:8080", "Address to listen on (use port 0 for a random port)")
+	cmd.Flags().BoolVar(&useTLS, "tls", false, "Serve HTTPS with HTTP/2 (self-signed certificate unless --cert/--key)")
+	cmd.Flags().StringVar(&certFile, "cert", "", "TLS certificate file (PEM)")
+	cmd.Flags().StringVar(&keyFile, "key", "", "TLS private key file (PEM)")
+	cmd.Flags().BoolVar(&h2c, "h2c", false, "Also accept HTTP/2 over cleartext (prior knowledge)")
+	cmd.Flags().DurationVar(&latency, "latency", 0, "Base latency added to every request")
+	cmd.Flags().DurationVar(&jitter, "jitter", 0, "Random extra latency in [0, jitter) added to every request")
+	cmd.Flags().StringVar(&maxBodySize, "max-body-size", "1GiB", "Largest generated or echoed body")
+	return cmd
+}
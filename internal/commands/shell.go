@@ -0,0 +1,222 @@
+package commands
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// splitCommands splits shell text into commands and their words, the way a
+// POSIX shell would for the simple command lines produced by "Copy as cURL":
+// single quotes, double quotes (with \", \\, \$ and \` escapes), ANSI-C
+// $'...' strings, backslash escapes and backslash-newline continuations.
+// Unquoted newlines and ';' separate commands, and '#' starts a comment at
+// the beginning of a word. Variables and substitutions are not expanded.
+func splitCommands(s string) ([][]string, error) {
+	var (
+		cmds    [][]string
+		words   []string
+		cur     strings.Builder
+		inWord  bool
+		endWord = func() {
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		}
+		endCmd = func() {
+			endWord()
+			if len(words) > 0 {
+				cmds = append(cmds, words)
+				words = nil
+			}
+		}
+	)
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\':
+			if i+1 < len(s) && s[i+1] == '\n' {
+				i++ // line continuation
+				continue
+			}
+			if i+1 < len(s) && s[i+1] == '\r' && i+2 < len(s) && s[i+2] == '\n' {
+				i += 2
+				continue
+			}
+			if i+1 < len(s) {
+				i++
+				cur.WriteByte(s[i])
+				inWord = true
+			}
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			cur.WriteString(s[i+1 : i+1+end])
+			inWord = true
+			i += end + 1
+		case c == '$' && i+1 < len(s) && s[i+1] == '\'':
+			n, err := ansiCString(s[i+2:], &cur)
+			if err != nil {
+				return nil, err
+			}
+			inWord = true
+			i += 1 + n
+		case c == '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`\n", s[i+1]) >= 0 {
+					i++
+					if s[i] == '\n' {
+						continue
+					}
+				}
+				cur.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, errors.New("unterminated double quote")
+			}
+			inWord = true
+		case c == '#' && !inWord:
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+			endCmd()
+		case c == '\n' || c == ';':
+			endCmd()
+		case c == ' ' || c == '\t' || c == '\r':
+			endWord()
+		default:
+			cur.WriteByte(c)
+			inWord = true
+		}
+	}
+	endCmd()
+	return cmds, nil
+}
+
+// ansiCString decodes the body of a $'...' string starting right after the
+// opening quote into b and returns the number of bytes consumed, including
+// the closing quote.
+func ansiCString(s string, b *strings.Builder) (int, error) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\'' {
+			return i + 1, nil
+		}
+		if c != '\\' || i+1 >= len(s) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch e := s[i]; e {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'e', 'E':
+			b.WriteByte(0x1b)
+		case 'f':
+			b.WriteByte('\f')
+		case 'v':
+			b.WriteByte('\v')
+		case '\\', '\'', '"', '?':
+			b.WriteByte(e)
+		case 'x', 'u', 'U':
+			max := map[byte]int{'x': 2, 'u': 4, 'U': 8}[e]
+			j := i + 1
+			for j < len(s) && j-i-1 < max && isHex(s[j]) {
+				j++
+			}
+			if j == i+1 {
+				b.WriteByte('\\')
+				b.WriteByte(e)
+				continue
+			}
+			v, _ := strconv.ParseUint(s[i+1:j], 16, 32)
+			if e == 'x' {
+				b.WriteByte(byte(v))
+			} else {
+				b.WriteRune(rune(v))
+			}
+			i = j - 1
+		default:
+			if e >= '0' && e <= '7' {
+				j := i
+				for j < len(s) && j-i < 3 && s[j] >= '0' && s[j] <= '7' {
+					j++
+				}
+				v, _ := strconv.ParseUint(s[i:j], 8, 8)
+				b.WriteByte(byte(v))
+				i = j - 1
+				continue
+			}
+			b.WriteByte('\\')
+			b.WriteByte(e)
+		}
+	}
+	return 0, errors.New("unterminated $'...' string")
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// shellQuote quotes s for a POSIX shell: bare when it is safe, in single
+// quotes when printable, and as an ANSI-C $'...' string otherwise.
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	safe := true
+	printable := true
+	for _, r := range s {
+		switch {
+		case r < 0x20 || r == 0x7f:
+			printable = false
+			safe = false
+		case !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=@,+%", r)):
+			safe = false
+		}
+	}
+	if safe {
+		return s
+	}
+	if printable {
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	}
+	var b strings.Builder
+	b.WriteString("$'")
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\\', '\'':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			if c < 0x20 || c >= 0x7f {
+				b.WriteString(`\x` + strconv.FormatUint(uint64(c)|0x100, 16)[1:])
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
@@ -0,0 +1,195 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/JeanGrijp/stress-test/internal/runner"
+	"github.com/JeanGrijp/stress-test/internal/sign"
+	"github.com/spf13/cobra"
+)
+
+// signFlags holds the request signing flags shared by run, ramp and curl.
+type signFlags struct {
+	hmacKey             string
+	hmacCanonical       string
+	hmacHeader          string
+	hmacPrefix          string
+	hmacEncoding        string
+	hmacTimestampHeader string
+	hmacTimestampFormat string
+
+	awsSigV4        string
+	awsAccessKey    string
+	awsSecretKey    string
+	awsSessionToken string
+	awsUnsigned     bool
+}
+
+// register adds the signing flags to cmd.
+func (s *signFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&s.hmacKey, "hmac-key", "", "Sign requests with HMAC-SHA256 using this key (or @file)")
+	cmd.Flags().StringVar(&s.hmacCanonical, "hmac-canonical", sign.DefaultCanonical, "HMAC canonical string; placeholders: {method} {host} {path} {query} {url} {timestamp} {body_sha256} {header:Name}")
+	cmd.Flags().StringVar(&s.hmacHeader, "hmac-header", "X-Signature", "Header that receives the HMAC signature")
+	cmd.Flags().StringVar(&s.hmacPrefix, "hmac-prefix", "", "Prefix for the HMAC header value, e.g. 'HMAC-SHA256 '")
+	cmd.Flags().StringVar(&s.hmacEncoding, "hmac-encoding", "hex", "HMAC signature encoding: hex|base64")
+	cmd.Flags().StringVar(&s.hmacTimestampHeader, "hmac-timestamp-header", "", "Header that receives the signing timestamp, e.g. X-Timestamp")
+	cmd.Flags().StringVar(&s.hmacTimestampFormat, "hmac-timestamp-format", "unix", "Signing timestamp format: unix|unix-ms|rfc3339")
+	cmd.Flags().StringVar(&s.awsSigV4, "aws-sigv4", "", "Sign requests with AWS SigV4, curl syntax 'aws:amz:REGION:SERVICE'")
+	cmd.Flags().StringVar(&s.awsAccessKey, "aws-access-key", "", "AWS access key ID (default $AWS_ACCESS_KEY_ID)")
+	cmd.Flags().StringVar(&s.awsSecretKey, "aws-secret-key", "", "AWS secret access key (default $AWS_SECRET_ACCESS_KEY)")
+	cmd.Flags().StringVar(&s.awsSessionToken, "aws-session-token", "", "AWS session token (default $AWS_SESSION_TOKEN)")
+	cmd.Flags().BoolVar(&s.awsUnsigned, "aws-unsigned-payload", false, "Use UNSIGNED-PAYLOAD instead of hashing the body (S3)")
+}
+
+// parseCurlFlag consumes a signing flag from curl-style arguments at args[i].
+// It reports whether the flag was recognised and the index of the last
+// argument consumed.
+func (s *signFlags) parseCurlFlag(args []string, i int) (int, bool, error) {
+	targets := map[string]*string{
+		"--hmac-key":              &s.hmacKey,
+		"--hmac-canonical":        &s.hmacCanonical,
+		"--hmac-header":           &s.hmacHeader,
+		"--hmac-prefix":           &s.hmacPrefix,
+		"--hmac-encoding":         &s.hmacEncoding,
+		"--hmac-timestamp-header": &s.hmacTimestampHeader,
+		"--hmac-timestamp-format": &s.hmacTimestampFormat,
+		"--aws-sigv4":             &s.awsSigV4,
+	}
+	if args[i] == "--aws-unsigned-payload" {
+		s.awsUnsigned = true
+		return i, true, nil
+	}
+	dst, ok := targets[args[i]]
+	if !ok {
+		return i, false, nil
+	}
+	if i+1 >= len(args) {
+		return i, true, fmt.Errorf("%s requires a value", args[i])
+	}
+	*dst = args[i+1]
+	return i + 1, true, nil
+}
+
+// hooks returns the configured signers. They must run after every other
+// hook so the signature covers the final headers.
+func (s *signFlags) hooks(stdin io.Reader) ([]runner.Hook, error) {
+	var hooks []runner.Hook
+	if s.hmacKey != "" {
+		key, err := readBodyArg(s.hmacKey, stdin)
+		if err != nil {
+			return nil, fmt.Errorf("--hmac-key: %w", err)
+		}
+		h := &sign.HMAC{
+			Key:             key,
+			Canonical:       s.hmacCanonical,
+			Header:          s.hmacHeader,
+			Prefix:          s.hmacPrefix,
+			Encoding:        s.hmacEncoding,
+			TimestampHeader: s.hmacTimestampHeader,
+			TimestampFormat: s.hmacTimestampFormat,
+		}
+		if err := h.Validate(); err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, h)
+	}
+	if s.awsSigV4 != "" {
+		region, service, err := parseAWSSigV4(s.awsSigV4)
+		if err != nil {
+			return nil, err
+		}
+		v4 := &sign.SigV4{
+			AccessKey:       firstNonEmpty(s.awsAccessKey, os.Getenv("AWS_ACCESS_KEY_ID")),
+			SecretKey:       firstNonEmpty(s.awsSecretKey, os.Getenv("AWS_SECRET_ACCESS_KEY")),
+			SessionToken:    firstNonEmpty(s.awsSessionToken, os.Getenv("AWS_SESSION_TOKEN")),
+			Region:          region,
+			Service:         service,
+			UnsignedPayload: s.awsUnsigned,
+		}
+		if err := v4.Validate(); err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, v4)
+	}
+	return hooks, nil
+}
+
+// parseAWSSigV4 extracts region and service from curl's --aws-sigv4 value
+// "provider1[:provider2[:region[:service]]]". The region falls back to
+// $AWS_REGION.
+func parseAWSSigV4(v string) (region, service string, err error) {
+	parts := strings.Split(v, ":")
+	if len(parts) > 4 {
+		return "", "", fmt.Errorf("invalid --aws-sigv4 %q (use 'aws:amz:REGION:SERVICE')", v)
+	}
+	if len(parts) >= 3 {
+		region = parts[2]
+	}
+	if len(parts) == 4 {
+		service = parts[3]
+	}
+	region = firstNonEmpty(region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"))
+	if region == "" || service == "" {
+		return "", "", errors.New("--aws-sigv4 needs a region and service, e.g. 'aws:amz:us-east-1:s3'")
+	}
+	return region, service, nil
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// curlArgs returns the curl arguments for the configured signers, and
+// notes for what curl cannot do. The --hmac-* options are understood by
+// 'stress-test curl' and --from-curl but not by curl itself.
+func (s *signFlags) curlArgs() (args, notes []string) {
+	if s.hmacKey != "" {
+		args = append(args, "--hmac-key", s.hmacKey)
+		defaults := map[string]string{
+			"--hmac-canonical":        sign.DefaultCanonical,
+			"--hmac-header":           "X-Signature",
+			"--hmac-prefix":           "",
+			"--hmac-encoding":         "hex",
+			"--hmac-timestamp-header": "",
+			"--hmac-timestamp-format": "unix",
+		}
+		for _, o := range []struct{ flag, value string }{
+			{"--hmac-canonical", s.hmacCanonical},
+			{"--hmac-header", s.hmacHeader},
+			{"--hmac-prefix", s.hmacPrefix},
+			{"--hmac-encoding", s.hmacEncoding},
+			{"--hmac-timestamp-header", s.hmacTimestampHeader},
+			{"--hmac-timestamp-format", s.hmacTimestampFormat},
+		} {
+			if o.value != defaults[o.flag] {
+				args = append(args, o.flag, o.value)
+			}
+		}
+		notes = append(notes, "--hmac-* signing is only understood by 'stress-test curl' and --from-curl")
+	}
+	if s.awsSigV4 != "" {
+		args = append(args, "--aws-sigv4", s.awsSigV4)
+		if s.awsAccessKey != "" && s.awsSecretKey != "" {
+			args = append(args, "-u", s.awsAccessKey+":"+s.awsSecretKey)
+		} else {
+			notes = append(notes, "curl takes the AWS keys from -u ACCESS_KEY:SECRET_KEY, not from $AWS_* variables")
+		}
+		if s.awsSessionToken != "" {
+			args = append(args, "-H", "X-Amz-Security-Token: "+s.awsSessionToken)
+		}
+		if s.awsUnsigned {
+			args = append(args, "--aws-unsigned-payload")
+			notes = append(notes, "--aws-unsigned-payload is only understood by 'stress-test curl' and --from-curl")
+		}
+	}
+	return args, notes
+}
@@ -0,0 +1,16 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/JeanGrijp/stress-test/internal/form"
+)
+
+// parseSizeFlag parses a byte size flag such as "64KiB" or "10MB".
+func parseSizeFlag(name, v string) (int64, error) {
+	n, err := form.ParseSize(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return n, nil
+}
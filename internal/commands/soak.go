@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/JeanGrijp/stress-test/internal/cli"
+	"github.com/JeanGrijp/stress-test/internal/report"
+)
+
+// maxSoakWindows bounds the timeline of a run --duration test: the window
+// grows with the duration so memory and the saved result stay small.
+const maxSoakWindows = 900
+
+// soakWindow returns the automatic timeline window of a test lasting d: one
+// second up to 15 minutes, then the shortest round window that keeps the
+// timeline within maxSoakWindows, e.g. one minute for 8 hours.
+func soakWindow(d time.Duration) time.Duration {
+	for _, w := range []time.Duration{time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second,
+		15 * time.Second, 30 * time.Second, time.Minute, 2 * time.Minute, 5 * time.Minute} {
+		if d/w <= maxSoakWindows {
+			return w
+		}
+	}
+	return 10 * time.Minute
+}
+
+// writeCheckpoint saves res as JSON to path through a temporary file, so
+// the file always holds a complete result even if the test is killed while
+// it is written.
+func writeCheckpoint(path string, res *report.Result) error {
+	var buf bytes.Buffer
+	if err := report.Write(&buf, "json", res, report.Options{}); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// printCheckpoint reports a saved checkpoint on stderr, e.g. "checkpoint
+// 1h0m0s: 3600000 requests, 1000.00 rps, p95 12.5ms, drift stable (59
+// windows of 1m0s)".
+func printCheckpoint(w io.Writer, res *report.Result, err error) {
+	if err != nil {
+		fmt.Fprintf(w, "checkpoint: %v\n", err)
+		return
+	}
+	elapsed := (time.Duration(res.DurationMS) * time.Millisecond).Round(time.Second)
+	line := fmt.Sprintf("checkpoint %s: %d requests, %.2f rps", elapsed, res.TotalRequests, res.RPS)
+	if res.Latency != nil {
+		line += fmt.Sprintf(", p95 %s", roundDuration(time.Duration(res.Latency.P95*float64(time.Millisecond))))
+	}
+	if res.Drift != nil {
+		line += ", drift " + res.Drift.Summary()
+	}
+	fmt.Fprintln(w, line)
+}
+
+// driftError fails a test whose timeline degrades, with the same exit
+// status as a failed threshold.
+func driftError(res *report.Result) error {
+	if res.Drift == nil || !res.Drift.Degrading {
+		return nil
+	}
+	return &cli.ExitError{Code: 2, Err: fmt.Errorf("drift: %s", res.Drift.Summary())}
+}
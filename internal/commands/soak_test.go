@@ -0,0 +1,26 @@
+package commands
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSoakWindow(t *testing.T) {
+	tests := []struct {
+		d, want time.Duration
+	}{
+		{10 * time.Second, time.Second},
+		{15 * time.Minute, time.Second},
+		{15*time.Minute + time.Second, 2 * time.Second},
+		{time.Hour, 5 * time.Second},
+		{8 * time.Hour, time.Minute},
+		{24 * time.Hour, 2 * time.Minute},
+		{75 * time.Hour, 5 * time.Minute},
+		{100 * time.Hour, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := soakWindow(tt.d); got != tt.want {
+			t.Errorf("soakWindow(%s) = %s, want %s", tt.d, got, tt.want)
+		}
+	}
+}
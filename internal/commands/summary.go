@@ -0,0 +1,207 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JeanGrijp/stress-test/internal/cli"
+	"github.com/JeanGrijp/stress-test/internal/report"
+	"github.com/JeanGrijp/stress-test/internal/runner"
+	"github.com/JeanGrijp/stress-test/internal/version"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// endpointNames returns the labels of rep.Endpoints, busiest first.
+func endpointNames(rep runner.Report) []string {
+	names := make([]string, 0, len(rep.Endpoints))
+	for name := range rep.Endpoints {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := rep.Endpoints[names[i]], rep.Endpoints[names[j]]
+		if a.Requests != b.Requests {
+			return a.Requests > b.Requests
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// endpointsJSON returns the labeled endpoints of rep, busiest first.
+func endpointsJSON(rep runner.Report) []report.Endpoint {
+	var out []report.Endpoint
+	for _, name := range endpointNames(rep) {
+		e := rep.Endpoints[name]
+		out = append(out, report.Endpoint{
+			Name:         name,
+			Requests:     e.Requests,
+			Errors:       e.Errors,
+			Unexpected:   e.Unexpected,
+			StatusCounts: statusCountsJSON(e.StatusCounts),
+			Latency:      summarizeLatency(e.Latency),
+		})
+	}
+	return out
+}
+
+func statusCountsJSON(counts map[int]int) map[string]int {
+	sc := make(map[string]int, len(counts))
+	for k, v := range counts {
+		sc[strconv.Itoa(k)] = v
+	}
+	return sc
+}
+
+// timelineJSON summarizes every window of rep.
+func timelineJSON(rep runner.Report) []report.Interval {
+	step := int(rep.Window() / time.Second)
+	out := make([]report.Interval, len(rep.Timeline))
+	for i, iv := range rep.Timeline {
+		out[i] = report.Interval{
+			Second:     i * step,
+			Requests:   iv.Requests,
+			Errors:     iv.Errors,
+			HTTPErrors: iv.HTTPErrors,
+			P50:        ms(iv.Latency.Quantile(0.50)),
+			P95:        ms(iv.Latency.Quantile(0.95)),
+			P99:        ms(iv.Latency.Quantile(0.99)),
+		}
+	}
+	return out
+}
+
+// newResult converts rep to the result document of command; callers fill
+// in what was tested and how. The preparation latency is only kept when
+// prepares is set, i.e. when requests are built per request.
+func newResult(command string, rep runner.Report, prepares bool) *report.Result {
+	res := &report.Result{
+		Version:       report.SchemaVersion,
+		Generator:     version.Version,
+		Command:       command,
+		DurationMS:    rep.Duration.Milliseconds(),
+		TotalRequests: rep.TotalRequests,
+		RPS:           rep.RPS(),
+		HTTP200:       rep.Succeeded200,
+		Errors:        rep.Errors,
+		Unexpected:    rep.Unexpected,
+		StatusCounts:  statusCountsJSON(rep.StatusCounts),
+		Latency:       summarizeLatency(rep.Latency),
+		Endpoints:     endpointsJSON(rep),
+		Timeline:      timelineJSON(rep),
+		Timestamp:     time.Now().UTC().Format(time.RFC3339),
+	}
+	if prepares {
+		res.Prepare = summarizeLatency(rep.Prepare)
+	}
+	if w := rep.Window(); w != time.Second {
+		res.TimelineStepMS = w.Milliseconds()
+	}
+	return res
+}
+
+// outputFormat normalizes the --output value and checks it is one of
+// allowed.
+func outputFormat(output string, allowed ...string) (string, error) {
+	format := strings.ToLower(strings.TrimSpace(output))
+	if format == "" {
+		format = "text"
+	}
+	if !slices.Contains(allowed, format) {
+		return "", fmt.Errorf("unsupported --output: %s (use %s)", output, strings.Join(allowed, ", "))
+	}
+	return format, nil
+}
+
+// writeResult renders res in format to outFile, or to w when outFile is
+// empty.
+func writeResult(w io.Writer, outFile, format string, res *report.Result, opts report.Options) error {
+	if outFile == "" {
+		return report.Write(w, format, res, opts)
+	}
+	var buf bytes.Buffer
+	if err := report.Write(&buf, format, res, opts); err != nil {
+		return err
+	}
+	return os.WriteFile(outFile, buf.Bytes(), 0644)
+}
+
+// secretFlags hold credentials; their values are masked in saved results
+// unless they name a file (@file).
+var secretFlags = flagSet("auth-basic", "auth-bearer", "oauth2-client-secret", "hmac-key",
+	"jwt-key", "aws-secret-key", "aws-session-token", "from-curl")
+
+// sensitiveHeader reports whether a header value is likely a credential.
+func sensitiveHeader(name string) bool {
+	name = strings.ToLower(name)
+	switch name {
+	case "authorization", "proxy-authorization", "cookie":
+		return true
+	}
+	return strings.Contains(name, "token") || strings.Contains(name, "secret") || strings.Contains(name, "key")
+}
+
+// testConfig lists the flags of cmd that were set, plus the core ones
+// whose defaults shape the test, with credentials masked.
+func testConfig(cmd *cobra.Command, core ...string) map[string]string {
+	cfg := make(map[string]string)
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if !f.Changed && !slices.Contains(core, f.Name) {
+			return
+		}
+		switch f.Name {
+		case "output", "out-file", "out", "help", "history", "tag":
+			return
+		}
+		value := f.Value.String()
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			vals := slices.Clone(sv.GetSlice())
+			if f.Name == "header" {
+				for i, h := range vals {
+					if k, _, ok := strings.Cut(h, ":"); ok && sensitiveHeader(k) {
+						vals[i] = k + ": ***"
+					}
+				}
+			}
+			value = strings.Join(vals, "\n")
+		} else if secretFlags[f.Name] && value != "" && !strings.HasPrefix(value, "@") {
+			value = "***"
+		}
+		cfg[f.Name] = value
+	})
+	return cfg
+}
+
+// parseThresholds parses the --threshold values.
+func parseThresholds(vals []string) ([]report.Threshold, error) {
+	ts := make([]report.Threshold, 0, len(vals))
+	for _, v := range vals {
+		t, err := report.ParseThreshold(v)
+		if err != nil {
+			return nil, err
+		}
+		ts = append(ts, t)
+	}
+	return ts, nil
+}
+
+// thresholdsError makes the command exit with status 2 when a threshold
+// of res failed.
+func thresholdsError(res *report.Result) error {
+	failed := res.FailedThresholds()
+	if len(failed) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(failed))
+	for i, t := range failed {
+		msgs[i] = t.Message()
+	}
+	return &cli.ExitError{Code: 2, Err: fmt.Errorf("threshold failed: %s", strings.Join(msgs, "; "))}
+}
@@ -0,0 +1,140 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/JeanGrijp/stress-test/internal/feeder"
+	"github.com/JeanGrijp/stress-test/internal/jwt"
+	"github.com/JeanGrijp/stress-test/internal/runner"
+	"github.com/JeanGrijp/stress-test/internal/tmpl"
+	"github.com/spf13/cobra"
+)
+
+// templateFlags holds the data feeder and JWT flags shared by run and ramp.
+// Header values may use placeholders such as {feed:column} and {jwt}; see
+// package tmpl.
+type templateFlags struct {
+	feederPath string
+	feederMode string
+
+	jwtKey    string
+	jwtAlg    string
+	jwtKeyID  string
+	jwtClaims []string
+	jwtTTL    time.Duration
+	jwtIssuer string
+	jwtAud    string
+}
+
+// register adds the feeder and JWT flags to cmd.
+func (t *templateFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&t.feederPath, "feeder", "", "Data feeder file (.csv, .json, .jsonl) used by {feed:column} placeholders")
+	cmd.Flags().StringVar(&t.feederMode, "feeder-mode", "sequential", "Feeder row selection: sequential|random")
+	cmd.Flags().StringVar(&t.jwtKey, "jwt-key", "", "Mint a JWT per request: HS256 secret or PEM private key (@file to read from a file)")
+	cmd.Flags().StringVar(&t.jwtAlg, "jwt-alg", "HS256", "JWT signing algorithm: HS256|RS256|ES256")
+	cmd.Flags().StringVar(&t.jwtKeyID, "jwt-kid", "", "JWT key ID header (kid)")
+	cmd.Flags().StringArrayVar(&t.jwtClaims, "jwt-claim", nil, "JWT claim 'name=value' or 'name:=json'; values may use placeholders, e.g. 'sub={feed:user_id}' (repeatable)")
+	cmd.Flags().DurationVar(&t.jwtTTL, "jwt-ttl", 5*time.Minute, "JWT lifetime used for the exp claim (0 to omit exp)")
+	cmd.Flags().StringVar(&t.jwtIssuer, "jwt-iss", "", "JWT issuer claim (iss)")
+	cmd.Flags().StringVar(&t.jwtAud, "jwt-aud", "", "JWT audience claim (aud)")
+}
+
+// hook splits hdr into static headers and templated headers, returning the
+// static ones and a hook that renders the rest per request (nil when nothing
+// is templated). A header name with any templated value goes to the hook
+// with all its values, in order, since the hook replaces the name. When a
+// JWT key is set and no header uses {jwt}, the token is sent as
+// "Authorization: Bearer {jwt}".
+func (t *templateFlags) hook(hdr http.Header, stdin io.Reader) (http.Header, runner.Hook, error) {
+	static := make(http.Header)
+	var dynamic []tmpl.Header
+	usesFeed, usesJWT := false, false
+	for k, vals := range hdr {
+		tps := make([]*tmpl.Template, len(vals))
+		templated := false
+		for i, v := range vals {
+			tps[i] = tmpl.Parse(v)
+			templated = templated || tps[i].Dynamic()
+		}
+		if !templated {
+			static[k] = append([]string(nil), vals...)
+			continue
+		}
+		for _, tp := range tps {
+			dynamic = append(dynamic, tmpl.Header{Name: k, Value: tp})
+			usesFeed = usesFeed || tp.UsesFeed()
+			usesJWT = usesJWT || tp.UsesJWT()
+		}
+	}
+
+	h := &tmpl.Headers{}
+	if t.jwtKey != "" {
+		key, err := readBodyArg(t.jwtKey, stdin)
+		if err != nil {
+			return nil, nil, fmt.Errorf("--jwt-key: %w", err)
+		}
+		signer, err := jwt.NewSigner(t.jwtAlg, key, t.jwtKeyID)
+		if err != nil {
+			return nil, nil, err
+		}
+		h.JWT = &tmpl.JWT{Signer: signer, TTL: t.jwtTTL, Issuer: t.jwtIssuer, Audience: t.jwtAud}
+		for _, c := range t.jwtClaims {
+			cl, err := tmpl.ParseClaim(c)
+			if err != nil {
+				return nil, nil, err
+			}
+			usesFeed = usesFeed || cl.Value.UsesFeed()
+			h.JWT.Claims = append(h.JWT.Claims, cl)
+		}
+		if !usesJWT {
+			static.Del("Authorization")
+			dynamic = append(dynamic, tmpl.Header{Name: "Authorization", Value: tmpl.Parse("Bearer {jwt}")})
+		}
+	} else {
+		if usesJWT {
+			return nil, nil, errors.New("a --header uses {jwt} but --jwt-key is not set")
+		}
+		if len(t.jwtClaims) > 0 {
+			return nil, nil, errors.New("--jwt-claim requires --jwt-key")
+		}
+	}
+
+	if t.feederPath != "" {
+		f, err := feeder.Load(t.feederPath, t.feederMode)
+		if err != nil {
+			return nil, nil, err
+		}
+		h.Feeder = f
+	} else if usesFeed {
+		return nil, nil, errors.New("{feed:...} placeholders require --feeder")
+	}
+
+	if len(dynamic) == 0 {
+		return static, nil, nil
+	}
+	h.Headers = dynamic
+	return static, h, nil
+}
+
+// curlNotes explains the per-request values a printed curl command cannot
+// reproduce.
+func (t *templateFlags) curlNotes(hdr http.Header) []string {
+	var notes []string
+	for k, vals := range hdr {
+		for _, v := range vals {
+			if tmpl.Parse(v).Dynamic() {
+				notes = append(notes, fmt.Sprintf("header %s uses placeholders that curl sends literally", k))
+			}
+		}
+	}
+	sort.Strings(notes)
+	if t.jwtKey != "" {
+		notes = append(notes, "--jwt-key mints a token per request; add -H 'Authorization: Bearer <token>' with a real token")
+	}
+	return notes
+}
@@ -0,0 +1,187 @@
+package commands
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"time"
+)
+
+// statsWriteOut is the --write-out format behind the --stats shorthand.
+const statsWriteOut = "%{stderr}\nTime: %{time_total}s\nStatus: %{http_code}\nBody bytes: %{size_download}\n"
+
+// transferInfo collects the measurements curl exposes as --write-out
+// variables. Times are relative to start, as in curl.
+type transferInfo struct {
+	start        time.Time
+	nameLookup   time.Duration
+	connectStart time.Duration
+	connect      time.Duration
+	appConnect   time.Duration
+	startXfer    time.Duration
+	total        time.Duration
+
+	method       string
+	url          string
+	code         int
+	contentType  string
+	remoteIP     string
+	remotePort   string
+	redirects    int
+	sizeHeader   int64
+	sizeDownload int64
+	sizeUpload   int64
+}
+
+// trace returns ctx with an httptrace hook filling in the timings and the
+// remote address. Hooks already in ctx keep running.
+func (t *transferInfo) trace(ctx context.Context) context.Context {
+	since := func() time.Duration { return time.Since(t.start) }
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSDone:      func(httptrace.DNSDoneInfo) { t.nameLookup = since() },
+		ConnectStart: func(string, string) { t.connectStart = since() },
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				t.connect = since()
+			}
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				t.appConnect = since()
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.remoteIP, t.remotePort, _ = net.SplitHostPort(info.Conn.RemoteAddr().String())
+		},
+		GotFirstResponseByte: func() { t.startXfer = since() },
+	})
+}
+
+// response records the status line and headers of resp.
+func (t *transferInfo) response(resp *http.Response) {
+	t.code = resp.StatusCode
+	t.method = resp.Request.Method
+	t.url = resp.Request.URL.String()
+	t.contentType = resp.Header.Get("Content-Type")
+	size := len(resp.Proto) + len(resp.Status) + 3
+	for k, vals := range resp.Header {
+		for _, v := range vals {
+			size += len(k) + len(v) + 4
+		}
+	}
+	t.sizeHeader = int64(size + 2)
+}
+
+// vars returns the --write-out variables. Like curl, a name lookup that
+// did not happen (IP literals, reused connections) counts as done when the
+// connection started.
+func (t *transferInfo) vars() map[string]any {
+	lookup := t.nameLookup
+	if lookup == 0 {
+		lookup = t.connectStart
+	}
+	speed := int64(0)
+	if t.total > 0 {
+		speed = int64(float64(t.sizeDownload) / t.total.Seconds())
+	}
+	return map[string]any{
+		"content_type":       t.contentType,
+		"http_code":          t.code,
+		"response_code":      t.code,
+		"method":             t.method,
+		"num_redirects":      t.redirects,
+		"remote_ip":          t.remoteIP,
+		"remote_port":        t.remotePort,
+		"size_download":      t.sizeDownload,
+		"size_header":        t.sizeHeader,
+		"size_upload":        t.sizeUpload,
+		"speed_download":     speed,
+		"time_namelookup":    lookup.Seconds(),
+		"time_connect":       t.connect.Seconds(),
+		"time_appconnect":    t.appConnect.Seconds(),
+		"time_starttransfer": t.startXfer.Seconds(),
+		"time_total":         t.total.Seconds(),
+		"url_effective":      t.url,
+	}
+}
+
+// writeOut renders a curl --write-out format: %{variable} expands to a
+// measurement of the transfer, %{json} to all of them as a JSON object,
+// %{stderr} and %{stdout} switch the destination, %% is a literal '%' and
+// \n, \r and \t are control characters. Unknown variables are reported on
+// errw and expand to nothing.
+func writeOut(stdout, errw io.Writer, format string, t *transferInfo) error {
+	vars := t.vars()
+	w := stdout
+	var b strings.Builder
+	flush := func() error {
+		_, err := io.WriteString(w, b.String())
+		b.Reset()
+		return err
+	}
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		switch {
+		case c == '\\' && i+1 < len(format) && strings.IndexByte("nrt\\", format[i+1]) >= 0:
+			i++
+			b.WriteByte(map[byte]byte{'n': '\n', 'r': '\r', 't': '\t', '\\': '\\'}[format[i]])
+		case c == '%' && strings.HasPrefix(format[i+1:], "%"):
+			i++
+			b.WriteByte('%')
+		case c == '%' && strings.HasPrefix(format[i+1:], "{"):
+			end := strings.IndexByte(format[i:], '}')
+			if end < 0 {
+				b.WriteString(format[i:])
+				i = len(format)
+				continue
+			}
+			name := format[i+2 : i+end]
+			i += end
+			switch name {
+			case "stdout", "stderr":
+				if err := flush(); err != nil {
+					return err
+				}
+				w = stdout
+				if name == "stderr" {
+					w = errw
+				}
+			case "json":
+				data, err := json.Marshal(vars)
+				if err != nil {
+					return err
+				}
+				b.Write(data)
+			default:
+				v, ok := vars[name]
+				if !ok {
+					fmt.Fprintf(errw, "warning: unknown --write-out variable: %q\n", name)
+					continue
+				}
+				b.WriteString(formatWriteOutVar(name, v))
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return flush()
+}
+
+func formatWriteOutVar(name string, v any) string {
+	switch x := v.(type) {
+	case float64:
+		return fmt.Sprintf("%.6f", x)
+	case int:
+		if name == "http_code" || name == "response_code" {
+			return fmt.Sprintf("%03d", x)
+		}
+		return fmt.Sprint(x)
+	}
+	return fmt.Sprint(v)
+}
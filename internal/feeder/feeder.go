@@ -0,0 +1,162 @@
+// Package feeder supplies rows of test data (user IDs, tokens, path
+// parameters...) to requests from CSV or JSON files.
+package feeder
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// Feeder hands out rows to concurrent requests. It is safe for concurrent use.
+type Feeder struct {
+	rows   []map[string]string
+	random bool
+	next   atomic.Uint64
+}
+
+// Load reads rows from path. The format is chosen by extension:
+//
+//	.csv            first line is the header
+//	.json           array of objects
+//	.jsonl/.ndjson  one object per line
+//
+// mode is "sequential" (round-robin, the default) or "random".
+func Load(path, mode string) (*Feeder, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("feeder: %w", err)
+	}
+	var rows []map[string]string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		rows, err = parseCSV(data)
+	case ".json":
+		rows, err = parseJSON(data)
+	case ".jsonl", ".ndjson":
+		rows, err = parseJSONLines(data)
+	default:
+		return nil, fmt.Errorf("feeder: unsupported file type %q (use .csv, .json, .jsonl)", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("feeder: %s: %w", path, err)
+	}
+	return New(rows, mode)
+}
+
+// New returns a feeder over rows.
+func New(rows []map[string]string, mode string) (*Feeder, error) {
+	if len(rows) == 0 {
+		return nil, errors.New("feeder: no rows")
+	}
+	f := &Feeder{rows: rows}
+	switch strings.ToLower(mode) {
+	case "", "sequential":
+	case "random":
+		f.random = true
+	default:
+		return nil, fmt.Errorf("feeder: unsupported mode %q (use sequential|random)", mode)
+	}
+	return f, nil
+}
+
+// Columns returns the column names of the first row, sorted.
+func (f *Feeder) Columns() []string {
+	cols := make([]string, 0, len(f.rows[0]))
+	for k := range f.rows[0] {
+		cols = append(cols, k)
+	}
+	sort.Strings(cols)
+	return cols
+}
+
+// Next returns the next row. Rows must not be modified by callers.
+func (f *Feeder) Next() map[string]string {
+	if f.random {
+		return f.rows[rand.IntN(len(f.rows))]
+	}
+	i := f.next.Add(1) - 1
+	return f.rows[i%uint64(len(f.rows))]
+}
+
+func parseCSV(data []byte) ([]map[string]string, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) < 2 {
+		return nil, errors.New("csv needs a header line and at least one row")
+	}
+	header := records[0]
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, rec := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, col := range header {
+			if i < len(rec) {
+				row[strings.TrimSpace(col)] = rec[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func parseJSON(data []byte) ([]map[string]string, error) {
+	var objs []map[string]any
+	if err := json.Unmarshal(data, &objs); err != nil {
+		return nil, err
+	}
+	rows := make([]map[string]string, 0, len(objs))
+	for _, o := range objs {
+		rows = append(rows, stringify(o))
+	}
+	return rows, nil
+}
+
+func parseJSONLines(data []byte) ([]map[string]string, error) {
+	var rows []map[string]string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	line := 0
+	for sc.Scan() {
+		line++
+		b := bytes.TrimSpace(sc.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		var o map[string]any
+		if err := json.Unmarshal(b, &o); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rows = append(rows, stringify(o))
+	}
+	return rows, sc.Err()
+}
+
+// stringify flattens JSON values to strings; nested values keep their JSON form.
+func stringify(o map[string]any) map[string]string {
+	row := make(map[string]string, len(o))
+	for k, v := range o {
+		switch t := v.(type) {
+		case string:
+			row[k] = t
+		case nil:
+			row[k] = ""
+		default:
+			b, _ := json.Marshal(t)
+			row[k] = string(b)
+		}
+	}
+	return row
+}
@@ -0,0 +1,231 @@
+// Package form builds multipart/form-data and URL-encoded request bodies.
+package form
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// randomPrefix marks a file part whose content is generated per request,
+// e.g. "upload=@random:64KiB".
+const randomPrefix = "random:"
+
+// Part is a single form field or file upload.
+type Part struct {
+	Name        string
+	Value       string // literal field value (when File and RandomSize are empty)
+	File        string // path of the file to upload ("-" for stdin)
+	RandomSize  int64  // size of random content generated per request
+	Filename    string // filename reported in Content-Disposition
+	ContentType string // Content-Type of the part
+
+	content []byte
+	isFile  bool
+}
+
+// ParsePart parses a curl -F style specification:
+//
+//	name=value                  plain field
+//	name=<path                  field whose value is read from path
+//	name=@path                  file upload
+//	name=@random:SIZE           file upload with SIZE random bytes per request
+//
+// File uploads accept ";type=..." and ";filename=..." attributes, as in
+// "avatar=@me.png;type=image/png;filename=avatar.png".
+func ParsePart(spec string) (Part, error) {
+	name, value, ok := strings.Cut(spec, "=")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return Part{}, fmt.Errorf("invalid form part (use 'name=value' or 'name=@file'): %q", spec)
+	}
+	p := Part{Name: name}
+	switch {
+	case strings.HasPrefix(value, "@"):
+		p.isFile = true
+		attrs := strings.Split(value[1:], ";")
+		src := attrs[0]
+		for _, a := range attrs[1:] {
+			k, v, _ := strings.Cut(a, "=")
+			switch strings.ToLower(strings.TrimSpace(k)) {
+			case "type":
+				p.ContentType = strings.TrimSpace(v)
+			case "filename":
+				p.Filename = strings.Trim(strings.TrimSpace(v), `"`)
+			default:
+				return Part{}, fmt.Errorf("unsupported form attribute %q in %q", k, spec)
+			}
+		}
+		if strings.HasPrefix(src, randomPrefix) {
+			n, err := ParseSize(strings.TrimPrefix(src, randomPrefix))
+			if err != nil {
+				return Part{}, fmt.Errorf("invalid random size in %q: %w", spec, err)
+			}
+			p.RandomSize = n
+			if p.Filename == "" {
+				p.Filename = name + ".bin"
+			}
+		} else {
+			if src == "" {
+				return Part{}, fmt.Errorf("missing file name in form part: %q", spec)
+			}
+			p.File = src
+			if p.Filename == "" && src != "-" {
+				p.Filename = filepath.Base(src)
+			}
+		}
+		if p.ContentType == "" {
+			p.ContentType = "application/octet-stream"
+		}
+	case strings.HasPrefix(value, "<"):
+		p.File = value[1:]
+		if p.File == "" {
+			return Part{}, fmt.Errorf("missing file name in form part: %q", spec)
+		}
+	default:
+		p.Value = value
+	}
+	return p, nil
+}
+
+// ParseSize parses a byte size such as "512", "64KB", "1.5MiB" or "2g".
+// Decimal (KB, MB, GB) and binary (KiB, MiB, GiB) suffixes are accepted;
+// single-letter suffixes are treated as binary.
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	units := []struct {
+		suffix string
+		mult   float64
+	}{
+		{"kib", 1 << 10}, {"mib", 1 << 20}, {"gib", 1 << 30},
+		{"kb", 1e3}, {"mb", 1e6}, {"gb", 1e9},
+		{"k", 1 << 10}, {"m", 1 << 20}, {"g", 1 << 30},
+		{"b", 1},
+	}
+	lower := strings.ToLower(s)
+	mult := 1.0
+	for _, u := range units {
+		if strings.HasSuffix(lower, u.suffix) {
+			mult = u.mult
+			lower = strings.TrimSpace(strings.TrimSuffix(lower, u.suffix))
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(lower, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid size: %q", s)
+	}
+	return int64(f * mult), nil
+}
+
+// Form is an ordered set of parts. Call Load once before building bodies;
+// file contents are then kept in memory and shared by every request.
+type Form struct {
+	Parts []Part
+}
+
+// Add appends a literal field that is never interpreted as a file.
+func (f *Form) Add(name, value string) {
+	f.Parts = append(f.Parts, Part{Name: name, Value: value})
+}
+
+// Load reads every file referenced by the form. stdin is used for "-".
+func (f *Form) Load(stdin io.Reader) error {
+	for i := range f.Parts {
+		p := &f.Parts[i]
+		if p.File == "" {
+			continue
+		}
+		var data []byte
+		var err error
+		if p.File == "-" {
+			if stdin == nil {
+				return errors.New("stdin is not available")
+			}
+			data, err = io.ReadAll(stdin)
+		} else {
+			data, err = os.ReadFile(p.File)
+		}
+		if err != nil {
+			return fmt.Errorf("read form part %q: %w", p.Name, err)
+		}
+		if p.isFile {
+			p.content = data
+		} else {
+			p.Value = string(data)
+		}
+	}
+	return nil
+}
+
+// Multipart renders the form as multipart/form-data and returns the body and
+// its Content-Type. Every call uses a fresh boundary and regenerates random
+// file contents, so it can be used to build one body per request.
+func (f *Form) Multipart() ([]byte, string, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, p := range f.Parts {
+		if !p.isFile {
+			if err := w.WriteField(p.Name, p.Value); err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(p.Name), escapeQuotes(p.Filename)))
+		h.Set("Content-Type", p.ContentType)
+		pw, err := w.CreatePart(h)
+		if err != nil {
+			return nil, "", err
+		}
+		if p.RandomSize > 0 {
+			if _, err := io.CopyN(pw, rand.Reader, p.RandomSize); err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+		if _, err := pw.Write(p.content); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
+
+// URLEncoded renders the form as application/x-www-form-urlencoded. File
+// parts are encoded with their contents as the value.
+func (f *Form) URLEncoded() ([]byte, string, error) {
+	vals := make([]string, 0, len(f.Parts))
+	for _, p := range f.Parts {
+		v := p.Value
+		switch {
+		case p.RandomSize > 0:
+			b := make([]byte, p.RandomSize)
+			if _, err := rand.Read(b); err != nil {
+				return nil, "", err
+			}
+			v = string(b)
+		case p.isFile:
+			v = string(p.content)
+		}
+		vals = append(vals, url.QueryEscape(p.Name)+"="+url.QueryEscape(v))
+	}
+	return []byte(strings.Join(vals, "&")), "application/x-www-form-urlencoded", nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
@@ -0,0 +1,233 @@
+// Package history keeps finished results in a local directory so they can
+// be listed, shown and followed over time.
+//
+// A store is a directory holding results/<id>.json, the saved Result
+// documents, and index.jsonl, one Entry per line in the order they were
+// saved, so listing does not need to read every result.
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/JeanGrijp/stress-test/internal/report"
+)
+
+const (
+	indexFile  = "index.jsonl"
+	resultsDir = "results"
+)
+
+// Metrics lists the metrics an Entry holds, as accepted by Entry.Metric.
+var Metrics = []string{"rps", "p50", "p90", "p95", "p99", "error_rate", "requests"}
+
+// Entry is the index line of a saved result: what it ran against, its tags
+// and its headline metrics. Latencies are in milliseconds and the error
+// rate in percent.
+type Entry struct {
+	ID        string            `json:"id"`
+	Time      time.Time         `json:"time"`
+	Command   string            `json:"command,omitempty"`
+	Target    string            `json:"target,omitempty"`
+	Tags      map[string]string `json:"tags,omitempty"`
+	Requests  int               `json:"requests"`
+	RPS       float64           `json:"rps"`
+	P50       float64           `json:"p50_ms"`
+	P90       float64           `json:"p90_ms"`
+	P95       float64           `json:"p95_ms"`
+	P99       float64           `json:"p99_ms"`
+	ErrorRate float64           `json:"error_rate"`
+}
+
+// Metric returns the value of metric, one of Metrics.
+func (e Entry) Metric(metric string) (float64, bool) {
+	switch metric {
+	case "rps":
+		return e.RPS, true
+	case "p50":
+		return e.P50, true
+	case "p90":
+		return e.P90, true
+	case "p95":
+		return e.P95, true
+	case "p99":
+		return e.P99, true
+	case "error_rate":
+		return e.ErrorRate, true
+	case "requests":
+		return float64(e.Requests), true
+	}
+	return 0, false
+}
+
+// Filter selects entries; empty fields match everything and every tag
+// must match.
+type Filter struct {
+	Command string
+	Target  string
+	Tags    map[string]string
+}
+
+func (f Filter) match(e Entry) bool {
+	if f.Command != "" && f.Command != e.Command {
+		return false
+	}
+	if f.Target != "" && f.Target != e.Target {
+		return false
+	}
+	for k, v := range f.Tags {
+		if e.Tags[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Store is a history directory.
+type Store struct {
+	Dir string
+}
+
+// Open returns the store in dir, creating the directory when needed.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Join(dir, resultsDir), 0755); err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	return &Store{Dir: dir}, nil
+}
+
+// Save stores res and appends it to the index.
+func (s *Store) Save(res *report.Result) (Entry, error) {
+	e := newEntry(res)
+	data, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return e, err
+	}
+	if err := os.WriteFile(s.resultPath(e.ID), append(data, '\n'), 0644); err != nil {
+		return e, fmt.Errorf("history: %w", err)
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return e, err
+	}
+	f, err := os.OpenFile(filepath.Join(s.Dir, indexFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return e, fmt.Errorf("history: %w", err)
+	}
+	// one write per line keeps concurrent appends from interleaving
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return e, fmt.Errorf("history: %w", err)
+	}
+	return e, f.Close()
+}
+
+func newEntry(res *report.Result) Entry {
+	t, err := time.Parse(time.RFC3339, res.Timestamp)
+	if err != nil {
+		t = time.Now().UTC()
+	}
+	var suffix [3]byte
+	_, _ = rand.Read(suffix[:])
+	e := Entry{
+		ID:        t.UTC().Format("20060102-150405") + "-" + hex.EncodeToString(suffix[:]),
+		Time:      t,
+		Command:   res.Command,
+		Target:    res.Target(),
+		Tags:      res.Tags,
+		Requests:  res.TotalRequests,
+		RPS:       res.RPS,
+		ErrorRate: res.FailureRate(),
+	}
+	if l := res.Latency; l != nil {
+		e.P50, e.P90, e.P95, e.P99 = l.P50, l.P90, l.P95, l.P99
+	}
+	return e
+}
+
+func (s *Store) resultPath(id string) string {
+	return filepath.Join(s.Dir, resultsDir, id+".json")
+}
+
+// List returns the entries matching f, oldest first.
+func (s *Store) List(f Filter) ([]Entry, error) {
+	data, err := os.ReadFile(filepath.Join(s.Dir, indexFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	var entries []Entry
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, fmt.Errorf("history: %s line %d: %w", indexFile, n, err)
+		}
+		if f.match(e) {
+			entries = append(entries, e)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+	return entries, nil
+}
+
+// Find returns the entry with id, which may also be a unique prefix of an
+// id or "latest" for the most recent entry.
+func (s *Store) Find(id string) (Entry, error) {
+	entries, err := s.List(Filter{})
+	if err != nil {
+		return Entry{}, err
+	}
+	if len(entries) == 0 {
+		return Entry{}, fmt.Errorf("history %s is empty", s.Dir)
+	}
+	if id == "latest" {
+		return entries[len(entries)-1], nil
+	}
+	var found []Entry
+	for _, e := range entries {
+		if e.ID == id {
+			return e, nil
+		}
+		if strings.HasPrefix(e.ID, id) {
+			found = append(found, e)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return Entry{}, fmt.Errorf("no result %q in history %s", id, s.Dir)
+	case 1:
+		return found[0], nil
+	}
+	return Entry{}, fmt.Errorf("%q matches %d results; use more of the id", id, len(found))
+}
+
+// Load reads the saved result of e.
+func (s *Store) Load(e Entry) (*report.Result, error) {
+	f, err := os.Open(s.resultPath(e.ID))
+	if err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	defer f.Close()
+	return report.Read(f)
+}
@@ -0,0 +1,59 @@
+package history
+
+import (
+	"math"
+
+	"github.com/JeanGrijp/stress-test/internal/report"
+)
+
+// outlierScore is the modified z-score above which a value is an outlier
+// (Iglewicz and Hoaglin).
+const outlierScore = 3.5
+
+// Point is one entry of a Trend.
+type Point struct {
+	Entry Entry   `json:"entry"`
+	Value float64 `json:"value"`
+	// Outlier is "high" or "low" when the value is far from the median of
+	// the trend.
+	Outlier string `json:"outlier,omitempty"`
+}
+
+// Trend follows metric across entries, in their order, and flags the
+// outliers: values whose modified z-score, based on the median and the
+// median absolute deviation, exceeds 3.5. With fewer than 3 entries
+// nothing is flagged.
+func Trend(entries []Entry, metric string) []Point {
+	points := make([]Point, 0, len(entries))
+	values := make([]float64, 0, len(entries))
+	for _, e := range entries {
+		v, _ := e.Metric(metric)
+		points = append(points, Point{Entry: e, Value: v})
+		values = append(values, v)
+	}
+	if len(values) < 3 {
+		return points
+	}
+	med := report.Median(values)
+	dev := make([]float64, len(values))
+	for i, v := range values {
+		dev[i] = math.Abs(v - med)
+	}
+	mad := report.Median(dev)
+	for i, v := range values {
+		var far bool
+		if mad == 0 {
+			// most values are equal: any other one stands out
+			far = v != med
+		} else {
+			far = 0.6745*math.Abs(v-med)/mad > outlierScore
+		}
+		switch {
+		case far && v > med:
+			points[i].Outlier = "high"
+		case far:
+			points[i].Outlier = "low"
+		}
+	}
+	return points
+}
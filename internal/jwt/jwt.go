@@ -0,0 +1,130 @@
+// Package jwt mints signed JSON Web Tokens (HS256, RS256 and ES256).
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Signer signs tokens with a key parsed once at construction time.
+type Signer struct {
+	alg   string
+	keyID string
+	hmac  []byte
+	rsa   *rsa.PrivateKey
+	ec    *ecdsa.PrivateKey
+}
+
+// NewSigner parses key for alg. HS256 takes the raw secret; RS256 and ES256
+// take a PEM private key (PKCS#1, PKCS#8 or SEC 1). kid, when set, is added
+// to the token header.
+func NewSigner(alg string, key []byte, kid string) (*Signer, error) {
+	s := &Signer{alg: strings.ToUpper(alg), keyID: kid}
+	switch s.alg {
+	case "HS256":
+		if len(key) == 0 {
+			return nil, errors.New("jwt: HS256 needs a non-empty secret")
+		}
+		s.hmac = key
+	case "RS256":
+		k, err := parsePrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		rk, ok := k.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("jwt: RS256 needs an RSA private key")
+		}
+		s.rsa = rk
+	case "ES256":
+		k, err := parsePrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		ek, ok := k.(*ecdsa.PrivateKey)
+		if !ok || ek.Curve != elliptic.P256() {
+			return nil, errors.New("jwt: ES256 needs a P-256 EC private key")
+		}
+		s.ec = ek
+	default:
+		return nil, fmt.Errorf("jwt: unsupported algorithm %q (use HS256|RS256|ES256)", alg)
+	}
+	return s, nil
+}
+
+// Sign encodes claims and returns the compact serialized token.
+func (s *Signer) Sign(claims map[string]any) (string, error) {
+	header := map[string]string{"alg": s.alg, "typ": "JWT"}
+	if s.keyID != "" {
+		header["kid"] = s.keyID
+	}
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("jwt: encode claims: %w", err)
+	}
+	enc := base64.RawURLEncoding
+	signingInput := enc.EncodeToString(h) + "." + enc.EncodeToString(c)
+
+	sig, err := s.sign([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + enc.EncodeToString(sig), nil
+}
+
+func (s *Signer) sign(data []byte) ([]byte, error) {
+	switch s.alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, s.hmac)
+		mac.Write(data)
+		return mac.Sum(nil), nil
+	case "RS256":
+		sum := sha256.Sum256(data)
+		return rsa.SignPKCS1v15(rand.Reader, s.rsa, crypto.SHA256, sum[:])
+	case "ES256":
+		sum := sha256.Sum256(data)
+		r, ss, err := ecdsa.Sign(rand.Reader, s.ec, sum[:])
+		if err != nil {
+			return nil, err
+		}
+		// JWS uses the fixed-size r||s encoding rather than ASN.1
+		out := make([]byte, 64)
+		r.FillBytes(out[:32])
+		ss.FillBytes(out[32:])
+		return out, nil
+	}
+	return nil, fmt.Errorf("jwt: unsupported algorithm %q", s.alg)
+}
+
+func parsePrivateKey(data []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("jwt: key is not PEM encoded")
+	}
+	if k, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	if k, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	return nil, fmt.Errorf("jwt: unsupported private key type %q", block.Type)
+}
@@ -0,0 +1,163 @@
+package openapi
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxDepth bounds example generation for deeply nested or recursive schemas.
+const maxDepth = 8
+
+// Example returns a value matching schema: its example, default, first enum
+// value or const when declared, otherwise a placeholder built from its type
+// and format. Objects include all non-readOnly properties.
+func (s *Spec) Example(schema any) any {
+	return s.example(schema, 0)
+}
+
+func (s *Spec) example(schema any, depth int) any {
+	m := s.resolveMap(schema)
+	if m == nil || depth > maxDepth {
+		return nil
+	}
+	for _, k := range []string{"example", "default", "const"} {
+		if v, ok := m[k]; ok {
+			return v
+		}
+	}
+	if ex, ok := m["examples"].([]any); ok && len(ex) > 0 {
+		return ex[0]
+	}
+	if enum, ok := m["enum"].([]any); ok && len(enum) > 0 {
+		return enum[0]
+	}
+	if all, ok := m["allOf"].([]any); ok {
+		merged := map[string]any{}
+		for _, sub := range all {
+			if obj, ok := s.example(sub, depth+1).(map[string]any); ok {
+				for k, v := range obj {
+					merged[k] = v
+				}
+			}
+		}
+		if props := s.objectExample(m, depth); props != nil {
+			for k, v := range props {
+				merged[k] = v
+			}
+		}
+		return merged
+	}
+	for _, k := range []string{"oneOf", "anyOf"} {
+		if alts, ok := m[k].([]any); ok && len(alts) > 0 {
+			return s.example(alts[0], depth+1)
+		}
+	}
+
+	switch schemaType(m) {
+	case "object":
+		obj := s.objectExample(m, depth)
+		if obj == nil {
+			obj = map[string]any{}
+		}
+		return obj
+	case "array":
+		item := s.example(m["items"], depth+1)
+		if item == nil {
+			return []any{}
+		}
+		return []any{item}
+	case "integer":
+		if v, ok := m["minimum"]; ok {
+			return v
+		}
+		return 1
+	case "number":
+		if v, ok := m["minimum"]; ok {
+			return v
+		}
+		return 1.5
+	case "boolean":
+		return true
+	case "string":
+		return stringExample(m)
+	}
+	return nil
+}
+
+func (s *Spec) objectExample(m map[string]any, depth int) map[string]any {
+	props, ok := m["properties"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	names := make([]string, 0, len(props))
+	for n := range props {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	obj := make(map[string]any, len(names))
+	for _, n := range names {
+		p := s.resolveMap(props[n])
+		if ro, _ := p["readOnly"].(bool); ro {
+			continue
+		}
+		if v := s.example(props[n], depth+1); v != nil {
+			obj[n] = v
+		}
+	}
+	return obj
+}
+
+// schemaType returns the schema's type, picking the first non-null type of
+// an OpenAPI 3.1 type list and inferring object/array from keywords.
+func schemaType(m map[string]any) string {
+	switch t := m["type"].(type) {
+	case string:
+		return t
+	case []any:
+		for _, e := range t {
+			if s, ok := e.(string); ok && s != "null" {
+				return s
+			}
+		}
+	}
+	if _, ok := m["properties"]; ok {
+		return "object"
+	}
+	if _, ok := m["items"]; ok {
+		return "array"
+	}
+	return ""
+}
+
+func stringExample(m map[string]any) string {
+	format, _ := m["format"].(string)
+	switch format {
+	case "date-time":
+		return "2024-01-01T00:00:00Z"
+	case "date":
+		return "2024-01-01"
+	case "time":
+		return "12:00:00"
+	case "uuid":
+		return "3fa85f64-5717-4562-b3fc-2c963f66afa6"
+	case "email":
+		return "user@example.com"
+	case "uri", "url":
+		return "https://example.com"
+	case "hostname":
+		return "example.com"
+	case "ipv4":
+		return "192.0.2.1"
+	case "ipv6":
+		return "2001:db8::1"
+	case "byte":
+		return "c3RyZXNzLXRlc3Q="
+	case "password":
+		return "secret"
+	}
+	v := "string"
+	if n, ok := m["minLength"].(int); ok && n > len(v) {
+		v += strings.Repeat("x", n-len(v))
+	}
+	return v
+}
@@ -0,0 +1,330 @@
+// Package openapi reads the parts of an OpenAPI 3 document needed to
+// generate load scenarios: servers, operations, parameters, request bodies
+// and declared response codes. Local $ref pointers are resolved and example
+// values are derived from examples or, failing that, from schemas.
+package openapi
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is a parsed OpenAPI 3 document.
+type Spec struct {
+	root map[string]any
+}
+
+// Operation is one method on one path.
+type Operation struct {
+	Method     string
+	Path       string
+	ID         string
+	Summary    string
+	Tags       []string
+	Deprecated bool
+	Params     []Parameter
+	Body       *Body
+	// Statuses are the declared 2xx and 3xx response codes.
+	Statuses []int
+}
+
+// Parameter is a path, query, header or cookie parameter.
+type Parameter struct {
+	Name     string
+	In       string
+	Required bool
+	// Example is the parameter's example, or one generated from its schema.
+	Example any
+}
+
+// Body is the request body chosen for an operation.
+type Body struct {
+	ContentType string
+	Required    bool
+	Example     any
+}
+
+// methods lists the operations of a path item in output order.
+var methods = []string{"get", "post", "put", "patch", "delete", "head", "options"}
+
+// Load reads a JSON or YAML document from path.
+func Load(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Parse parses a JSON or YAML document. Only OpenAPI 3.x is supported.
+func Parse(data []byte) (*Spec, error) {
+	var raw any
+	// YAML is a superset of JSON, so one decoder handles both
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing OpenAPI document: %w", err)
+	}
+	root, ok := normalize(raw).(map[string]any)
+	if !ok {
+		return nil, errors.New("OpenAPI document is not an object")
+	}
+	version, _ := root["openapi"].(string)
+	if !strings.HasPrefix(version, "3.") {
+		if _, ok := root["swagger"]; ok {
+			return nil, errors.New("Swagger 2.0 documents are not supported; convert to OpenAPI 3 first")
+		}
+		return nil, fmt.Errorf("unsupported OpenAPI version %q (want 3.x)", version)
+	}
+	return &Spec{root: root}, nil
+}
+
+// normalize turns YAML maps with non-string keys (such as unquoted
+// response codes) into map[string]any.
+func normalize(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, e := range t {
+			t[k] = normalize(e)
+		}
+		return t
+	case map[any]any:
+		m := make(map[string]any, len(t))
+		for k, e := range t {
+			m[fmt.Sprint(k)] = normalize(e)
+		}
+		return m
+	case []any:
+		for i, e := range t {
+			t[i] = normalize(e)
+		}
+		return t
+	}
+	return v
+}
+
+// Servers returns the server URLs with variables set to their defaults.
+func (s *Spec) Servers() []string {
+	list, _ := s.root["servers"].([]any)
+	var out []string
+	for _, e := range list {
+		srv, _ := e.(map[string]any)
+		u, _ := srv["url"].(string)
+		if u == "" {
+			continue
+		}
+		vars, _ := srv["variables"].(map[string]any)
+		for name, v := range vars {
+			vm, _ := v.(map[string]any)
+			if def, ok := vm["default"]; ok {
+				u = strings.ReplaceAll(u, "{"+name+"}", fmt.Sprint(def))
+			}
+		}
+		out = append(out, u)
+	}
+	return out
+}
+
+// Operations returns every operation, ordered by path and then method.
+func (s *Spec) Operations() []Operation {
+	paths, _ := s.root["paths"].(map[string]any)
+	keys := make([]string, 0, len(paths))
+	for p := range paths {
+		keys = append(keys, p)
+	}
+	sort.Strings(keys)
+
+	var ops []Operation
+	for _, p := range keys {
+		item := s.resolveMap(paths[p])
+		if item == nil {
+			continue
+		}
+		shared := s.params(item["parameters"])
+		for _, m := range methods {
+			raw := s.resolveMap(item[m])
+			if raw == nil {
+				continue
+			}
+			op := Operation{Method: strings.ToUpper(m), Path: p}
+			op.ID, _ = raw["operationId"].(string)
+			op.Summary, _ = raw["summary"].(string)
+			op.Deprecated, _ = raw["deprecated"].(bool)
+			if tags, ok := raw["tags"].([]any); ok {
+				for _, t := range tags {
+					op.Tags = append(op.Tags, fmt.Sprint(t))
+				}
+			}
+			op.Params = mergeParams(shared, s.params(raw["parameters"]))
+			op.Body = s.body(raw["requestBody"])
+			op.Statuses = successStatuses(s.resolveMap(raw["responses"]))
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
+
+func (s *Spec) params(v any) []Parameter {
+	list, _ := v.([]any)
+	var out []Parameter
+	for _, e := range list {
+		m := s.resolveMap(e)
+		if m == nil {
+			continue
+		}
+		p := Parameter{}
+		p.Name, _ = m["name"].(string)
+		p.In, _ = m["in"].(string)
+		p.Required, _ = m["required"].(bool)
+		if p.In == "path" {
+			p.Required = true
+		}
+		p.Example = s.mediaExample(m)
+		out = append(out, p)
+	}
+	return out
+}
+
+// mergeParams lets operation parameters override path-level ones with the
+// same name and location.
+func mergeParams(shared, own []Parameter) []Parameter {
+	out := append([]Parameter(nil), own...)
+	for _, sp := range shared {
+		overridden := false
+		for _, op := range own {
+			if op.Name == sp.Name && op.In == sp.In {
+				overridden = true
+				break
+			}
+		}
+		if !overridden {
+			out = append(out, sp)
+		}
+	}
+	return out
+}
+
+// contentPreference orders request body media types; JSON bodies are the
+// most useful for load tests.
+func contentPreference(ct string) int {
+	ct = strings.ToLower(ct)
+	switch {
+	case ct == "application/json":
+		return 0
+	case strings.HasSuffix(ct, "+json"):
+		return 1
+	case ct == "application/x-www-form-urlencoded":
+		return 2
+	case strings.HasPrefix(ct, "text/"):
+		return 3
+	case ct == "multipart/form-data":
+		return 5
+	}
+	return 4
+}
+
+func (s *Spec) body(v any) *Body {
+	rb := s.resolveMap(v)
+	if rb == nil {
+		return nil
+	}
+	content, _ := rb["content"].(map[string]any)
+	if len(content) == 0 {
+		return nil
+	}
+	types := make([]string, 0, len(content))
+	for ct := range content {
+		types = append(types, ct)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		pi, pj := contentPreference(types[i]), contentPreference(types[j])
+		if pi != pj {
+			return pi < pj
+		}
+		return types[i] < types[j]
+	})
+	ct := types[0]
+	b := &Body{ContentType: ct}
+	b.Required, _ = rb["required"].(bool)
+	b.Example = s.mediaExample(s.resolveMap(content[ct]))
+	return b
+}
+
+// mediaExample returns the example of a parameter or media type object:
+// example, the first of examples, or one generated from schema.
+func (s *Spec) mediaExample(m map[string]any) any {
+	if m == nil {
+		return nil
+	}
+	if v, ok := m["example"]; ok {
+		return v
+	}
+	if ex, ok := m["examples"].(map[string]any); ok && len(ex) > 0 {
+		names := make([]string, 0, len(ex))
+		for n := range ex {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if e := s.resolveMap(ex[names[0]]); e != nil {
+			if v, ok := e["value"]; ok {
+				return v
+			}
+		}
+	}
+	return s.Example(m["schema"])
+}
+
+// successStatuses returns the explicit 2xx and 3xx response codes.
+func successStatuses(responses map[string]any) []int {
+	var codes []int
+	for k := range responses {
+		code, err := strconv.Atoi(k)
+		if err == nil && code >= 200 && code < 400 {
+			codes = append(codes, code)
+		}
+	}
+	sort.Ints(codes)
+	return codes
+}
+
+// resolveMap follows local $ref pointers ("#/components/...") and returns
+// the target object, or nil.
+func (s *Spec) resolveMap(v any) map[string]any {
+	for i := 0; i < 32; i++ {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return m
+		}
+		v = s.lookup(ref)
+	}
+	return nil // reference cycle
+}
+
+func (s *Spec) lookup(ref string) any {
+	p, ok := strings.CutPrefix(ref, "#/")
+	if !ok {
+		return nil // external references are not supported
+	}
+	var cur any = s.root
+	for _, tok := range strings.Split(p, "/") {
+		tok = strings.ReplaceAll(strings.ReplaceAll(tok, "~1", "/"), "~0", "~")
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil
+		}
+		cur = m[tok]
+	}
+	return cur
+}
@@ -0,0 +1,294 @@
+// Package proxy implements a reverse proxy that injects network and server
+// faults (latency, jitter, bandwidth limits, error responses, connection
+// resets and truncated bodies) per route, for local resilience testing.
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultStatsPath is where the proxy serves its own counters.
+const DefaultStatsPath = "/__proxy/stats"
+
+// Config configures the proxy.
+type Config struct {
+	Upstream *url.URL
+	// Rules are matched by longest path prefix (method-specific rules win
+	// ties). Requests matching no rule use Default.
+	Rules   []Rule
+	Default Rule
+	// StatsPath serves JSON counters; empty disables it.
+	StatsPath string
+}
+
+// Proxy is an http.Handler forwarding to the upstream with injected faults.
+type Proxy struct {
+	cfg   Config
+	rp    *httputil.ReverseProxy
+	rules []Rule
+
+	mu    sync.Mutex
+	stats map[string]*routeStats
+}
+
+type routeStats struct {
+	requests       int64
+	forwarded      int64
+	injectedErrors int64
+	resets         int64
+	truncated      int64
+	upstreamErrors int64
+	statuses       map[int]int64
+	addedLatency   time.Duration
+}
+
+// New returns a fault-injecting proxy.
+func New(cfg Config) (*Proxy, error) {
+	if cfg.Upstream == nil || cfg.Upstream.Host == "" {
+		return nil, fmt.Errorf("proxy: upstream URL is required")
+	}
+	if cfg.Default.Prefix == "" {
+		cfg.Default.Prefix = "/"
+	}
+	rules := append([]Rule(nil), cfg.Rules...)
+	sort.SliceStable(rules, func(i, j int) bool {
+		if len(rules[i].Prefix) != len(rules[j].Prefix) {
+			return len(rules[i].Prefix) > len(rules[j].Prefix)
+		}
+		return rules[i].Method != "" && rules[j].Method == ""
+	})
+	p := &Proxy{cfg: cfg, rules: rules, stats: make(map[string]*routeStats)}
+	upstream := cfg.Upstream
+	p.rp = &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(upstream)
+			pr.SetXForwarded()
+		},
+		FlushInterval: -1, // stream bodies so bandwidth limits are visible immediately
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			// matching is cheap, so re-match instead of threading the rule through
+			p.count(p.match(r), func(s *routeStats) { s.upstreamErrors++ })
+			http.Error(w, "upstream error: "+err.Error(), http.StatusBadGateway)
+		},
+	}
+	return p, nil
+}
+
+func (p *Proxy) match(r *http.Request) Rule {
+	for _, rule := range p.rules {
+		if rule.matches(r) {
+			return rule
+		}
+	}
+	return p.cfg.Default
+}
+
+func (p *Proxy) count(rule Rule, f func(*routeStats)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s, ok := p.stats[rule.Name()]
+	if !ok {
+		s = &routeStats{statuses: make(map[int]int64)}
+		p.stats[rule.Name()] = s
+	}
+	f(s)
+}
+
+// ServeHTTP implements http.Handler.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if p.cfg.StatsPath != "" && r.URL.Path == p.cfg.StatsPath {
+		p.serveStats(w)
+		return
+	}
+	rule := p.match(r)
+
+	delay := rule.Latency
+	if rule.Jitter > 0 {
+		delay += time.Duration(rand.Int64N(int64(rule.Jitter)))
+	}
+	p.count(rule, func(s *routeStats) {
+		s.requests++
+		s.addedLatency += delay
+	})
+	if delay > 0 {
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-r.Context().Done():
+			t.Stop()
+			return
+		}
+	}
+
+	// one draw decides which fault (if any) applies, so rates are exclusive
+	x := rand.Float64()
+	switch {
+	case x < rule.ResetRate:
+		p.count(rule, func(s *routeStats) { s.resets++ })
+		panic(http.ErrAbortHandler)
+	case x < rule.ResetRate+rule.ErrorRate:
+		status := rule.ErrorStatus
+		if status == 0 {
+			status = http.StatusServiceUnavailable
+		}
+		p.count(rule, func(s *routeStats) {
+			s.injectedErrors++
+			s.statuses[status]++
+		})
+		w.Header().Set("X-Fault-Injected", "error")
+		http.Error(w, http.StatusText(status)+" (injected)", status)
+		return
+	}
+	truncate := x < rule.ResetRate+rule.ErrorRate+rule.TruncateRate
+
+	fw := &faultWriter{ResponseWriter: w, ctx: r.Context(), rule: rule, truncate: truncate, start: time.Now()}
+	defer func() {
+		p.count(rule, func(s *routeStats) {
+			s.forwarded++
+			if fw.status != 0 {
+				s.statuses[fw.status]++
+			}
+			if fw.truncated {
+				s.truncated++
+			}
+		})
+	}()
+	p.rp.ServeHTTP(fw, r)
+}
+
+// faultWriter throttles and truncates the proxied response.
+type faultWriter struct {
+	http.ResponseWriter
+	ctx       context.Context // of the request; throttling stops when done
+	rule      Rule
+	truncate  bool
+	limit     int64 // body bytes allowed before truncation
+	written   int64
+	status    int
+	start     time.Time
+	truncated bool
+}
+
+func (w *faultWriter) WriteHeader(code int) {
+	w.status = code
+	if w.truncate {
+		w.limit = w.rule.TruncateAfter
+		if w.limit == 0 {
+			if n, err := strconv.ParseInt(w.Header().Get("Content-Length"), 10, 64); err == nil {
+				w.limit = n / 2
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *faultWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.truncate && w.written+int64(len(b)) > w.limit {
+		n := w.limit - w.written
+		if n > 0 {
+			_, _ = w.ResponseWriter.Write(b[:n])
+		}
+		w.truncated = true
+		_ = http.NewResponseController(w.ResponseWriter).Flush()
+		panic(http.ErrAbortHandler)
+	}
+	if w.rule.Bandwidth <= 0 {
+		n, err := w.ResponseWriter.Write(b)
+		w.written += int64(n)
+		return n, err
+	}
+	// throttle in slices of ~1/10s so the rate stays smooth
+	slice := max(w.rule.Bandwidth/10, 1)
+	total := 0
+	for len(b) > 0 {
+		chunk := b[:min(int64(len(b)), slice)]
+		n, err := w.ResponseWriter.Write(chunk)
+		total += n
+		w.written += int64(n)
+		if err != nil {
+			return total, err
+		}
+		_ = http.NewResponseController(w.ResponseWriter).Flush()
+		b = b[n:]
+		due := time.Duration(float64(w.written) / float64(w.rule.Bandwidth) * float64(time.Second))
+		if wait := due - time.Since(w.start); wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-t.C:
+			case <-w.ctx.Done():
+				t.Stop()
+				return total, w.ctx.Err()
+			}
+		}
+	}
+	return total, nil
+}
+
+// Flush implements http.Flusher.
+func (w *faultWriter) Flush() {
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (w *faultWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// RouteStats are the counters of one route in the stats document.
+type RouteStats struct {
+	Requests       int64            `json:"requests"`
+	Forwarded      int64            `json:"forwarded"`
+	InjectedErrors int64            `json:"injected_errors"`
+	Resets         int64            `json:"resets"`
+	Truncated      int64            `json:"truncated"`
+	UpstreamErrors int64            `json:"upstream_errors"`
+	Statuses       map[string]int64 `json:"statuses"`
+	MeanAddedMS    float64          `json:"mean_added_latency_ms"`
+}
+
+// Snapshot returns the counters per route.
+func (p *Proxy) Snapshot() map[string]RouteStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make(map[string]RouteStats, len(p.stats))
+	for name, s := range p.stats {
+		rs := RouteStats{
+			Requests:       s.requests,
+			Forwarded:      s.forwarded,
+			InjectedErrors: s.injectedErrors,
+			Resets:         s.resets,
+			Truncated:      s.truncated,
+			UpstreamErrors: s.upstreamErrors,
+			Statuses:       make(map[string]int64, len(s.statuses)),
+		}
+		for code, n := range s.statuses {
+			rs.Statuses[strconv.Itoa(code)] = n
+		}
+		if s.requests > 0 {
+			rs.MeanAddedMS = float64(s.addedLatency) / float64(s.requests) / float64(time.Millisecond)
+		}
+		out[name] = rs
+	}
+	return out
+}
+
+func (p *Proxy) serveStats(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(map[string]any{
+		"upstream": p.cfg.Upstream.String(),
+		"routes":   p.Snapshot(),
+	})
+}
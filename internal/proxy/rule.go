@@ -0,0 +1,182 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JeanGrijp/stress-test/internal/form"
+)
+
+// Rule describes the faults injected for requests matching a route.
+type Rule struct {
+	// Method restricts the rule to one HTTP method (empty matches all).
+	Method string
+	// Prefix is the URL path prefix the rule applies to ("/" matches all).
+	Prefix string
+
+	Latency time.Duration // added before forwarding
+	Jitter  time.Duration // random extra latency in [0, Jitter)
+	// Bandwidth limits response bodies to this many bytes per second.
+	Bandwidth int64
+	// ErrorRate is the probability (0..1) of answering ErrorStatus without
+	// contacting the upstream.
+	ErrorRate   float64
+	ErrorStatus int
+	// ResetRate is the probability of dropping the connection without a
+	// response.
+	ResetRate float64
+	// TruncateRate is the probability of cutting the response body short.
+	TruncateRate float64
+	// TruncateAfter is how many body bytes are sent before a truncation;
+	// 0 sends half of the Content-Length (or nothing when unknown).
+	TruncateAfter int64
+}
+
+// Name returns the route label used in stats, e.g. "POST /api".
+func (r Rule) Name() string {
+	if r.Method == "" {
+		return r.Prefix
+	}
+	return r.Method + " " + r.Prefix
+}
+
+func (r Rule) matches(req *http.Request) bool {
+	if r.Method != "" && !strings.EqualFold(r.Method, req.Method) {
+		return false
+	}
+	return strings.HasPrefix(req.URL.Path, r.Prefix)
+}
+
+// ParseRule parses a route specification:
+//
+//	[METHOD ]PREFIX[:key=value,key=value...]
+//
+// Keys: latency, jitter, bandwidth (size per second, e.g. 64KiB),
+// error-rate, error-status, reset-rate, truncate-rate, truncate-after.
+// Rates accept fractions (0.05) or percentages (5%) and may not add up to
+// more than 1. Unset keys inherit from base. The options start after the
+// last ':' followed by a key=value list, so prefixes such as
+// /v1/items:batch keep their colon.
+func ParseRule(spec string, base Rule) (Rule, error) {
+	r := base
+	route, opts := splitRoute(spec)
+	route = strings.TrimSpace(route)
+	if m, p, ok := strings.Cut(route, " "); ok {
+		r.Method = strings.ToUpper(strings.TrimSpace(m))
+		route = strings.TrimSpace(p)
+	} else {
+		r.Method = ""
+	}
+	if !strings.HasPrefix(route, "/") {
+		return Rule{}, fmt.Errorf("invalid route %q: path prefix must start with '/'", spec)
+	}
+	r.Prefix = route
+	if strings.TrimSpace(opts) == "" {
+		return r, nil
+	}
+	for _, kv := range strings.Split(opts, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok {
+			return Rule{}, fmt.Errorf("invalid route option %q in %q (use key=value)", kv, spec)
+		}
+		if err := r.set(strings.TrimSpace(k), strings.TrimSpace(v)); err != nil {
+			return Rule{}, fmt.Errorf("route %q: %w", spec, err)
+		}
+	}
+	if err := r.CheckRates(); err != nil {
+		return Rule{}, fmt.Errorf("route %q: %w", spec, err)
+	}
+	return r, nil
+}
+
+// splitRoute splits a route specification into the route and its options
+// at the last ':' followed by a key=value list; opts is "" when there is
+// none.
+func splitRoute(spec string) (route, opts string) {
+	for i := strings.LastIndex(spec, ":"); i >= 0; i = strings.LastIndex(spec[:i], ":") {
+		if isOptionList(spec[i+1:]) {
+			return spec[:i], spec[i+1:]
+		}
+	}
+	return spec, ""
+}
+
+// isOptionList reports whether s is empty or looks like key=value pairs
+// separated by commas, with keys made of lowercase letters and dashes.
+func isOptionList(s string) bool {
+	if strings.TrimSpace(s) == "" {
+		return true
+	}
+	for _, kv := range strings.Split(s, ",") {
+		k, _, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok || k == "" || strings.Trim(k, "abcdefghijklmnopqrstuvwxyz-") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckRates reports an error when the reset, error and truncate rates of
+// r add up to more than 1: one draw picks the fault of a request, so the
+// rates are exclusive and the last ones would silently be cut short.
+func (r Rule) CheckRates() error {
+	// allow for rounding, e.g. 0.1+0.2+0.7
+	if sum := r.ResetRate + r.ErrorRate + r.TruncateRate; sum > 1+1e-9 {
+		return fmt.Errorf("reset, error and truncate rates add up to %.4g, more than 1", sum)
+	}
+	return nil
+}
+
+func (r *Rule) set(key, val string) error {
+	var err error
+	switch key {
+	case "latency":
+		r.Latency, err = time.ParseDuration(val)
+	case "jitter":
+		r.Jitter, err = time.ParseDuration(val)
+	case "bandwidth":
+		r.Bandwidth, err = form.ParseSize(val)
+	case "error-rate":
+		r.ErrorRate, err = ParseRate(val)
+	case "error-status":
+		r.ErrorStatus, err = strconv.Atoi(val)
+		if err == nil && (r.ErrorStatus < 100 || r.ErrorStatus > 999) {
+			err = fmt.Errorf("invalid status %d", r.ErrorStatus)
+		}
+	case "reset-rate":
+		r.ResetRate, err = ParseRate(val)
+	case "truncate-rate":
+		r.TruncateRate, err = ParseRate(val)
+	case "truncate-after":
+		r.TruncateAfter, err = form.ParseSize(val)
+	default:
+		return fmt.Errorf("unknown option %q", key)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	return nil
+}
+
+// ParseRate parses a probability given as a fraction ("0.05") or a
+// percentage ("5%").
+func ParseRate(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	div := 1.0
+	if strings.HasSuffix(s, "%") {
+		s = strings.TrimSuffix(s, "%")
+		div = 100
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %q", s)
+	}
+	f /= div
+	if f < 0 || f > 1 {
+		return 0, fmt.Errorf("rate %v out of range [0, 1]", f)
+	}
+	return f, nil
+}
@@ -0,0 +1,92 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// Adaptive is the outcome of an adaptive concurrency test: the trajectory
+// of the concurrency a controller chose to hold p95 at TargetP95, and
+// where it settled.
+type Adaptive struct {
+	// Controller is aimd or pid.
+	Controller string  `json:"controller"`
+	TargetP95  float64 `json:"target_p95_ms"`
+	IntervalMS int64   `json:"interval_ms"`
+	// Settled holds the medians of the second half of the trajectory, the
+	// load the target takes at the latency budget once the controller
+	// converged.
+	Settled    AdaptiveStep   `json:"settled"`
+	Trajectory []AdaptiveStep `json:"trajectory"`
+}
+
+// AdaptiveStep is one control interval; AtMS is its end relative to the
+// start of the test. Failed counts requests without a response and 5xx
+// responses.
+type AdaptiveStep struct {
+	AtMS        int64   `json:"at_ms"`
+	Concurrency float64 `json:"concurrency"`
+	Requests    int     `json:"requests"`
+	RPS         float64 `json:"rps"`
+	Failed      int     `json:"failed"`
+	P95         float64 `json:"p95_ms"`
+}
+
+// Settle sets a.Settled from the second half of the trajectory. Intervals
+// without requests have no p95 and are left out of the medians.
+func (a *Adaptive) Settle() {
+	half := a.Trajectory[len(a.Trajectory)/2:]
+	a.Settled = AdaptiveStep{}
+	pick := func(f func(AdaptiveStep) float64) float64 {
+		vals := make([]float64, 0, len(half))
+		for _, s := range half {
+			if s.Requests > 0 {
+				vals = append(vals, f(s))
+			}
+		}
+		return Median(vals)
+	}
+	a.Settled = AdaptiveStep{
+		Concurrency: pick(func(s AdaptiveStep) float64 { return s.Concurrency }),
+		RPS:         pick(func(s AdaptiveStep) float64 { return s.RPS }),
+		P95:         pick(func(s AdaptiveStep) float64 { return s.P95 }),
+	}
+	for _, s := range half {
+		a.Settled.Requests += s.Requests
+		a.Settled.Failed += s.Failed
+	}
+}
+
+// Summary describes where a settled, e.g. "concurrency 96, 1950.20 rps,
+// p95 48.5ms (aimd, target p95 50ms)".
+func (a *Adaptive) Summary() string {
+	s := a.Settled
+	return fmt.Sprintf("concurrency %g, %.2f rps, p95 %s (%s, target p95 %s)",
+		s.Concurrency, s.RPS, formatMS(s.P95), a.Controller, formatMS(a.TargetP95))
+}
+
+// maxTrajectoryRows bounds the trajectory table of the text output; longer
+// trajectories are sampled evenly.
+const maxTrajectoryRows = 30
+
+func writeAdaptiveText(w io.Writer, a *Adaptive) {
+	steps := a.Trajectory
+	if len(steps) > maxTrajectoryRows {
+		sampled := make([]AdaptiveStep, maxTrajectoryRows)
+		for i := range sampled {
+			sampled[i] = steps[i*(len(steps)-1)/(maxTrajectoryRows-1)]
+		}
+		steps = sampled
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Time\tConcurrency\tRequests\tRPS\tFailed\tp95")
+	for _, s := range steps {
+		fmt.Fprintf(tw, "%s\t%g\t%d\t%.2f\t%d\t%s\n", formatMS(float64(s.AtMS)), s.Concurrency, s.Requests, s.RPS, s.Failed, formatMS(s.P95))
+	}
+	_ = tw.Flush()
+	if len(steps) < len(a.Trajectory) {
+		fmt.Fprintf(w, "(%d of %d intervals shown)\n", len(steps), len(a.Trajectory))
+	}
+	fmt.Fprintf(w, "Settled: %s\n", a.Summary())
+}
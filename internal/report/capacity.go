@@ -0,0 +1,239 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// A probe also fails when it delivered less than probeRateShare of its
+// target rate: the target or the client could not keep up, so its
+// latencies say nothing about that rate.
+const probeRateShare = 0.95
+
+// z95 is the standard normal quantile of the one-sided 95% bounds used to
+// judge the SLO margin at the capacity.
+const z95 = 1.645
+
+// Capacity is the outcome of a capacity search: the highest probed rate
+// that met every SLO and the probes that led to it. Each probe is one of
+// the phases of the result.
+type Capacity struct {
+	SLOs []string `json:"slos"`
+	// RPS is the capacity, the highest target rate that met the SLOs; 0
+	// when none did.
+	RPS float64 `json:"rps"`
+	// FailedRPS is the lowest target rate above RPS that broke an SLO; 0
+	// when the search stopped before one did.
+	FailedRPS float64 `json:"failed_rps,omitempty"`
+	// Stop tells why the search ended.
+	Stop string `json:"stop"`
+	// Confidence is high, medium or low; Notes explain anything that
+	// lowered it.
+	Confidence string   `json:"confidence"`
+	Notes      []string `json:"notes,omitempty"`
+	Probes     []Probe  `json:"probes"`
+}
+
+// Probe is one rate tried by a capacity search. Stage is explore while
+// the rate grows (or shrinks) geometrically, bisect while it narrows the
+// bracket and verify when the capacity is probed again.
+type Probe struct {
+	Phase     int         `json:"phase"`
+	Stage     string      `json:"stage"`
+	TargetRPS float64     `json:"target_rps"`
+	RPS       float64     `json:"rps"`
+	Passed    bool        `json:"passed"`
+	SLOs      []Threshold `json:"slos"`
+	// Note explains a failure that is not an SLO, e.g. a rate shortfall.
+	Note string `json:"note,omitempty"`
+}
+
+// ProbePhase checks slos against phase p of a capacity search.
+func ProbePhase(p Phase, stage string, slos []Threshold) Probe {
+	r := phaseResult(p)
+	failed := r.CheckThresholds(slos)
+	pr := Probe{Phase: p.Phase, Stage: stage, TargetRPS: p.TargetRPS, RPS: p.RPS, SLOs: r.Thresholds, Passed: failed == 0}
+	// one request of slack: short, slow probes send whole requests
+	if due := p.TargetRPS * float64(p.DurationMS) / 1000; p.TargetRPS > 0 && float64(p.TotalRequests+1) < probeRateShare*due {
+		pr.Passed = false
+		pr.Note = fmt.Sprintf("reached %.0f%% of the target rate", 100*p.RPS/p.TargetRPS)
+	}
+	return pr
+}
+
+// Failure explains why pr failed, e.g. "p99 was 612ms, expected <500ms".
+func (pr Probe) Failure() string {
+	var why []string
+	if pr.Note != "" {
+		why = append(why, pr.Note)
+	}
+	for _, t := range pr.SLOs {
+		if !t.Passed {
+			why = append(why, t.Message())
+		}
+	}
+	return strings.Join(why, "; ")
+}
+
+// Assess sets the confidence of c from the probes at the capacity, found
+// among phases: it is low when no rate met the SLOs or the capacity failed
+// when probed again, and medium when the search did not narrow the
+// capacity to within precision (relative) or an SLO at the capacity is
+// within the noise of its limit.
+func (c *Capacity) Assess(phases []Phase, precision float64) {
+	c.Notes = nil
+	if c.RPS == 0 {
+		c.Confidence = "low"
+		c.Notes = append(c.Notes, "no probed rate met the SLOs")
+		return
+	}
+	byPhase := make(map[int]Phase, len(phases))
+	for _, p := range phases {
+		byPhase[p.Phase] = p
+	}
+	low := false
+	verified := false
+	for _, pr := range c.Probes {
+		if pr.TargetRPS != c.RPS {
+			continue
+		}
+		if pr.Stage == "verify" {
+			if !pr.Passed {
+				low = true
+				c.Notes = append(c.Notes, "the capacity failed when probed again: "+pr.Failure())
+				continue
+			}
+			verified = true
+		}
+		if !pr.Passed {
+			continue
+		}
+		for _, t := range pr.SLOs {
+			if note := sloMargin(t, byPhase[pr.Phase]); note != "" {
+				c.Notes = append(c.Notes, fmt.Sprintf("%s probe: %s", pr.Stage, note))
+			}
+		}
+	}
+	if !verified && !low {
+		c.Notes = append(c.Notes, "the capacity was probed only once")
+	}
+	switch {
+	case c.FailedRPS == 0:
+		c.Notes = append(c.Notes, fmt.Sprintf("no rate failed; the capacity is at least %.2f rps", c.RPS))
+	case c.FailedRPS/c.RPS-1 > precision:
+		c.Notes = append(c.Notes, fmt.Sprintf("the capacity lies between %.2f and %.2f rps, wider than %s", c.RPS, c.FailedRPS, percent(precision)))
+	}
+	switch {
+	case low:
+		c.Confidence = "low"
+	case len(c.Notes) > 0:
+		c.Confidence = "medium"
+	default:
+		c.Confidence = "high"
+	}
+}
+
+// sloMargin checks that upper limit t still holds at the one-sided 95%
+// upper bound of its metric in p, to tell a comfortable pass from one
+// within sampling noise. Only percentiles and the error rate are bounded.
+func sloMargin(t Threshold, p Phase) string {
+	if t.Op != "<" && t.Op != "<=" {
+		return ""
+	}
+	var q float64
+	switch t.Metric {
+	case "error_rate":
+		if p.TotalRequests == 0 {
+			return ""
+		}
+		if ub := wilsonUpper(float64(failed(p.Errors, p.StatusCounts)), float64(p.TotalRequests)); ub >= t.Limit {
+			return fmt.Sprintf("error rate may be up to %.2f%% (95%% bound), not clearly under %s", ub, strings.TrimPrefix(t.Expr, t.Metric))
+		}
+		return ""
+	case "p50":
+		q = 0.50
+	case "p90":
+		q = 0.90
+	case "p95":
+		q = 0.95
+	case "p99":
+		q = 0.99
+	default:
+		return ""
+	}
+	if p.Latency == nil || len(p.Latency.Buckets) == 0 {
+		return ""
+	}
+	ub, ok := quantileUpper(p.Latency.Buckets, q)
+	if !ok {
+		return fmt.Sprintf("too few responses (%d) to bound %s", p.Latency.Count, t.Metric)
+	}
+	if ub >= t.Limit {
+		return fmt.Sprintf("%s may be up to %s (95%% bound), not clearly under %s", t.Metric, formatMS(ub), strings.TrimPrefix(t.Expr, t.Metric))
+	}
+	return ""
+}
+
+// quantileUpper returns a one-sided 95% upper bound, in milliseconds, of
+// the q quantile of the latencies in buckets: the order statistic at the
+// normal approximation of the binomial rank bound. ok is false when the
+// bound falls beyond the samples.
+func quantileUpper(buckets map[int]int64, q float64) (float64, bool) {
+	var n int64
+	idx := make([]int, 0, len(buckets))
+	for b, c := range buckets {
+		n += c
+		idx = append(idx, b)
+	}
+	sort.Ints(idx)
+	nf := float64(n)
+	rank := int64(math.Ceil(nf*q + z95*math.Sqrt(nf*q*(1-q))))
+	if n == 0 || rank > n {
+		return 0, false
+	}
+	var seen int64
+	for _, b := range idx {
+		seen += buckets[b]
+		if seen >= rank {
+			return bucketMS(b), true
+		}
+	}
+	return bucketMS(idx[len(idx)-1]), true
+}
+
+// wilsonUpper returns the one-sided 95% Wilson upper bound, in percent, of
+// the rate of f failures in n requests.
+func wilsonUpper(f, n float64) float64 {
+	p := f / n
+	z2 := z95 * z95
+	ub := (p + z2/(2*n) + z95*math.Sqrt(p*(1-p)/n+z2/(4*n*n))) / (1 + z2/n)
+	return 100 * ub
+}
+
+// phaseResult wraps the totals of p to check thresholds against them.
+func phaseResult(p Phase) *Result {
+	return &Result{
+		DurationMS:    p.DurationMS,
+		TotalRequests: p.TotalRequests,
+		RPS:           p.RPS,
+		HTTP200:       p.HTTP200,
+		Errors:        p.Errors,
+		StatusCounts:  p.StatusCounts,
+		Latency:       p.Latency,
+	}
+}
+
+// Summary describes c in one line, e.g. "1520.00 rps, confidence high
+// (1600.00 rps failed; SLOs p99<500ms, error_rate<1%)".
+func (c *Capacity) Summary() string {
+	if c.RPS == 0 {
+		return fmt.Sprintf("none, no probed rate met the SLOs (%s)", strings.Join(c.SLOs, ", "))
+	}
+	bracket := ""
+	if c.FailedRPS > 0 {
+		bracket = fmt.Sprintf("%.2f rps failed; ", c.FailedRPS)
+	}
+	return fmt.Sprintf("%.2f rps, confidence %s (%sSLOs %s)", c.RPS, c.Confidence, bracket, strings.Join(c.SLOs, ", "))
+}
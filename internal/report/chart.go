@@ -0,0 +1,139 @@
+package report
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// chart geometry, in SVG user units
+const (
+	chartWidth  = 760
+	chartHeight = 220
+	padLeft     = 56
+	padRight    = 16
+	padTop      = 12
+	padBottom   = 28
+)
+
+// series is one line of a chart; NaN values leave a gap.
+type series struct {
+	Name   string
+	Color  string
+	Values []float64
+}
+
+// marker is a labeled vertical line, e.g. the start of a ramp phase.
+type marker struct {
+	At    float64 // seconds
+	Label string
+}
+
+// lineChart renders series over time (one value every step seconds) as an
+// inline SVG with a labeled y axis in unit.
+func lineChart(unit string, step float64, lines []series, markers []marker) template.HTML {
+	n := 0
+	top := 0.0
+	for _, s := range lines {
+		n = max(n, len(s.Values))
+		for _, v := range s.Values {
+			if !math.IsNaN(v) {
+				top = max(top, v)
+			}
+		}
+	}
+	if n == 0 {
+		return ""
+	}
+	yMax, yStep := niceScale(top)
+	plotW := float64(chartWidth - padLeft - padRight)
+	plotH := float64(chartHeight - padTop - padBottom)
+	span := float64(max(n-1, 1)) * step
+	x := func(sec float64) float64 { return padLeft + sec/span*plotW }
+	y := func(v float64) float64 { return padTop + plotH - v/yMax*plotH }
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg viewBox="0 0 %d %d" class="chart" role="img">`, chartWidth, chartHeight)
+	// horizontal grid and y labels
+	for v := 0.0; v <= yMax+yStep/2; v += yStep {
+		fmt.Fprintf(&b, `<line x1="%d" x2="%d" y1="%.1f" y2="%.1f" class="grid"/>`, padLeft, chartWidth-padRight, y(v), y(v))
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" class="ylabel">%s</text>`, padLeft-6, y(v)+4, formatTick(v))
+	}
+	fmt.Fprintf(&b, `<text x="12" y="%d" class="unit" transform="rotate(-90 12 %d)">%s</text>`,
+		padTop+int(plotH/2), padTop+int(plotH/2), html.EscapeString(unit))
+	// x labels
+	every := timeStep(int(span) + 1)
+	for sec := 0; float64(sec) <= float64(n-1)*step; sec += every {
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" class="xlabel">%s</text>`, x(float64(sec)), chartHeight-8, formatSeconds(sec))
+	}
+	for _, m := range markers {
+		if m.At > span {
+			continue
+		}
+		fmt.Fprintf(&b, `<line x1="%.1f" x2="%.1f" y1="%d" y2="%.1f" class="marker"/>`, x(m.At), x(m.At), padTop, padTop+plotH)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" class="mlabel">%s</text>`, x(m.At)+3, padTop+10, html.EscapeString(m.Label))
+	}
+	for _, s := range lines {
+		color := html.EscapeString(s.Color)
+		var pts []string
+		flush := func() {
+			switch len(pts) {
+			case 0:
+			case 1:
+				xy := strings.Split(pts[0], ",")
+				fmt.Fprintf(&b, `<circle cx="%s" cy="%s" r="2.5" fill="%s"/>`, xy[0], xy[1], color)
+			default:
+				fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="1.75"/>`, strings.Join(pts, " "), color)
+			}
+			pts = pts[:0]
+		}
+		for i, v := range s.Values {
+			if math.IsNaN(v) {
+				flush()
+				continue
+			}
+			pts = append(pts, fmt.Sprintf("%.1f,%.1f", x(float64(i)*step), y(v)))
+		}
+		flush()
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// niceScale returns an axis maximum of at least v and a tick step of 1, 2
+// or 5 times a power of ten giving four to five ticks.
+func niceScale(v float64) (top, step float64) {
+	if v <= 0 {
+		return 1, 0.25
+	}
+	raw := v / 4
+	mag := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, m := range []float64{1, 2, 2.5, 5, 10} {
+		if step = m * mag; step >= raw {
+			break
+		}
+	}
+	return math.Ceil(v/step) * step, step
+}
+
+// timeStep returns the spacing of x labels, in seconds, for n seconds.
+func timeStep(n int) int {
+	for _, s := range []int{1, 2, 5, 10, 15, 30, 60, 120, 300, 600, 900, 1800, 3600} {
+		if n/s <= 8 {
+			return s
+		}
+	}
+	return 7200
+}
+
+func formatSeconds(sec int) string {
+	return (time.Duration(sec) * time.Second).String()
+}
+
+func formatTick(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+}
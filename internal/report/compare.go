@@ -0,0 +1,414 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// CompareMetrics lists the metrics Compare diffs, in report order.
+var CompareMetrics = []string{"rps", "p50", "p90", "p95", "p99", "error_rate"}
+
+// Outcomes of a metric in a Comparison.
+const (
+	StatusOK            = "ok"
+	StatusRegressed     = "regressed"
+	StatusImproved      = "improved"
+	StatusInsignificant = "not significant"
+)
+
+// Tolerance is how much a metric may get worse before it is a regression.
+type Tolerance struct {
+	Value float64
+	// Relative makes Value a percentage of the baseline; otherwise it is
+	// in the unit of the metric: milliseconds, requests/s or percentage
+	// points of the error rate.
+	Relative bool
+}
+
+// DefaultTolerances allows 10% on throughput and latency and one
+// percentage point on the error rate.
+func DefaultTolerances() map[string]Tolerance {
+	tol := make(map[string]Tolerance, len(CompareMetrics))
+	for _, m := range CompareMetrics {
+		tol[m] = Tolerance{Value: 10, Relative: true}
+	}
+	tol["error_rate"] = Tolerance{Value: 1}
+	return tol
+}
+
+// ParseTolerance parses the tolerance of metric: a percentage of the
+// baseline ("10%") or an absolute value, a duration for latency ("20ms"),
+// requests/s for rps and percentage points for error_rate ("0.5" or
+// "0.5pp").
+func ParseTolerance(metric, s string) (Tolerance, error) {
+	s = strings.TrimSpace(s)
+	var (
+		t   Tolerance
+		err error
+	)
+	switch {
+	case strings.HasSuffix(s, "%"):
+		t.Relative = true
+		t.Value, err = strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	case isLatencyMetric(metric):
+		var d time.Duration
+		if d, err = time.ParseDuration(s); err == nil {
+			t.Value = float64(d) / float64(time.Millisecond)
+		}
+	case metric == "error_rate":
+		t.Value, err = strconv.ParseFloat(strings.TrimSuffix(s, "pp"), 64)
+	case metric == "rps":
+		t.Value, err = strconv.ParseFloat(s, 64)
+	default:
+		return t, fmt.Errorf("unknown metric %q (use %s)", metric, strings.Join(CompareMetrics, ", "))
+	}
+	if err != nil || t.Value < 0 || math.IsNaN(t.Value) {
+		return t, fmt.Errorf("invalid tolerance %q for %s", s, metric)
+	}
+	return t, nil
+}
+
+func isLatencyMetric(metric string) bool {
+	switch metric {
+	case "p50", "p90", "p95", "p99":
+		return true
+	}
+	return false
+}
+
+// CompareOptions tune Compare.
+type CompareOptions struct {
+	// Tolerances by metric; metrics without one must not change at all.
+	Tolerances map[string]Tolerance
+	// Alpha is the significance level: a change beyond its tolerance is a
+	// regression only when its p-value is below Alpha. Metrics without a
+	// test (throughput, or latency of results saved without histogram
+	// buckets) are judged on their tolerance alone.
+	Alpha float64
+}
+
+// Comparison is the diff of a candidate result against a baseline.
+type Comparison struct {
+	Baseline  Side        `json:"baseline"`
+	Candidate Side        `json:"candidate"`
+	Alpha     float64     `json:"alpha"`
+	Total     ScopeDiff   `json:"total"`
+	Phases    []ScopeDiff `json:"phases,omitempty"`
+	Endpoints []ScopeDiff `json:"endpoints,omitempty"`
+	// Regressions names each regressed metric, e.g. "total p95" or
+	// "GET /users error_rate".
+	Regressions []string `json:"regressions"`
+}
+
+// Side identifies one of the compared results.
+type Side struct {
+	File      string `json:"file,omitempty"`
+	Command   string `json:"command,omitempty"`
+	Target    string `json:"target,omitempty"`
+	Generator string `json:"generator,omitempty"`
+	Timestamp string `json:"timestamp"`
+}
+
+// ScopeDiff holds the metric diffs of the total, a phase or an endpoint.
+type ScopeDiff struct {
+	Name string `json:"name"`
+	// Only is "baseline" or "candidate" when the scope is missing from the
+	// other result; it has no metrics then.
+	Only    string       `json:"only,omitempty"`
+	Metrics []MetricDiff `json:"metrics,omitempty"`
+}
+
+// MetricDiff is the change of one metric. Latencies are in milliseconds
+// and the error rate in percent.
+type MetricDiff struct {
+	Metric    string  `json:"metric"`
+	Baseline  float64 `json:"baseline"`
+	Candidate float64 `json:"candidate"`
+	Diff      float64 `json:"diff"`
+	// Change is Diff as a percentage of the baseline, absent when the
+	// baseline is zero.
+	Change *float64 `json:"change_pct,omitempty"`
+	// PValue is the probability of a difference at least this large
+	// between two runs of the same system; absent when untested.
+	PValue    *float64 `json:"p_value,omitempty"`
+	Tolerance string   `json:"tolerance"`
+	Status    string   `json:"status"`
+}
+
+// scope is what Compare needs from the total, a phase or an endpoint.
+type scope struct {
+	rps      float64 // NaN when unknown
+	latency  *Latency
+	requests int
+	failed   int
+}
+
+// Compare diffs cand against base: the totals, the phases matched by
+// number and the endpoints matched by name.
+func Compare(base, cand *Result, opts CompareOptions) *Comparison {
+	c := &Comparison{
+		Baseline:    side(base),
+		Candidate:   side(cand),
+		Alpha:       opts.Alpha,
+		Regressions: []string{},
+	}
+	c.Total = compareScope("total", totalScope(base), totalScope(cand), opts)
+
+	candPhases := make(map[int]Phase, len(cand.Phases))
+	for _, p := range cand.Phases {
+		candPhases[p.Phase] = p
+	}
+	seen := make(map[int]bool, len(base.Phases))
+	for _, p := range base.Phases {
+		name := fmt.Sprintf("phase %d", p.Phase)
+		seen[p.Phase] = true
+		if cp, ok := candPhases[p.Phase]; ok {
+			c.Phases = append(c.Phases, compareScope(name, phaseScope(p), phaseScope(cp), opts))
+		} else {
+			c.Phases = append(c.Phases, ScopeDiff{Name: name, Only: "baseline"})
+		}
+	}
+	for _, p := range cand.Phases {
+		if !seen[p.Phase] {
+			c.Phases = append(c.Phases, ScopeDiff{Name: fmt.Sprintf("phase %d", p.Phase), Only: "candidate"})
+		}
+	}
+
+	candEndpoints := make(map[string]Endpoint, len(cand.Endpoints))
+	for _, e := range cand.Endpoints {
+		candEndpoints[e.Name] = e
+	}
+	seenEndpoints := make(map[string]bool, len(base.Endpoints))
+	for _, e := range base.Endpoints {
+		seenEndpoints[e.Name] = true
+		if ce, ok := candEndpoints[e.Name]; ok {
+			c.Endpoints = append(c.Endpoints, compareScope(e.Name, endpointScope(e), endpointScope(ce), opts))
+		} else {
+			c.Endpoints = append(c.Endpoints, ScopeDiff{Name: e.Name, Only: "baseline"})
+		}
+	}
+	for _, e := range cand.Endpoints {
+		if !seenEndpoints[e.Name] {
+			c.Endpoints = append(c.Endpoints, ScopeDiff{Name: e.Name, Only: "candidate"})
+		}
+	}
+
+	for _, s := range append(append([]ScopeDiff{c.Total}, c.Phases...), c.Endpoints...) {
+		for _, m := range s.Metrics {
+			if m.Status == StatusRegressed {
+				c.Regressions = append(c.Regressions, s.Name+" "+m.Metric)
+			}
+		}
+	}
+	return c
+}
+
+func side(r *Result) Side {
+	return Side{Command: r.Command, Target: r.Target(), Generator: r.Generator, Timestamp: r.Timestamp}
+}
+
+func totalScope(r *Result) scope {
+	return scope{rps: r.RPS, latency: r.Latency, requests: r.TotalRequests, failed: r.Failed()}
+}
+
+func phaseScope(p Phase) scope {
+	return scope{rps: p.RPS, latency: p.Latency, requests: p.TotalRequests, failed: failed(p.Errors, p.StatusCounts)}
+}
+
+func endpointScope(e Endpoint) scope {
+	return scope{rps: math.NaN(), latency: e.Latency, requests: e.Requests, failed: failed(e.Errors, e.StatusCounts)}
+}
+
+func compareScope(name string, base, cand scope, opts CompareOptions) ScopeDiff {
+	s := ScopeDiff{Name: name}
+	if !math.IsNaN(base.rps) && !math.IsNaN(cand.rps) {
+		s.Metrics = append(s.Metrics, judge("rps", base.rps, cand.rps, nil, opts))
+	}
+	if base.latency != nil && cand.latency != nil {
+		for _, m := range []struct {
+			name       string
+			q          float64
+			base, cand float64
+		}{
+			{"p50", 0.50, base.latency.P50, cand.latency.P50},
+			{"p90", 0.90, base.latency.P90, cand.latency.P90},
+			{"p95", 0.95, base.latency.P95, cand.latency.P95},
+			{"p99", 0.99, base.latency.P99, cand.latency.P99},
+		} {
+			var pv *float64
+			if p, ok := quantileTest(base.latency.Buckets, cand.latency.Buckets, m.q); ok {
+				pv = &p
+			}
+			s.Metrics = append(s.Metrics, judge(m.name, m.base, m.cand, pv, opts))
+		}
+	}
+	if base.requests > 0 && cand.requests > 0 {
+		p := proportionTest(float64(base.failed), float64(base.requests), float64(cand.failed), float64(cand.requests))
+		s.Metrics = append(s.Metrics, judge("error_rate",
+			100*float64(base.failed)/float64(base.requests),
+			100*float64(cand.failed)/float64(cand.requests), &p, opts))
+	}
+	return s
+}
+
+// judge decides whether metric regressed from base to cand. Throughput
+// gets worse when it drops, latency and the error rate when they grow.
+func judge(metric string, base, cand float64, pv *float64, opts CompareOptions) MetricDiff {
+	tol := opts.Tolerances[metric]
+	d := MetricDiff{
+		Metric:    metric,
+		Baseline:  base,
+		Candidate: cand,
+		Diff:      cand - base,
+		PValue:    pv,
+		Tolerance: formatTolerance(metric, tol),
+		Status:    StatusOK,
+	}
+	if base != 0 {
+		change := 100 * d.Diff / base
+		d.Change = &change
+	}
+	worse := d.Diff
+	if metric == "rps" {
+		worse = -worse
+	}
+	limit := tol.Value
+	if tol.Relative {
+		limit = math.Abs(base) * tol.Value / 100
+	}
+	significant := pv == nil || *pv < opts.Alpha
+	switch {
+	case worse > limit && significant:
+		d.Status = StatusRegressed
+	case worse > limit:
+		d.Status = StatusInsignificant
+	case -worse > limit && significant:
+		d.Status = StatusImproved
+	}
+	return d
+}
+
+func formatTolerance(metric string, t Tolerance) string {
+	v := strconv.FormatFloat(t.Value, 'f', -1, 64)
+	switch {
+	case t.Relative:
+		return v + "%"
+	case isLatencyMetric(metric):
+		return formatMS(t.Value)
+	case metric == "error_rate":
+		return v + "pp"
+	}
+	return v
+}
+
+// failed counts the requests without a response plus the 4xx and 5xx
+// responses.
+func failed(errs int, counts map[string]int) int {
+	n := errs
+	for code, count := range counts {
+		if c, err := strconv.Atoi(code); err == nil && c >= 400 {
+			n += count
+		}
+	}
+	return n
+}
+
+// WriteComparison prints c as tables: the totals, each phase and endpoint,
+// then the regressions.
+func WriteComparison(w io.Writer, c *Comparison) error {
+	writeSide(w, "Baseline: ", c.Baseline)
+	writeSide(w, "Candidate:", c.Candidate)
+	fmt.Fprintln(w)
+	writeScopeDiff(w, c.Total)
+	for _, s := range c.Phases {
+		fmt.Fprintln(w)
+		writeScopeDiff(w, s)
+	}
+	for _, s := range c.Endpoints {
+		fmt.Fprintln(w)
+		s.Name = "Endpoint " + s.Name
+		writeScopeDiff(w, s)
+	}
+	fmt.Fprintln(w)
+	switch len(c.Regressions) {
+	case 0:
+		fmt.Fprintln(w, "No regressions.")
+	case 1:
+		fmt.Fprintf(w, "1 regression: %s\n", c.Regressions[0])
+	default:
+		fmt.Fprintf(w, "%d regressions: %s\n", len(c.Regressions), strings.Join(c.Regressions, ", "))
+	}
+	return nil
+}
+
+func writeSide(w io.Writer, label string, s Side) {
+	name := s.File
+	if name == "" {
+		name = "-"
+	}
+	fmt.Fprintf(w, "%s %s (%s %s, %s)\n", label, name, s.Command, s.Target, s.Timestamp)
+}
+
+func writeScopeDiff(w io.Writer, s ScopeDiff) {
+	title := strings.ToUpper(s.Name[:1]) + s.Name[1:]
+	if s.Only != "" {
+		fmt.Fprintf(w, "%s: only in %s\n", title, s.Only)
+		return
+	}
+	fmt.Fprintln(w, title)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Metric\tBaseline\tCandidate\tChange\tp-value\tTolerance\tResult")
+	for _, m := range s.Metrics {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", metricLabel(m.Metric),
+			formatMetric(m.Metric, m.Baseline), formatMetric(m.Metric, m.Candidate),
+			formatChange(m), formatPValue(m.PValue), m.Tolerance, m.Status)
+	}
+	_ = tw.Flush()
+}
+
+func metricLabel(metric string) string {
+	switch metric {
+	case "rps":
+		return "Requests/s"
+	case "error_rate":
+		return "Error rate"
+	}
+	return metric
+}
+
+func formatMetric(metric string, v float64) string {
+	switch {
+	case isLatencyMetric(metric):
+		return formatMS(v)
+	case metric == "error_rate":
+		return fmt.Sprintf("%.2f%%", v)
+	}
+	return fmt.Sprintf("%.2f", v)
+}
+
+// formatChange shows the error rate change in percentage points and the
+// others relative to the baseline.
+func formatChange(m MetricDiff) string {
+	switch {
+	case m.Metric == "error_rate":
+		return fmt.Sprintf("%+.2fpp", m.Diff)
+	case m.Change == nil:
+		return "-"
+	}
+	return fmt.Sprintf("%+.1f%%", *m.Change)
+}
+
+func formatPValue(p *float64) string {
+	switch {
+	case p == nil:
+		return "-"
+	case *p < 0.001:
+		return "<0.001"
+	}
+	return fmt.Sprintf("%.3f", *p)
+}
@@ -0,0 +1,91 @@
+package report
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseTolerance(t *testing.T) {
+	tests := []struct {
+		metric, in string
+		want       Tolerance
+		err        bool
+	}{
+		{"p95", "10%", Tolerance{Value: 10, Relative: true}, false},
+		{"p95", "20ms", Tolerance{Value: 20}, false},
+		{"p99", "1.5s", Tolerance{Value: 1500}, false},
+		{"rps", "50", Tolerance{Value: 50}, false},
+		{"rps", " 5% ", Tolerance{Value: 5, Relative: true}, false},
+		{"error_rate", "0.5", Tolerance{Value: 0.5}, false},
+		{"error_rate", "0.5pp", Tolerance{Value: 0.5}, false},
+		{"error_rate", "0", Tolerance{}, false},
+		{"p95", "20", Tolerance{}, true},
+		{"rps", "-1", Tolerance{}, true},
+		{"rps", "NaN", Tolerance{}, true},
+		{"p95", "x%", Tolerance{}, true},
+		{"p42", "10ms", Tolerance{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTolerance(tt.metric, tt.in)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseTolerance(%q, %q) = %+v, want an error", tt.metric, tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseTolerance(%q, %q) = %+v, %v, want %+v", tt.metric, tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestJudge(t *testing.T) {
+	pv := func(p float64) *float64 { return &p }
+	rel10 := map[string]Tolerance{"rps": {Value: 10, Relative: true}, "p95": {Value: 10, Relative: true}}
+	abs := map[string]Tolerance{"p95": {Value: 20}, "error_rate": {Value: 0}}
+	tests := []struct {
+		name       string
+		metric     string
+		base, cand float64
+		pv         *float64
+		opts       CompareOptions
+		want       string
+	}{
+		{"rps drop is worse", "rps", 100, 80, nil, CompareOptions{Tolerances: rel10}, StatusRegressed},
+		{"rps rise is better", "rps", 100, 120, nil, CompareOptions{Tolerances: rel10}, StatusImproved},
+		{"rps within tolerance", "rps", 100, 95, nil, CompareOptions{Tolerances: rel10}, StatusOK},
+		{"latency rise is worse", "p95", 100, 115, nil, CompareOptions{Tolerances: rel10}, StatusRegressed},
+		{"latency drop is better", "p95", 100, 85, nil, CompareOptions{Tolerances: rel10}, StatusImproved},
+		{"absolute tolerance allows", "p95", 100, 115, nil, CompareOptions{Tolerances: abs}, StatusOK},
+		{"absolute tolerance exceeded", "p95", 100, 125, nil, CompareOptions{Tolerances: abs}, StatusRegressed},
+		{"relative scales with the baseline", "p95", 1000, 1090, nil, CompareOptions{Tolerances: rel10}, StatusOK},
+		{"missing tolerance allows no change", "p50", 100, 100.5, nil, CompareOptions{}, StatusRegressed},
+		{"significant change", "p95", 100, 150, pv(0.01), CompareOptions{Tolerances: rel10, Alpha: 0.05}, StatusRegressed},
+		{"insignificant change", "p95", 100, 150, pv(0.2), CompareOptions{Tolerances: rel10, Alpha: 0.05}, StatusInsignificant},
+		{"insignificant improvement", "p95", 100, 50, pv(0.2), CompareOptions{Tolerances: rel10, Alpha: 0.05}, StatusOK},
+		{"alpha 1 accepts any p-value", "p95", 100, 150, pv(0.99), CompareOptions{Tolerances: rel10, Alpha: 1}, StatusRegressed},
+		{"error_rate=0 rejects any rise", "error_rate", 0, 0.1, pv(0.01), CompareOptions{Tolerances: abs, Alpha: 0.05}, StatusRegressed},
+		{"error_rate=0 accepts no change", "error_rate", 1, 1, pv(1), CompareOptions{Tolerances: abs, Alpha: 0.05}, StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := judge(tt.metric, tt.base, tt.cand, tt.pv, tt.opts)
+			if d.Status != tt.want {
+				t.Errorf("status = %q, want %q", d.Status, tt.want)
+			}
+			if d.Diff != tt.cand-tt.base {
+				t.Errorf("diff = %v, want %v", d.Diff, tt.cand-tt.base)
+			}
+		})
+	}
+}
+
+func TestJudgeChange(t *testing.T) {
+	d := judge("p95", 200, 250, nil, CompareOptions{})
+	if d.Change == nil || math.Abs(*d.Change-25) > 1e-9 {
+		t.Errorf("change = %v, want 25", d.Change)
+	}
+	if d := judge("error_rate", 0, 1, nil, CompareOptions{}); d.Change != nil {
+		t.Errorf("change = %v with a zero baseline, want none", *d.Change)
+	}
+}
@@ -0,0 +1,55 @@
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// csvHeader are the columns of WriteCSV; latencies are in milliseconds.
+var csvHeader = []string{
+	"scope", "name", "concurrency", "duration_ms", "requests", "rps", "http_200", "errors", "non_2xx",
+	"min_ms", "mean_ms", "p50_ms", "p90_ms", "p95_ms", "p99_ms", "max_ms",
+}
+
+// WriteCSV writes one row for the whole test ("total"), then one per ramp
+// phase ("phase") and per endpoint ("endpoint"), for spreadsheets. Cells
+// that do not apply to a scope are empty.
+func WriteCSV(w io.Writer, res *Result) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	itoa := strconv.Itoa
+	ftoa := func(f float64) string { return strconv.FormatFloat(f, 'f', 3, 64) }
+	row := func(scope, name, conc, durationMS, requests, rps, ok, errs string, counts map[string]int, l *Latency) error {
+		rec := []string{scope, name, conc, durationMS, requests, rps, ok, errs, itoa(non2xx(counts))}
+		if l == nil {
+			rec = append(rec, "", "", "", "", "", "", "")
+		} else {
+			rec = append(rec, ftoa(l.Min), ftoa(l.Mean), ftoa(l.P50), ftoa(l.P90), ftoa(l.P95), ftoa(l.P99), ftoa(l.Max))
+		}
+		return cw.Write(rec)
+	}
+
+	err := row("total", res.Target(), "", strconv.FormatInt(res.DurationMS, 10), itoa(res.TotalRequests),
+		ftoa(res.RPS), itoa(res.HTTP200), itoa(res.Errors), res.StatusCounts, res.Latency)
+	if err != nil {
+		return err
+	}
+	for _, p := range res.Phases {
+		err := row("phase", itoa(p.Phase), itoa(p.Concurrency), strconv.FormatInt(p.DurationMS, 10), itoa(p.TotalRequests),
+			ftoa(p.RPS), itoa(p.HTTP200), itoa(p.Errors), p.StatusCounts, p.Latency)
+		if err != nil {
+			return err
+		}
+	}
+	for _, e := range res.Endpoints {
+		err := row("endpoint", e.Name, "", "", itoa(e.Requests), "", itoa(e.StatusCounts["200"]), itoa(e.Errors), e.StatusCounts, e.Latency)
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,197 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// A trend is degrading when its slope is significant at driftSignificance
+// and the fitted change over the test goes past the tolerance of its
+// metric the wrong way; past it the right way, it is improving.
+const (
+	driftSignificance = 0.01
+	driftLatencyRise  = 0.2 // relative rise of p95
+	driftRPSDecay     = 0.1 // relative fall of the throughput
+	driftErrorRise    = 1.0 // percentage points of error rate
+)
+
+// minDriftWindows is the fewest timeline windows a trend is fitted to.
+const minDriftWindows = 20
+
+// Drift is the degradation over time of a long test, found by fitting a
+// line to p95, error rate and throughput over its timeline windows. The
+// first window (warm-up) and a last partial one are left out.
+type Drift struct {
+	WindowMS int64 `json:"window_ms"`
+	Windows  int   `json:"windows"`
+	// Degrading is set when any trend is degrading.
+	Degrading bool    `json:"degrading"`
+	Trends    []Trend `json:"trends"`
+}
+
+// Trend is the least-squares line of one metric (p95_ms, error_rate in
+// percent or rps) over the windows: its fitted value at the first and last
+// window, its slope per hour and the two-sided p-value of the slope.
+// Verdict is degrading, improving or stable.
+type Trend struct {
+	Metric  string  `json:"metric"`
+	Start   float64 `json:"start"`
+	End     float64 `json:"end"`
+	PerHour float64 `json:"per_hour"`
+	PValue  float64 `json:"p_value"`
+	Verdict string  `json:"verdict"`
+}
+
+// AnalyzeDrift fits the trends of the timeline of res. It returns nil when
+// the timeline has fewer than minDriftWindows full windows.
+func AnalyzeDrift(res *Result) *Drift {
+	if len(res.Timeline) < 2 {
+		return nil
+	}
+	step := res.timelineStep()
+	last := len(res.Timeline)
+	if float64(res.Timeline[last-1].Second)+step > float64(res.DurationMS)/1000 {
+		last-- // cut short by the end of the test
+	}
+	var p95X, p95, errX, errs, rpsX, rps []float64
+	for i := 1; i < last; i++ {
+		iv := res.Timeline[i]
+		x := float64(iv.Second) + step/2
+		rpsX, rps = append(rpsX, x), append(rps, float64(iv.Requests)/step)
+		if iv.Requests > 0 {
+			errX, errs = append(errX, x), append(errs, 100*float64(iv.Errors+iv.HTTPErrors)/float64(iv.Requests))
+		}
+		if iv.Requests > iv.Errors {
+			p95X, p95 = append(p95X, x), append(p95, iv.P95)
+		}
+	}
+	if len(rps) < minDriftWindows {
+		return nil
+	}
+	d := &Drift{WindowMS: int64(step * 1000), Windows: len(rps)}
+	for _, m := range []struct {
+		metric string
+		x, y   []float64
+	}{{"p95_ms", p95X, p95}, {"error_rate", errX, errs}, {"rps", rpsX, rps}} {
+		if len(m.y) < minDriftWindows {
+			continue
+		}
+		t, ok := fitTrend(m.metric, m.x, m.y)
+		if !ok {
+			continue
+		}
+		d.Degrading = d.Degrading || t.Verdict == "degrading"
+		d.Trends = append(d.Trends, t)
+	}
+	return d
+}
+
+// fitTrend fits y = a + b*x by least squares, x in seconds, and judges the
+// change of the line between the first and last x.
+func fitTrend(metric string, x, y []float64) (Trend, bool) {
+	n := float64(len(x))
+	var mx, my float64
+	for i := range x {
+		mx += x[i]
+		my += y[i]
+	}
+	mx, my = mx/n, my/n
+	var sxx, sxy float64
+	for i := range x {
+		sxx += (x[i] - mx) * (x[i] - mx)
+		sxy += (x[i] - mx) * (y[i] - my)
+	}
+	if sxx == 0 {
+		return Trend{}, false
+	}
+	b := sxy / sxx
+	a := my - b*mx
+	var sse float64
+	for i := range x {
+		r := y[i] - a - b*x[i]
+		sse += r * r
+	}
+	p := 1.0
+	if se := math.Sqrt(sse / (n - 2) / sxx); se > 0 {
+		p = twoSided(b / se)
+	} else if b != 0 {
+		p = 0 // a perfect line
+	}
+	t := Trend{Metric: metric, Start: a + b*x[0], End: a + b*x[len(x)-1], PerHour: b * 3600, PValue: p, Verdict: "stable"}
+
+	var worse, better bool
+	switch metric {
+	case "error_rate":
+		worse, better = t.End-t.Start > driftErrorRise, t.Start-t.End > driftErrorRise
+	case "rps":
+		rel := t.relChange()
+		worse, better = rel < -driftRPSDecay, rel > driftRPSDecay
+	default:
+		rel := t.relChange()
+		worse, better = rel > driftLatencyRise, rel < -driftLatencyRise
+	}
+	if p < driftSignificance {
+		switch {
+		case worse:
+			t.Verdict = "degrading"
+		case better:
+			t.Verdict = "improving"
+		}
+	}
+	return t, true
+}
+
+// relChange returns the change of t relative to its start, 0 when the
+// start is not positive.
+func (t Trend) relChange() float64 {
+	if t.Start <= 0 {
+		return 0
+	}
+	return t.End/t.Start - 1
+}
+
+// Summary describes t, e.g. "p95 12.1ms → 17.9ms (+48%, +727.5µs/h,
+// p<0.001): degrading".
+func (t Trend) Summary() string {
+	switch t.Metric {
+	case "error_rate":
+		return fmt.Sprintf("error rate %.2f%% → %.2f%% (%+.2f points, %+.2f points/h, %s): %s",
+			t.Start, t.End, t.End-t.Start, t.PerHour, formatP(t.PValue), t.Verdict)
+	case "rps":
+		return fmt.Sprintf("throughput %.2f → %.2f rps (%s, %+.2f rps/h, %s): %s",
+			t.Start, t.End, signedPercent(t.relChange()), t.PerHour, formatP(t.PValue), t.Verdict)
+	}
+	slope := "+" + formatMS(t.PerHour)
+	if t.PerHour < 0 {
+		slope = "-" + formatMS(-t.PerHour)
+	}
+	return fmt.Sprintf("p95 %s → %s (%s, %s/h, %s): %s",
+		formatMS(t.Start), formatMS(t.End), signedPercent(t.relChange()), slope, formatP(t.PValue), t.Verdict)
+}
+
+var trendNames = map[string]string{"p95_ms": "p95", "error_rate": "error rate", "rps": "throughput"}
+
+// Summary describes d in one line, e.g. "degrading: p95, throughput (479
+// windows of 1m0s)".
+func (d *Drift) Summary() string {
+	var worse []string
+	for _, t := range d.Trends {
+		if t.Verdict == "degrading" {
+			worse = append(worse, trendNames[t.Metric])
+		}
+	}
+	verdict := "stable"
+	if len(worse) > 0 {
+		verdict = "degrading: " + strings.Join(worse, ", ")
+	}
+	return fmt.Sprintf("%s (%d windows of %s)", verdict, d.Windows, formatMS(float64(d.WindowMS)))
+}
+
+// formatP formats a p-value as "p=0.003" or "p<0.001".
+func formatP(p float64) string {
+	if s := formatPValue(&p); strings.HasPrefix(s, "<") {
+		return "p" + s
+	}
+	return fmt.Sprintf("p=%.3f", p)
+}
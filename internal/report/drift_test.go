@@ -0,0 +1,172 @@
+package report
+
+import (
+	"math"
+	"testing"
+)
+
+// timeline builds a result of n full one-minute windows plus extra seconds
+// of a last partial one, each window from iv.
+func timeline(n, extra int, iv func(i int) Interval) *Result {
+	res := &Result{TimelineStepMS: 60000, DurationMS: int64(n*60+extra) * 1000}
+	windows := n
+	if extra > 0 {
+		windows++
+	}
+	for i := range windows {
+		w := iv(i)
+		w.Second = i * 60
+		res.Timeline = append(res.Timeline, w)
+	}
+	return res
+}
+
+// flat varies p95 around 100ms and keeps 6000 requests and 10 errors per
+// window.
+func flat(i int) Interval {
+	return Interval{Requests: 6000, HTTPErrors: 10, P95: 100 + float64(i%3-1)}
+}
+
+func trend(d *Drift, metric string) (Trend, bool) {
+	for _, t := range d.Trends {
+		if t.Metric == metric {
+			return t, true
+		}
+	}
+	return Trend{}, false
+}
+
+func TestAnalyzeDriftStable(t *testing.T) {
+	d := AnalyzeDrift(timeline(60, 0, flat))
+	if d == nil {
+		t.Fatal("no drift analysis")
+	}
+	if d.Degrading || d.WindowMS != 60000 || d.Windows != 59 {
+		t.Errorf("drift = %+v, want 59 stable windows of 60000ms", d)
+	}
+	for _, m := range []string{"p95_ms", "error_rate", "rps"} {
+		if tr, ok := trend(d, m); !ok || tr.Verdict != "stable" {
+			t.Errorf("%s trend = %+v, %v, want stable", m, tr, ok)
+		}
+	}
+	if got, want := d.Summary(), "stable (59 windows of 1m0s)"; got != want {
+		t.Errorf("summary = %q, want %q", got, want)
+	}
+}
+
+func TestAnalyzeDriftDegrading(t *testing.T) {
+	// p95 climbs from 100ms to about 220ms over the hour
+	d := AnalyzeDrift(timeline(60, 0, func(i int) Interval {
+		iv := flat(i)
+		iv.P95 += 2 * float64(i)
+		return iv
+	}))
+	if d == nil || !d.Degrading {
+		t.Fatalf("drift = %+v, want degrading", d)
+	}
+	tr, _ := trend(d, "p95_ms")
+	if tr.Verdict != "degrading" || tr.PValue >= driftSignificance || math.Abs(tr.PerHour-120) > 2 {
+		t.Errorf("p95 trend = %+v, want degrading by about 120ms/h", tr)
+	}
+	for _, m := range []string{"error_rate", "rps"} {
+		if tr, _ := trend(d, m); tr.Verdict != "stable" {
+			t.Errorf("%s trend = %+v, want stable", m, tr)
+		}
+	}
+	if got, want := d.Summary(), "degrading: p95 (59 windows of 1m0s)"; got != want {
+		t.Errorf("summary = %q, want %q", got, want)
+	}
+}
+
+func TestAnalyzeDriftWindows(t *testing.T) {
+	tests := []struct {
+		name     string
+		n, extra int
+		want     int // 0 for no analysis
+	}{
+		// the warm-up window is always left out
+		{"full windows", 30, 0, 29},
+		{"partial last window is cut", 30, 20, 29},
+		{"just enough", minDriftWindows + 1, 0, minDriftWindows},
+		{"too few", minDriftWindows, 0, 0},
+		{"too few once cut", minDriftWindows, 30, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := AnalyzeDrift(timeline(tt.n, tt.extra, flat))
+			switch {
+			case tt.want == 0 && d != nil:
+				t.Errorf("drift over %d windows, want none", d.Windows)
+			case tt.want > 0 && d == nil:
+				t.Errorf("no drift, want %d windows", tt.want)
+			case tt.want > 0 && d.Windows != tt.want:
+				t.Errorf("windows = %d, want %d", d.Windows, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeDriftFailedWindows(t *testing.T) {
+	// windows without a response have no p95 and are not fitted for it
+	d := AnalyzeDrift(timeline(60, 0, func(i int) Interval {
+		if i%2 == 0 {
+			return Interval{Requests: 10, Errors: 10}
+		}
+		iv := flat(i)
+		iv.P95 += 2 * float64(i)
+		return iv
+	}))
+	if tr, ok := trend(d, "p95_ms"); !ok || tr.Verdict != "degrading" || tr.Start < 90 {
+		t.Errorf("p95 trend = %+v, %v, want degrading from about 100ms", tr, ok)
+	}
+}
+
+func TestFitTrendVerdicts(t *testing.T) {
+	tests := []struct {
+		metric     string
+		start, end float64
+		want       string
+	}{
+		{"p95_ms", 100, 115, "stable"},
+		{"p95_ms", 100, 125, "degrading"},
+		{"p95_ms", 100, 75, "improving"},
+		{"rps", 1000, 950, "stable"},
+		{"rps", 1000, 850, "degrading"},
+		{"rps", 1000, 1150, "improving"},
+		{"error_rate", 1, 1.5, "stable"},
+		{"error_rate", 1, 2.5, "degrading"},
+		{"error_rate", 3, 1.5, "improving"},
+	}
+	for _, tt := range tests {
+		x := make([]float64, 30)
+		y := make([]float64, 30)
+		for i := range x {
+			x[i] = float64(i * 60)
+			y[i] = tt.start + (tt.end-tt.start)*float64(i)/29
+		}
+		tr, ok := fitTrend(tt.metric, x, y)
+		if !ok || tr.Verdict != tt.want {
+			t.Errorf("%s %v → %v: trend = %+v, %v, want %s", tt.metric, tt.start, tt.end, tr, ok, tt.want)
+		}
+	}
+}
+
+func TestFitTrendNoise(t *testing.T) {
+	// a 30% rise hidden in noise is not significant
+	x := make([]float64, 20)
+	y := make([]float64, 20)
+	for i := range x {
+		x[i] = float64(i)
+		y[i] = 100 + 1.5*float64(i)
+		if i%2 == 0 {
+			y[i] += 200
+		}
+	}
+	tr, ok := fitTrend("p95_ms", x, y)
+	if !ok || tr.PValue < driftSignificance || tr.Verdict != "stable" {
+		t.Errorf("trend = %+v, %v, want stable", tr, ok)
+	}
+	if _, ok := fitTrend("p95_ms", []float64{5, 5, 5}, []float64{1, 2, 3}); ok {
+		t.Error("fitted a trend to a single x")
+	}
+}
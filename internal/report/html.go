@@ -0,0 +1,299 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// chart colors, also used by the legends
+const (
+	colorBlue   = "#1c7ed6"
+	colorOrange = "#f08c00"
+	colorRed    = "#e03131"
+	colorViolet = "#7048e8"
+)
+
+// WriteHTML renders res as a single HTML page that works offline: styles
+// and charts are inline, nothing is loaded from the network.
+func WriteHTML(w io.Writer, res *Result) error {
+	return htmlTemplate.Execute(w, newHTMLView(res))
+}
+
+type htmlView struct {
+	*Result
+	Title     string
+	Generated string
+	Generator string
+	Charts    []htmlChart
+	Statuses  []statusRow
+	Settings  []configRow
+	ErrorRate float64
+}
+
+type htmlChart struct {
+	Title  string
+	Legend []series
+	SVG    template.HTML
+}
+
+type statusRow struct {
+	Code  string
+	Count int
+	Share float64 // percent of all requests
+	// Failed marks transport errors and 4xx/5xx statuses.
+	Failed bool
+}
+
+type configRow struct {
+	Name, Value string
+}
+
+func newHTMLView(res *Result) htmlView {
+	v := htmlView{
+		Result:    res,
+		Title:     "stress-test report",
+		Generated: time.Now().UTC().Format(time.RFC3339),
+		Generator: generatorName(res),
+	}
+	if res.Command != "" {
+		v.Title = "stress-test " + res.Command
+	}
+	if t := res.Target(); t != "" {
+		v.Title += ": " + t
+	}
+
+	for _, code := range sortedCodes(res.StatusCounts) {
+		c, err := strconv.Atoi(code)
+		v.Statuses = append(v.Statuses, statusRow{Code: code, Count: res.StatusCounts[code], Failed: err == nil && c >= 400})
+	}
+	if res.Errors > 0 {
+		v.Statuses = append(v.Statuses, statusRow{Code: "error", Count: res.Errors, Failed: true})
+	}
+	if res.TotalRequests > 0 {
+		for i := range v.Statuses {
+			v.Statuses[i].Share = 100 * float64(v.Statuses[i].Count) / float64(res.TotalRequests)
+		}
+	}
+	v.ErrorRate = res.FailureRate()
+
+	for name, value := range res.Config {
+		v.Settings = append(v.Settings, configRow{name, value})
+	}
+	sort.Slice(v.Settings, func(i, j int) bool { return v.Settings[i].Name < v.Settings[j].Name })
+
+	v.Charts = timelineCharts(res)
+	return v
+}
+
+// timelineCharts plots throughput, latency percentiles, error rates and the
+// adaptive concurrency per timeline window, with the start of every ramp
+// phase marked.
+func timelineCharts(res *Result) []htmlChart {
+	n := len(res.Timeline)
+	if n == 0 {
+		return nil
+	}
+	rps := make([]float64, n)
+	p50, p95, p99 := make([]float64, n), make([]float64, n), make([]float64, n)
+	transport, httpErr := make([]float64, n), make([]float64, n)
+	for i, iv := range res.Timeline {
+		rps[i] = float64(iv.Requests) / res.windowSeconds(i)
+		responses := iv.Requests - iv.Errors
+		if responses > 0 {
+			p50[i], p95[i], p99[i] = iv.P50, iv.P95, iv.P99
+		} else {
+			p50[i], p95[i], p99[i] = math.NaN(), math.NaN(), math.NaN()
+		}
+		if iv.Requests > 0 {
+			transport[i] = 100 * float64(iv.Errors) / float64(iv.Requests)
+			httpErr[i] = 100 * float64(iv.HTTPErrors) / float64(iv.Requests)
+		} else {
+			transport[i], httpErr[i] = math.NaN(), math.NaN()
+		}
+	}
+	sec := res.timelineStep()
+	var markers []marker
+	for _, p := range res.Phases {
+		markers = append(markers, marker{At: float64(p.StartMS) / 1000, Label: fmt.Sprintf("P%d", p.Phase)})
+	}
+
+	throughput := []series{{Name: "requests/s", Color: colorBlue, Values: rps}}
+	latency := []series{
+		{Name: "p50", Color: colorBlue, Values: p50},
+		{Name: "p95", Color: colorOrange, Values: p95},
+		{Name: "p99", Color: colorRed, Values: p99},
+	}
+	errs := []series{
+		{Name: "HTTP 4xx/5xx", Color: colorOrange, Values: httpErr},
+		{Name: "transport errors", Color: colorViolet, Values: transport},
+	}
+	charts := []htmlChart{
+		{Title: "Throughput", Legend: throughput, SVG: lineChart("requests/s", sec, throughput, markers)},
+		{Title: "Latency percentiles", Legend: latency, SVG: lineChart("ms", sec, latency, markers)},
+		{Title: "Error rate", Legend: errs, SVG: lineChart("% of requests", sec, errs, markers)},
+	}
+	if a := res.Adaptive; a != nil && len(a.Trajectory) > 0 {
+		// every window takes the concurrency of the interval it ends in
+		workers := make([]float64, n)
+		step := 0
+		for i := range workers {
+			for step < len(a.Trajectory)-1 && float64(a.Trajectory[step].AtMS) < float64(i+1)*sec*1000 {
+				step++
+			}
+			workers[i] = a.Trajectory[step].Concurrency
+		}
+		conc := []series{{Name: "workers", Color: colorViolet, Values: workers}}
+		charts = append(charts, htmlChart{Title: "Concurrency", Legend: conc, SVG: lineChart("workers", sec, conc, markers)})
+	}
+	return charts
+}
+
+func formatMS(ms float64) string {
+	d := time.Duration(ms * float64(time.Millisecond))
+	switch {
+	case d >= time.Second:
+		d = d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		d = d.Round(10 * time.Microsecond)
+	default:
+		d = d.Round(time.Microsecond / 10)
+	}
+	return d.String()
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"ms":  formatMS,
+	"dur": func(ms int64) string { return formatMS(float64(ms)) },
+	"pct": func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) + "%" },
+	"num": func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font: 14px/1.45 -apple-system, "Segoe UI", Roboto, Helvetica, Arial, sans-serif; color: #212529; margin: 0; background: #f8f9fa; }
+main { max-width: 880px; margin: 0 auto; padding: 24px; }
+h1 { font-size: 20px; margin: 0 0 4px; word-break: break-all; }
+h2 { font-size: 16px; margin: 32px 0 8px; }
+.meta { color: #868e96; margin: 0 0 20px; }
+.cards { display: grid; grid-template-columns: repeat(auto-fill, minmax(130px, 1fr)); gap: 8px; }
+.card { background: #fff; border: 1px solid #dee2e6; border-radius: 6px; padding: 10px 12px; }
+.card b { display: block; font-size: 18px; }
+.card span { color: #868e96; font-size: 12px; }
+section.chart-box { background: #fff; border: 1px solid #dee2e6; border-radius: 6px; padding: 8px 12px; margin-bottom: 12px; }
+.chart-box h3 { font-size: 14px; margin: 4px 0; }
+.legend span { margin-right: 14px; font-size: 12px; }
+.legend i { display: inline-block; width: 10px; height: 10px; border-radius: 2px; margin-right: 4px; }
+svg.chart { width: 100%; height: auto; }
+svg .grid { stroke: #e9ecef; }
+svg .marker { stroke: #adb5bd; stroke-dasharray: 4 3; }
+svg text { font-size: 11px; fill: #868e96; }
+svg .ylabel { text-anchor: end; }
+svg .xlabel { text-anchor: middle; }
+svg .unit { text-anchor: middle; }
+table { border-collapse: collapse; width: 100%; background: #fff; border: 1px solid #dee2e6; }
+th, td { padding: 5px 10px; border-bottom: 1px solid #e9ecef; text-align: right; white-space: nowrap; }
+th:first-child, td:first-child { text-align: left; }
+td.value { text-align: left; white-space: pre-wrap; word-break: break-all; font-family: ui-monospace, Menlo, Consolas, monospace; font-size: 12px; }
+th { background: #f1f3f5; font-weight: 600; }
+.bar { display: inline-block; height: 10px; background: #1c7ed6; border-radius: 2px; vertical-align: middle; }
+.bar.bad { background: #e03131; }
+td.bad, li.bad { color: #e03131; font-weight: 600; }
+</style>
+</head>
+<body>
+<main>
+<h1>{{.Title}}</h1>
+<p class="meta">{{if .Method}}{{.Method}} · {{end}}test finished {{.Timestamp}} · report generated {{.Generated}} · tested with {{.Generator}}</p>
+
+<div class="cards">
+<div class="card"><b>{{.TotalRequests}}</b><span>requests</span></div>
+<div class="card"><b>{{num .RPS}}</b><span>requests/s</span></div>
+<div class="card"><b>{{dur .DurationMS}}</b><span>duration</span></div>
+<div class="card"><b>{{pct .ErrorRate}}</b><span>failed (errors and 4xx/5xx)</span></div>
+{{with .Latency}}<div class="card"><b>{{ms .P50}}</b><span>p50 latency</span></div>
+<div class="card"><b>{{ms .P95}}</b><span>p95 latency</span></div>
+<div class="card"><b>{{ms .P99}}</b><span>p99 latency</span></div>
+<div class="card"><b>{{ms .Max}}</b><span>max latency</span></div>{{end}}
+</div>
+
+{{if .Thresholds}}<h2>Thresholds</h2>
+<table>
+<tr><th>Threshold</th><th>Value</th><th>Result</th></tr>
+{{range .Thresholds}}<tr><td>{{.Expr}}</td><td>{{.FormattedValue}}</td><td{{if not .Passed}} class="bad"{{end}}>{{if .Passed}}passed{{else}}failed{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+
+{{if .Charts}}<h2>Over time</h2>
+{{range .Charts}}<section class="chart-box">
+<h3>{{.Title}}</h3>
+<div class="legend">{{range .Legend}}<span><i style="background:{{.Color}}"></i>{{.Name}}</span>{{end}}</div>
+{{.SVG}}
+</section>
+{{end}}{{end}}
+
+{{with .Drift}}<h2>Drift</h2>
+<p><b>{{.Summary}}</b></p>
+<ul>{{range .Trends}}<li{{if eq .Verdict "degrading"}} class="bad"{{end}}>{{.Summary}}</li>{{end}}</ul>
+{{end}}
+
+{{with .Adaptive}}<h2>Adaptive concurrency</h2>
+<p><b>Settled: {{.Summary}}</b></p>
+{{end}}
+
+{{with .Capacity}}<h2>Capacity</h2>
+<p><b>{{.Summary}}</b></p>
+<p>Search: {{.Stop}}</p>
+{{if .Notes}}<ul>{{range .Notes}}<li>{{.}}</li>{{end}}</ul>{{end}}
+<table>
+<tr><th>Probe</th><th>Stage</th><th>Target RPS</th><th>RPS</th><th>Result</th></tr>
+{{range .Probes}}<tr><td>{{.Phase}}</td><td>{{.Stage}}</td><td>{{num .TargetRPS}}</td><td>{{num .RPS}}</td><td{{if not .Passed}} class="bad"{{end}}>{{if .Passed}}passed{{else}}failed: {{.Failure}}{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+
+{{if .Phases}}<h2>Phases</h2>
+<table>
+<tr><th>Phase</th><th>Concurrency</th><th>Target RPS</th><th>Duration</th><th>Requests</th><th>RPS</th><th>HTTP 200</th><th>Errors</th><th>p50</th><th>p95</th><th>p99</th></tr>
+{{range .Phases}}<tr><td>{{.Phase}}</td><td>{{.Concurrency}}</td><td>{{if .TargetRPS}}{{num .TargetRPS}}{{else}}-{{end}}</td><td>{{dur .DurationMS}}</td><td>{{.TotalRequests}}</td><td>{{num .RPS}}</td><td>{{.HTTP200}}</td><td>{{.Errors}}</td>{{with .Latency}}<td>{{ms .P50}}</td><td>{{ms .P95}}</td><td>{{ms .P99}}</td>{{else}}<td>-</td><td>-</td><td>-</td>{{end}}</tr>
+{{end}}</table>
+{{with .Knee}}<p><b>Knee:</b> {{.Summary}}</p>{{end}}
+{{end}}
+
+<h2>Status codes</h2>
+<table>
+<tr><th>Status</th><th>Requests</th><th>Share</th><th style="width:40%"></th></tr>
+{{range .Statuses}}<tr><td>{{.Code}}</td><td>{{.Count}}</td><td>{{pct .Share}}</td><td style="text-align:left"><span class="bar{{if .Failed}} bad{{end}}" style="width:{{printf "%.1f" .Share}}%"></span></td></tr>
+{{else}}<tr><td colspan="4">no responses</td></tr>
+{{end}}</table>
+
+{{if .Latency}}<h2>Latency</h2>
+<table>
+<tr><th></th><th>count</th><th>min</th><th>mean</th><th>p50</th><th>p90</th><th>p95</th><th>p99</th><th>max</th></tr>
+{{with .Latency}}<tr><td>Response</td><td>{{.Count}}</td><td>{{ms .Min}}</td><td>{{ms .Mean}}</td><td>{{ms .P50}}</td><td>{{ms .P90}}</td><td>{{ms .P95}}</td><td>{{ms .P99}}</td><td>{{ms .Max}}</td></tr>{{end}}
+{{with .Prepare}}<tr><td>Request preparation</td><td>{{.Count}}</td><td>{{ms .Min}}</td><td>{{ms .Mean}}</td><td>{{ms .P50}}</td><td>{{ms .P90}}</td><td>{{ms .P95}}</td><td>{{ms .P99}}</td><td>{{ms .Max}}</td></tr>{{end}}
+</table>
+{{end}}
+
+{{if .Endpoints}}<h2>Endpoints</h2>
+<table>
+<tr><th>Endpoint</th><th>Requests</th><th>Errors</th><th>p50</th><th>p95</th><th>p99</th><th>max</th></tr>
+{{range .Endpoints}}<tr><td>{{.Name}}</td><td>{{.Requests}}</td><td>{{.Errors}}</td>{{with .Latency}}<td>{{ms .P50}}</td><td>{{ms .P95}}</td><td>{{ms .P99}}</td><td>{{ms .Max}}</td>{{else}}<td>-</td><td>-</td><td>-</td><td>-</td>{{end}}</tr>
+{{end}}</table>
+{{end}}
+
+{{if .Settings}}<h2>Configuration</h2>
+<table>
+{{range .Settings}}<tr><td>{{.Name}}</td><td class="value">{{.Value}}</td></tr>
+{{end}}</table>
+{{end}}
+</main>
+</body>
+</html>
+`))
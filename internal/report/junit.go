@@ -0,0 +1,148 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr,omitempty"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitCase     `xml:"testcase"`
+	SystemOut  string          `xml:"system-out,omitempty"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes res as JUnit XML for CI systems: the whole test, every
+// ramp phase and every endpoint is a test case that fails when it had
+// requests without a response, 5xx responses or unexpected statuses, and
+// every threshold is one that fails with it. The text summary goes to
+// system-out.
+func WriteJUnit(w io.Writer, res *Result) error {
+	class := "stress-test"
+	if res.Command != "" {
+		class += "." + res.Command
+	}
+	suite := junitSuite{
+		Name:      strings.TrimPrefix(class+" "+res.Target(), " "),
+		Time:      seconds(res.DurationMS),
+		Timestamp: res.Timestamp,
+	}
+	addCase := func(name string, durationMS int64, requests, errs, unexpected int, counts map[string]int) {
+		c := junitCase{Name: name, Classname: class, Time: seconds(durationMS)}
+		if msg := failureMessage(requests, errs, unexpected, counts); msg != "" {
+			c.Failure = &junitFailure{Message: msg, Type: "errors", Text: statusLine(counts, errs)}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	addCase("total", res.DurationMS, res.TotalRequests, res.Errors, res.Unexpected, res.StatusCounts)
+	for _, t := range res.Thresholds {
+		c := junitCase{Name: "threshold " + t.Expr, Classname: class, Time: seconds(0)}
+		if !t.Passed {
+			c.Failure = &junitFailure{Message: t.Message(), Type: "threshold"}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	for _, p := range res.Phases {
+		addCase(fmt.Sprintf("phase %d (concurrency %d)", p.Phase, p.Concurrency), p.DurationMS, p.TotalRequests, p.Errors, 0, p.StatusCounts)
+	}
+	for _, e := range res.Endpoints {
+		addCase("endpoint "+e.Name, 0, e.Requests, e.Errors, e.Unexpected, e.StatusCounts)
+	}
+	suite.Tests = len(suite.Cases)
+
+	names := make([]string, 0, len(res.Config))
+	for name := range res.Config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		suite.Properties = append(suite.Properties, junitProperty{Name: name, Value: res.Config[name]})
+	}
+	var out bytes.Buffer
+	if err := WriteText(&out, res, Options{}); err != nil {
+		return err
+	}
+	suite.SystemOut = out.String()
+
+	doc := junitSuites{
+		Name:     "stress-test",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Time:     suite.Time,
+		Suites:   []junitSuite{suite},
+	}
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s%s\n", xml.Header, data)
+	return err
+}
+
+// failureMessage explains why a test case failed, or returns "".
+func failureMessage(requests, errs, unexpected int, counts map[string]int) string {
+	var parts []string
+	if errs > 0 {
+		parts = append(parts, fmt.Sprintf("%d of %d requests got no response", errs, requests))
+	}
+	if n := serverErrors(counts); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d of %d responses were 5xx", n, requests-errs))
+	}
+	if unexpected > 0 {
+		parts = append(parts, fmt.Sprintf("%d responses had an unexpected status", unexpected))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// statusLine lists the status counts, e.g. "200: 95, 503: 5, errors: 2".
+func statusLine(counts map[string]int, errs int) string {
+	var parts []string
+	for _, code := range sortedCodes(counts) {
+		parts = append(parts, fmt.Sprintf("%s: %d", code, counts[code]))
+	}
+	if errs > 0 {
+		parts = append(parts, fmt.Sprintf("errors: %d", errs))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func seconds(ms int64) string {
+	return fmt.Sprintf("%.3f", float64(ms)/1000)
+}
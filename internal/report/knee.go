@@ -0,0 +1,144 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The knee is the first phase whose added load returned less than
+// kneeEfficiency of its relative increase as throughput while p95 rose by
+// at least kneeLatencyRise or the error rate by kneeErrorRise points.
+const (
+	kneeEfficiency  = 0.5
+	kneeLatencyRise = 0.1
+	kneeErrorRise   = 1.0
+)
+
+// Knee is the saturation point found in the phases of a ramp: the last
+// phase whose added load still turned into throughput. Beyond it, in
+// SaturatedPhase, throughput stopped growing while latency or errors
+// climbed. Throughput counts responses without errors, so fast failures
+// do not pass for capacity.
+type Knee struct {
+	Found bool `json:"found"`
+	// Phase is the knee, the highest load the target absorbed; it and the
+	// fields below are set only when Found.
+	Phase          int     `json:"phase,omitempty"`
+	SaturatedPhase int     `json:"saturated_phase,omitempty"`
+	Concurrency    int     `json:"concurrency,omitempty"`
+	TargetRPS      float64 `json:"target_rps,omitempty"`
+	RPS            float64 `json:"rps,omitempty"`
+	P95            float64 `json:"p95_ms,omitempty"`
+	// Reason explains the verdict, e.g. "phase 4: +33% concurrency gave
+	// +2% throughput while p95 rose 80%".
+	Reason string `json:"reason"`
+}
+
+// FindKnee looks for the saturation knee in phases of increasing load,
+// measured by target RPS in rate mode and concurrency otherwise. It
+// returns nil for fewer than two phases.
+func FindKnee(phases []Phase) *Knee {
+	if len(phases) < 2 {
+		return nil
+	}
+	compared := 0
+	for i := 1; i < len(phases); i++ {
+		prev, cur := phases[i-1], phases[i]
+		load, growth := loadGrowth(prev, cur)
+		if growth <= 0 {
+			continue
+		}
+		compared++
+		prevGood, curGood := goodput(prev), goodput(cur)
+		var efficiency float64
+		switch {
+		case prevGood > 0:
+			efficiency = (curGood/prevGood - 1) / growth
+		case curGood > 0:
+			efficiency = 1
+		}
+		if efficiency >= kneeEfficiency {
+			continue
+		}
+
+		var why []string
+		if p, c := prev.Latency, cur.Latency; p != nil && c != nil && p.Count > 0 && c.Count > 0 && p.P95 > 0 {
+			if rise := c.P95/p.P95 - 1; rise >= kneeLatencyRise {
+				why = append(why, fmt.Sprintf("p95 rose %s (%s to %s)", percent(rise), formatMS(p.P95), formatMS(c.P95)))
+			}
+		}
+		if rise := phaseFailureRate(cur) - phaseFailureRate(prev); rise >= kneeErrorRise {
+			why = append(why, fmt.Sprintf("the error rate rose %.1f points", rise))
+		}
+		if len(why) == 0 {
+			continue
+		}
+		k := &Knee{
+			Found:          true,
+			Phase:          prev.Phase,
+			SaturatedPhase: cur.Phase,
+			Concurrency:    prev.Concurrency,
+			TargetRPS:      prev.TargetRPS,
+			RPS:            prev.RPS,
+			Reason: fmt.Sprintf("phase %d: %s %s gave %s throughput while %s", cur.Phase,
+				signedPercent(growth), load, signedPercent(efficiency*growth), strings.Join(why, " and ")),
+		}
+		if prev.Latency != nil {
+			k.P95 = prev.Latency.P95
+		}
+		return k
+	}
+	if compared == 0 {
+		return &Knee{Reason: "the load did not increase between phases"}
+	}
+	return &Knee{Reason: fmt.Sprintf("throughput kept up with the load through phase %d", phases[len(phases)-1].Phase)}
+}
+
+// Summary describes k in one line, e.g. "phase 3 (concurrency 15, 1204.50
+// rps, p95 20ms); phase 4: ...".
+func (k *Knee) Summary() string {
+	if !k.Found {
+		return "none, " + k.Reason
+	}
+	load := fmt.Sprintf("concurrency %d", k.Concurrency)
+	if k.TargetRPS > 0 {
+		load = fmt.Sprintf("target %.2f rps", k.TargetRPS)
+	}
+	return fmt.Sprintf("phase %d (%s, %.2f rps, p95 %s); %s", k.Phase, load, k.RPS, formatMS(k.P95), k.Reason)
+}
+
+// loadGrowth returns what grew from prev to cur and by how much,
+// relatively: the target rate when both phases are paced, else
+// concurrency.
+func loadGrowth(prev, cur Phase) (string, float64) {
+	if prev.TargetRPS > 0 && cur.TargetRPS > prev.TargetRPS {
+		return "target rps", cur.TargetRPS/prev.TargetRPS - 1
+	}
+	if prev.Concurrency > 0 && cur.Concurrency > prev.Concurrency {
+		return "concurrency", float64(cur.Concurrency)/float64(prev.Concurrency) - 1
+	}
+	return "", 0
+}
+
+// goodput is the rate of requests of p that did not fail.
+func goodput(p Phase) float64 {
+	if p.TotalRequests == 0 {
+		return 0
+	}
+	return p.RPS * float64(p.TotalRequests-failed(p.Errors, p.StatusCounts)) / float64(p.TotalRequests)
+}
+
+func phaseFailureRate(p Phase) float64 {
+	if p.TotalRequests == 0 {
+		return 0
+	}
+	return 100 * float64(failed(p.Errors, p.StatusCounts)) / float64(p.TotalRequests)
+}
+
+func percent(f float64) string {
+	return fmt.Sprintf("%.0f%%", 100*f)
+}
+
+func signedPercent(f float64) string {
+	return fmt.Sprintf("%+.0f%%", 100*f)
+}
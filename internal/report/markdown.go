@@ -0,0 +1,170 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WriteMarkdown writes a GitHub-flavored Markdown summary that fits a pull
+// request comment or $GITHUB_STEP_SUMMARY: headline numbers, thresholds,
+// status codes, drift, phases, capacity probes or the settled adaptive
+// load, endpoints and the options of the test, folded.
+func WriteMarkdown(w io.Writer, res *Result, opts Options) error {
+	title := "stress-test"
+	if res.Command != "" {
+		title += " " + res.Command
+	}
+	if t := res.Target(); t != "" {
+		title += ": `" + strings.ReplaceAll(t, "`", "'") + "`"
+	}
+	fmt.Fprintf(w, "### %s\n\n", title)
+
+	fmt.Fprintln(w, "| Requests | Requests/s | Duration | Failed | p50 | p95 | p99 | max |")
+	fmt.Fprintln(w, "|---:|---:|---:|---:|---:|---:|---:|---:|")
+	p50, p95, p99 := latencyCells(res.Latency)
+	max := "-"
+	if res.Latency != nil {
+		max = formatMS(res.Latency.Max)
+	}
+	fmt.Fprintf(w, "| %d | %.2f | %s | %.2f%% | %s | %s | %s | %s |\n\n", res.TotalRequests, res.RPS,
+		formatMS(float64(res.DurationMS)), res.FailureRate(), p50, p95, p99, max)
+
+	if len(res.Thresholds) > 0 {
+		fmt.Fprintln(w, "| Threshold | Value | Result |")
+		fmt.Fprintln(w, "|---|---:|---|")
+		for _, t := range res.Thresholds {
+			outcome := "passed"
+			if !t.Passed {
+				outcome = "**failed**"
+			}
+			fmt.Fprintf(w, "| `%s` | %s | %s |\n", t.Expr, t.FormattedValue(), outcome)
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintln(w, "| Status | Requests | Share |")
+	fmt.Fprintln(w, "|---|---:|---:|")
+	for _, code := range sortedCodes(res.StatusCounts) {
+		fmt.Fprintf(w, "| %s | %d | %s |\n", code, res.StatusCounts[code], share(res.StatusCounts[code], res.TotalRequests))
+	}
+	if res.Errors > 0 {
+		fmt.Fprintf(w, "| transport error | %d | %s |\n", res.Errors, share(res.Errors, res.TotalRequests))
+	}
+	fmt.Fprintln(w)
+
+	if a := res.Adaptive; a != nil {
+		fmt.Fprintf(w, "**Settled:** %s\n\n", markdownCell(a.Summary()))
+	}
+	if d := res.Drift; d != nil {
+		fmt.Fprintf(w, "**Drift:** %s\n\n", markdownCell(d.Summary()))
+		for _, t := range d.Trends {
+			fmt.Fprintf(w, "- %s\n", markdownCell(t.Summary()))
+		}
+		fmt.Fprintln(w)
+	}
+	if c := res.Capacity; c != nil {
+		fmt.Fprintf(w, "**Capacity:** %s\n\n", markdownCell(c.Summary()))
+		for _, n := range c.Notes {
+			fmt.Fprintf(w, "- %s\n", markdownCell(n))
+		}
+		if len(c.Notes) > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, "| Probe | Stage | Target RPS | RPS | Failed | p99 | Result |")
+		fmt.Fprintln(w, "|---:|---|---:|---:|---:|---:|---|")
+		for _, pr := range c.Probes {
+			var p Phase
+			for _, ph := range res.Phases {
+				if ph.Phase == pr.Phase {
+					p = ph
+				}
+			}
+			_, _, p99 := latencyCells(p.Latency)
+			outcome := "passed"
+			if !pr.Passed {
+				outcome = "**failed**: " + markdownCell(pr.Failure())
+			}
+			fmt.Fprintf(w, "| %d | %s | %.2f | %.2f | %.2f%% | %s | %s |\n",
+				pr.Phase, pr.Stage, pr.TargetRPS, pr.RPS, phaseFailureRate(p), p99, outcome)
+		}
+		fmt.Fprintln(w)
+	} else if len(res.Phases) > 0 {
+		fmt.Fprintln(w, "| Phase | Concurrency | Target RPS | RPS | Requests | Errors | p50 | p95 | p99 |")
+		fmt.Fprintln(w, "|---:|---:|---:|---:|---:|---:|---:|---:|---:|")
+		for _, p := range res.Phases {
+			target := "-"
+			if p.TargetRPS > 0 {
+				target = strconv.FormatFloat(p.TargetRPS, 'f', 2, 64)
+			}
+			p50, p95, p99 := latencyCells(p.Latency)
+			fmt.Fprintf(w, "| %d | %d | %s | %.2f | %d | %d | %s | %s | %s |\n",
+				p.Phase, p.Concurrency, target, p.RPS, p.TotalRequests, p.Errors, p50, p95, p99)
+		}
+		fmt.Fprintln(w)
+		if res.Knee != nil {
+			fmt.Fprintf(w, "**Knee:** %s\n\n", markdownCell(res.Knee.Summary()))
+		}
+	}
+
+	if len(res.Endpoints) > 0 {
+		shown := res.Endpoints
+		if opts.TopEndpoints > 0 && len(shown) > opts.TopEndpoints {
+			shown = shown[:opts.TopEndpoints]
+		}
+		fmt.Fprintln(w, "| Endpoint | Requests | Errors | Non-2xx | p50 | p95 | p99 |")
+		fmt.Fprintln(w, "|---|---:|---:|---:|---:|---:|---:|")
+		for _, e := range shown {
+			p50, p95, p99 := latencyCells(e.Latency)
+			fmt.Fprintf(w, "| %s | %d | %d | %d | %s | %s | %s |\n",
+				markdownCell(e.Name), e.Requests, e.Errors, non2xx(e.StatusCounts), p50, p95, p99)
+		}
+		if len(shown) < len(res.Endpoints) {
+			fmt.Fprintf(w, "\n_%d more endpoints not shown._\n", len(res.Endpoints)-len(shown))
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(res.Config) > 0 {
+		names := make([]string, 0, len(res.Config))
+		for name := range res.Config {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintln(w, "<details><summary>Configuration</summary>")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "| Option | Value |")
+		fmt.Fprintln(w, "|---|---|")
+		for _, name := range names {
+			fmt.Fprintf(w, "| `--%s` | %s |\n", name, markdownCell(res.Config[name]))
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "</details>")
+		fmt.Fprintln(w)
+	}
+	_, err := fmt.Fprintf(w, "<sub>%s · %s</sub>\n", res.Timestamp, generatorName(res))
+	return err
+}
+
+// markdownCell escapes s for a table cell.
+func markdownCell(s string) string {
+	s = strings.NewReplacer("|", `\|`, "<", "&lt;", ">", "&gt;", "&", "&amp;").Replace(s)
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
+
+func share(n, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f%%", 100*float64(n)/float64(total))
+}
+
+// generatorName names the stress-test build that ran the test.
+func generatorName(res *Result) string {
+	if res.Generator == "" {
+		return "stress-test"
+	}
+	return "stress-test " + res.Generator
+}
@@ -0,0 +1,69 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Formats lists the output formats Write supports.
+var Formats = []string{"text", "json", "markdown", "csv", "html", "junit"}
+
+// Options tune the human-readable formats.
+type Options struct {
+	// TopEndpoints limits the endpoint tables of text and Markdown to the
+	// busiest endpoints; 0 shows all of them.
+	TopEndpoints int
+}
+
+// Write renders res in format, one of Formats ("md" and "xml" are accepted
+// for markdown and junit).
+func Write(w io.Writer, format string, res *Result, opts Options) error {
+	switch format {
+	case "text":
+		return WriteText(w, res, opts)
+	case "json":
+		return WriteJSON(w, res)
+	case "markdown", "md":
+		return WriteMarkdown(w, res, opts)
+	case "csv":
+		return WriteCSV(w, res)
+	case "html":
+		return WriteHTML(w, res)
+	case "junit", "xml":
+		return WriteJUnit(w, res)
+	}
+	return fmt.Errorf("unsupported format %q (use %s)", format, strings.Join(Formats, ", "))
+}
+
+// FormatForFile guesses the format from the extension of name, e.g. "html"
+// for report.html; it returns "" when the extension is not recognized.
+func FormatForFile(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".txt":
+		return "text"
+	case ".json":
+		return "json"
+	case ".md", ".markdown":
+		return "markdown"
+	case ".csv":
+		return "csv"
+	case ".html", ".htm":
+		return "html"
+	case ".xml":
+		return "junit"
+	}
+	return ""
+}
+
+// WriteJSON writes v, a Result or Comparison, as indented JSON.
+func WriteJSON(w io.Writer, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,226 @@
+// Package report holds the saved form of a finished test, the versioned
+// Result document, and renders it as text, JSON, Markdown, CSV, HTML or
+// JUnit XML.
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// SchemaVersion is the version of the Result document written by this
+// build. It changes when fields are renamed or change meaning; added fields
+// keep it. Results without a version predate it and read as version 0.
+const SchemaVersion = 1
+
+// Result is a finished test as written by `--output json` and read back by
+// the report command. Times are in milliseconds.
+type Result struct {
+	Version int `json:"version"`
+	// Generator is the stress-test version that ran the test.
+	Generator string `json:"generator,omitempty"`
+	// Command is the command that produced the result, e.g. "run" or "ramp".
+	Command string `json:"command,omitempty"`
+	URL     string `json:"url,omitempty"`
+	HAR     string `json:"har,omitempty"`
+	// Source describes what was replayed (replay, replay-log, openapi).
+	Source string `json:"source,omitempty"`
+	Method string `json:"method,omitempty"`
+	*Ramp
+
+	DurationMS    int64          `json:"duration_ms"`
+	TotalRequests int            `json:"total_requests"`
+	RPS           float64        `json:"rps"`
+	HTTP200       int            `json:"http_200"`
+	Errors        int            `json:"errors"`
+	Unexpected    int            `json:"unexpected_status,omitempty"`
+	StatusCounts  map[string]int `json:"status_counts"`
+	Latency       *Latency       `json:"latency_ms,omitempty"`
+	Prepare       *Latency       `json:"prepare_ms,omitempty"`
+	Endpoints     []Endpoint     `json:"endpoints,omitempty"`
+	Phases        []Phase        `json:"phases,omitempty"`
+	// Knee is the saturation point of a ramp found in its phases.
+	Knee *Knee `json:"knee,omitempty"`
+	// Capacity is the outcome of a capacity search, whose probes are the
+	// phases.
+	Capacity *Capacity `json:"capacity,omitempty"`
+	// Adaptive is the concurrency trajectory of an adaptive test.
+	Adaptive *Adaptive `json:"adaptive,omitempty"`
+	// Timeline holds one entry per window of the test, TimelineStepMS long
+	// (one second when unset).
+	Timeline       []Interval `json:"timeline,omitempty"`
+	TimelineStepMS int64      `json:"timeline_step_ms,omitempty"`
+	// Drift is the degradation over time found in the timeline of a long
+	// test.
+	Drift *Drift `json:"drift,omitempty"`
+	// Thresholds holds the pass/fail criteria of the test and their outcome.
+	Thresholds []Threshold `json:"thresholds,omitempty"`
+	// Config lists the options the test ran with, secrets masked.
+	Config map[string]string `json:"config,omitempty"`
+	// Tags label the result in a history, e.g. git, env and service.
+	Tags      map[string]string `json:"tags,omitempty"`
+	Timestamp string            `json:"timestamp"`
+}
+
+// Ramp describes the phases of a ramp test.
+type Ramp struct {
+	Steps            int    `json:"steps"`
+	StartConcurrency int    `json:"start_concurrency"`
+	StepConcurrency  int    `json:"step_concurrency"`
+	Mode             string `json:"mode"`
+	PerStep          string `json:"per_step"`
+}
+
+// Latency summarizes a latency histogram, in milliseconds.
+type Latency struct {
+	Count int64   `json:"count"`
+	Min   float64 `json:"min"`
+	Mean  float64 `json:"mean"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+	Max   float64 `json:"max"`
+	// Buckets counts the samples per logarithmic bucket of the histogram
+	// they were measured with: bucket i holds latencies of about 1.02^i
+	// nanoseconds. compare tests them for significance; results written
+	// before they were saved have none.
+	Buckets map[int]int64 `json:"buckets,omitempty"`
+}
+
+// Endpoint holds the results of one labeled endpoint.
+type Endpoint struct {
+	Name         string         `json:"name"`
+	Requests     int            `json:"requests"`
+	Errors       int            `json:"errors"`
+	Unexpected   int            `json:"unexpected_status,omitempty"`
+	StatusCounts map[string]int `json:"status_counts"`
+	Latency      *Latency       `json:"latency_ms,omitempty"`
+}
+
+// Phase holds the results of one ramp phase, summarized like the totals.
+// StartMS is its start relative to the start of the test.
+type Phase struct {
+	Phase         int            `json:"phase"`
+	Concurrency   int            `json:"concurrency"`
+	TargetRPS     float64        `json:"target_rps,omitempty"`
+	StartMS       int64          `json:"start_ms"`
+	DurationMS    int64          `json:"duration_ms"`
+	TotalRequests int            `json:"total_requests"`
+	RPS           float64        `json:"rps"`
+	HTTP200       int            `json:"http_200"`
+	Errors        int            `json:"errors"`
+	Unexpected    int            `json:"unexpected_status,omitempty"`
+	StatusCounts  map[string]int `json:"status_counts"`
+	Latency       *Latency       `json:"latency_ms,omitempty"`
+	Prepare       *Latency       `json:"prepare_ms,omitempty"`
+	Endpoints     []Endpoint     `json:"endpoints,omitempty"`
+}
+
+// Interval holds the requests completed during one window of the test,
+// starting Second seconds after its start. Errors are requests without a
+// response and HTTPErrors responses with a 4xx or 5xx status. Percentiles
+// are zero when no response arrived.
+type Interval struct {
+	Second     int     `json:"second"`
+	Requests   int     `json:"requests"`
+	Errors     int     `json:"errors"`
+	HTTPErrors int     `json:"http_errors"`
+	P50        float64 `json:"p50_ms"`
+	P95        float64 `json:"p95_ms"`
+	P99        float64 `json:"p99_ms"`
+}
+
+// Read decodes a result saved with `--output json`, including results
+// saved before the document was versioned.
+func Read(r io.Reader) (*Result, error) {
+	var res Result
+	if err := json.NewDecoder(r).Decode(&res); err != nil {
+		return nil, fmt.Errorf("decode result: %w", err)
+	}
+	if res.Timestamp == "" || res.StatusCounts == nil {
+		return nil, errors.New("not a stress-test result (expected the output of --output json)")
+	}
+	if res.Version > SchemaVersion {
+		return nil, fmt.Errorf("result version %d is newer than this stress-test supports (%d); upgrade to read it", res.Version, SchemaVersion)
+	}
+	return &res, nil
+}
+
+// Target returns what the test was aimed at: the URL, HAR file or source.
+func (r *Result) Target() string {
+	switch {
+	case r.URL != "":
+		return r.URL
+	case r.HAR != "":
+		return r.HAR
+	}
+	return r.Source
+}
+
+// Failed counts the requests without a response plus the 4xx and 5xx
+// responses.
+func (r *Result) Failed() int {
+	return failed(r.Errors, r.StatusCounts)
+}
+
+// FailureRate returns Failed as a percentage of all requests.
+func (r *Result) FailureRate() float64 {
+	if r.TotalRequests == 0 {
+		return 0
+	}
+	return 100 * float64(r.Failed()) / float64(r.TotalRequests)
+}
+
+// timelineStep returns the length of the Timeline entries, in seconds.
+func (r *Result) timelineStep() float64 {
+	if r.TimelineStepMS <= 0 {
+		return 1
+	}
+	return float64(r.TimelineStepMS) / 1000
+}
+
+// windowSeconds returns how long Timeline entry i lasted, in seconds: the
+// step, except for the last entry, usually cut short by the end of the
+// test.
+func (r *Result) windowSeconds(i int) float64 {
+	step := r.timelineStep()
+	if i == len(r.Timeline)-1 && r.DurationMS > 0 {
+		if rest := float64(r.DurationMS)/1000 - float64(r.Timeline[i].Second); rest > 0.05*step && rest < step {
+			return rest
+		}
+	}
+	return step
+}
+
+// serverErrors counts the 5xx responses in counts.
+func serverErrors(counts map[string]int) int {
+	n := 0
+	for code, count := range counts {
+		if c, err := strconv.Atoi(code); err == nil && c >= 500 {
+			n += count
+		}
+	}
+	return n
+}
+
+// sortedCodes returns the keys of counts in numeric order.
+func sortedCodes(counts map[string]int) []string {
+	codes := make([]string, 0, len(counts))
+	for code := range counts {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		a, errA := strconv.Atoi(codes[i])
+		b, errB := strconv.Atoi(codes[j])
+		if errA != nil || errB != nil {
+			return codes[i] < codes[j]
+		}
+		return a < b
+	})
+	return codes
+}
@@ -0,0 +1,87 @@
+package report
+
+import (
+	"math"
+	"sort"
+)
+
+// quantileTest tests whether the q quantile (0..1) of the latencies in
+// cand differs from that of base, using their histogram buckets: it
+// compares the shares of samples of each side above the bucket holding the
+// baseline quantile with proportionTest. It returns the two-sided p-value
+// and ok=false when either side has no buckets.
+func quantileTest(base, cand map[int]int64, q float64) (p float64, ok bool) {
+	var n1, n2 int64
+	for _, c := range base {
+		n1 += c
+	}
+	for _, c := range cand {
+		n2 += c
+	}
+	if n1 == 0 || n2 == 0 {
+		return 0, false
+	}
+	idx := make([]int, 0, len(base))
+	for b := range base {
+		idx = append(idx, b)
+	}
+	sort.Ints(idx)
+	rank := int64(math.Ceil(q * float64(n1)))
+	var seen int64
+	bucket := idx[len(idx)-1]
+	for _, b := range idx {
+		seen += base[b]
+		if seen >= rank {
+			bucket = b
+			break
+		}
+	}
+	var above int64
+	for b, c := range cand {
+		if b > bucket {
+			above += c
+		}
+	}
+	return proportionTest(float64(n1-seen), float64(n1), float64(above), float64(n2)), true
+}
+
+// proportionTest compares the failure rates f1/n1 and f2/n2 with a
+// two-proportion z-test and returns the two-sided p-value.
+func proportionTest(f1, n1, f2, n2 float64) float64 {
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+	p1, p2 := f1/n1, f2/n2
+	pooled := (f1 + f2) / (n1 + n2)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/n1 + 1/n2))
+	if se == 0 {
+		return 1
+	}
+	return twoSided((p2 - p1) / se)
+}
+
+// twoSided returns the probability of a standard normal value at least as
+// far from zero as z.
+func twoSided(z float64) float64 {
+	return math.Erfc(math.Abs(z) / math.Sqrt2)
+}
+
+// bucketMS returns the midpoint, in milliseconds, of histogram bucket i
+// (see Latency.Buckets).
+func bucketMS(i int) float64 {
+	return math.Pow(1.02, float64(i)+0.5) / 1e6
+}
+
+// Median returns the median of values, or 0 when there are none.
+func Median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	s := append([]float64(nil), values...)
+	sort.Float64s(s)
+	mid := len(s) / 2
+	if len(s)%2 == 0 {
+		return (s[mid-1] + s[mid]) / 2
+	}
+	return s[mid]
+}
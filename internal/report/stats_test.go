@@ -0,0 +1,40 @@
+package report
+
+import (
+	"math"
+	"testing"
+)
+
+func TestQuantileTest(t *testing.T) {
+	even := map[int]int64{10: 50, 20: 50}
+	tests := []struct {
+		name       string
+		base, cand map[int]int64
+		q          float64
+		want       float64 // p-value; -1 for "tiny"
+		ok         bool
+	}{
+		{"same distribution", even, map[int]int64{10: 50, 20: 50}, 0.5, 1, true},
+		{"same shares, other sizes", even, map[int]int64{10: 500, 20: 500}, 0.5, 1, true},
+		{"slower candidate", even, map[int]int64{10: 10, 20: 90}, 0.5, -1, true},
+		{"faster candidate", even, map[int]int64{10: 90, 20: 10}, 0.5, -1, true},
+		// 50/100 against 60/100 above bucket 10: z = 0.1/sqrt(0.55*0.45*0.02)
+		{"small shift", even, map[int]int64{10: 40, 30: 60}, 0.5, math.Erfc(0.1 / math.Sqrt(0.55*0.45*0.02) / math.Sqrt2), true},
+		{"top quantile has nothing above", even, map[int]int64{10: 1, 20: 99}, 1, 1, true},
+		{"no baseline buckets", nil, even, 0.5, 0, false},
+		{"no candidate buckets", even, map[int]int64{}, 0.5, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, ok := quantileTest(tt.base, tt.cand, tt.q)
+			switch {
+			case ok != tt.ok:
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			case tt.want < 0 && p > 1e-6:
+				t.Errorf("p = %v, want < 1e-6", p)
+			case tt.want >= 0 && math.Abs(p-tt.want) > 1e-9:
+				t.Errorf("p = %v, want %v", p, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+// WriteText writes the human-readable summary printed by run and ramp:
+// the phases of a ramp and its knee, the probes of a capacity search or the
+// trajectory of an adaptive test, the totals, status codes, latency, drift,
+// thresholds and the busiest endpoints.
+func WriteText(w io.Writer, res *Result, opts Options) error {
+	if res.Capacity != nil {
+		writeCapacityText(w, res.Capacity, res.Phases)
+		fmt.Fprintln(w, "---")
+	} else if res.Adaptive != nil {
+		writeAdaptiveText(w, res.Adaptive)
+		fmt.Fprintln(w, "---")
+	} else if len(res.Phases) > 0 {
+		writePhasesText(w, res.Phases)
+		if res.Knee != nil {
+			fmt.Fprintf(w, "Knee: %s\n", res.Knee.Summary())
+		}
+		fmt.Fprintln(w, "---")
+	}
+	fmt.Fprintf(w, "Total time: %s\n", formatMS(float64(res.DurationMS)))
+	fmt.Fprintf(w, "Total requests: %d\n", res.TotalRequests)
+	fmt.Fprintf(w, "Requests/sec: %.2f\n", res.RPS)
+	fmt.Fprintf(w, "HTTP 200: %d\n", res.HTTP200)
+	var other []string
+	for _, code := range sortedCodes(res.StatusCounts) {
+		if code != "200" { // already printed separately
+			other = append(other, code)
+		}
+	}
+	if len(other) > 0 {
+		fmt.Fprintln(w, "Other status codes:")
+		for _, code := range other {
+			fmt.Fprintf(w, "- %s: %d\n", code, res.StatusCounts[code])
+		}
+	}
+	if res.Errors > 0 {
+		fmt.Fprintf(w, "Errors: %d\n", res.Errors)
+	}
+	if res.Unexpected > 0 {
+		fmt.Fprintf(w, "Unexpected status: %d\n", res.Unexpected)
+	}
+	writeLatencyText(w, "Latency", res.Latency)
+	// client-side cost, excluded from the latency above
+	writeLatencyText(w, "Request preparation", res.Prepare)
+	if d := res.Drift; d != nil {
+		fmt.Fprintf(w, "Drift: %s\n", d.Summary())
+		for _, t := range d.Trends {
+			fmt.Fprintf(w, "- %s\n", t.Summary())
+		}
+	}
+	if len(res.Thresholds) > 0 {
+		fmt.Fprintln(w, "Thresholds:")
+		for _, t := range res.Thresholds {
+			outcome := "passed"
+			if !t.Passed {
+				outcome = "FAILED"
+			}
+			fmt.Fprintf(w, "- %s: %s, %s\n", t.Expr, t.FormattedValue(), outcome)
+		}
+	}
+	if len(res.Endpoints) > 0 {
+		fmt.Fprintln(w)
+		writeEndpointsText(w, res, opts.TopEndpoints)
+	}
+	return nil
+}
+
+// writeLatencyText prints a one-line summary of l, e.g.
+// "Latency: min=1ms avg=3ms p50=2ms p90=5ms p95=7ms p99=12ms max=40ms".
+func writeLatencyText(w io.Writer, label string, l *Latency) {
+	if l == nil || l.Count == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%s: min=%s avg=%s p50=%s p90=%s p95=%s p99=%s max=%s\n", label,
+		formatMS(l.Min), formatMS(l.Mean), formatMS(l.P50), formatMS(l.P90),
+		formatMS(l.P95), formatMS(l.P99), formatMS(l.Max))
+}
+
+func writePhasesText(w io.Writer, phases []Phase) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Phase\tConcurrency\tTarget RPS\tTime\tRequests\tRPS\tHTTP 200\tErrors\tp50\tp95\tp99")
+	for _, p := range phases {
+		target := "-"
+		if p.TargetRPS > 0 {
+			target = strconv.FormatFloat(p.TargetRPS, 'f', 2, 64)
+		}
+		p50, p95, p99 := latencyCells(p.Latency)
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%s\t%d\t%.2f\t%d\t%d\t%s\t%s\t%s\n", p.Phase, p.Concurrency, target,
+			formatMS(float64(p.DurationMS)), p.TotalRequests, p.RPS, p.HTTP200, p.Errors, p50, p95, p99)
+	}
+	_ = tw.Flush()
+}
+
+func writeCapacityText(w io.Writer, c *Capacity, phases []Phase) {
+	byPhase := make(map[int]Phase, len(phases))
+	for _, p := range phases {
+		byPhase[p.Phase] = p
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Probe\tStage\tTarget RPS\tRPS\tConcurrency\tRequests\tFailed\tp50\tp95\tp99\tResult")
+	for _, pr := range c.Probes {
+		p := byPhase[pr.Phase]
+		p50, p95, p99 := latencyCells(p.Latency)
+		outcome := "passed"
+		if !pr.Passed {
+			outcome = "FAILED: " + pr.Failure()
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%.2f\t%.2f\t%d\t%d\t%.2f%%\t%s\t%s\t%s\t%s\n", pr.Phase, pr.Stage, pr.TargetRPS, pr.RPS,
+			p.Concurrency, p.TotalRequests, phaseFailureRate(p), p50, p95, p99, outcome)
+	}
+	_ = tw.Flush()
+	fmt.Fprintf(w, "Capacity: %s\n", c.Summary())
+	fmt.Fprintf(w, "Search: %s\n", c.Stop)
+	for _, n := range c.Notes {
+		fmt.Fprintf(w, "- %s\n", n)
+	}
+}
+
+// writeEndpointsText prints a per-endpoint table of the top busiest
+// endpoints (all when top <= 0).
+func writeEndpointsText(w io.Writer, res *Result, top int) {
+	shown := res.Endpoints
+	if top > 0 && len(shown) > top {
+		shown = shown[:top]
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprint(tw, "Endpoint\tRequests\tErrors\tNon-2xx\t")
+	if res.Unexpected > 0 {
+		fmt.Fprint(tw, "Unexpected\t")
+	}
+	fmt.Fprintln(tw, "p50\tp95\tp99\tmax")
+	for _, e := range shown {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t", e.Name, e.Requests, e.Errors, non2xx(e.StatusCounts))
+		if res.Unexpected > 0 {
+			fmt.Fprintf(tw, "%d\t", e.Unexpected)
+		}
+		max := "-"
+		if e.Latency != nil {
+			max = formatMS(e.Latency.Max)
+		}
+		p50, p95, p99 := latencyCells(e.Latency)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", p50, p95, p99, max)
+	}
+	_ = tw.Flush()
+	if len(shown) < len(res.Endpoints) {
+		fmt.Fprintf(w, "... %d more endpoints (use --top 0 to show all)\n", len(res.Endpoints)-len(shown))
+	}
+}
+
+// latencyCells returns the p50, p95 and p99 of l for a table, or dashes.
+func latencyCells(l *Latency) (p50, p95, p99 string) {
+	if l == nil {
+		return "-", "-", "-"
+	}
+	return formatMS(l.P50), formatMS(l.P95), formatMS(l.P99)
+}
+
+func non2xx(counts map[string]int) int {
+	n := 0
+	for code, count := range counts {
+		if c, err := strconv.Atoi(code); err != nil || c < 200 || c > 299 {
+			n += count
+		}
+	}
+	return n
+}
@@ -0,0 +1,157 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ThresholdMetrics lists the metrics a Threshold can check.
+var ThresholdMetrics = []string{"rps", "mean", "p50", "p90", "p95", "p99", "max", "error_rate"}
+
+// Threshold is a pass/fail criterion on the totals of a result, such as
+// "p95<300ms", "error_rate<=1%" or "rps>=500". Latencies are in
+// milliseconds and the error rate (requests without a response plus 4xx
+// and 5xx responses) in percent.
+type Threshold struct {
+	Expr   string  `json:"expr"`
+	Metric string  `json:"metric"`
+	Op     string  `json:"op"`
+	Limit  float64 `json:"limit"`
+	Value  float64 `json:"value"`
+	Passed bool    `json:"passed"`
+	// Note explains a failure without a value, e.g. latency of a test
+	// without responses.
+	Note string `json:"note,omitempty"`
+}
+
+// ParseThreshold parses METRIC OP LIMIT, OP being <, <=, > or >=. Latency
+// limits are durations ("300ms"), the error rate a percentage ("1%" or
+// "1") and rps a number.
+func ParseThreshold(s string) (Threshold, error) {
+	expr := strings.Join(strings.Fields(s), "")
+	i := strings.IndexAny(expr, "<>")
+	if i <= 0 {
+		return Threshold{}, fmt.Errorf("invalid threshold %q (use METRIC<LIMIT, e.g. p95<300ms)", s)
+	}
+	t := Threshold{Expr: expr, Metric: expr[:i], Op: expr[i : i+1]}
+	limit := expr[i+1:]
+	if strings.HasPrefix(limit, "=") {
+		t.Op += "="
+		limit = limit[1:]
+	}
+	var err error
+	switch t.Metric {
+	case "mean", "p50", "p90", "p95", "p99", "max":
+		d, err := time.ParseDuration(limit)
+		if err != nil {
+			return t, fmt.Errorf("invalid threshold %q: bad limit %q (use a duration, e.g. 300ms)", s, limit)
+		}
+		t.Limit = float64(d) / float64(time.Millisecond)
+	case "error_rate":
+		t.Limit, err = strconv.ParseFloat(strings.TrimSuffix(limit, "%"), 64)
+	case "rps":
+		t.Limit, err = strconv.ParseFloat(limit, 64)
+	default:
+		return t, fmt.Errorf("invalid threshold %q: unknown metric %q (use %s)", s, t.Metric, strings.Join(ThresholdMetrics, ", "))
+	}
+	if err != nil || math.IsNaN(t.Limit) || math.IsInf(t.Limit, 0) {
+		return t, fmt.Errorf("invalid threshold %q: bad limit %q", s, limit)
+	}
+	return t, nil
+}
+
+// CheckThresholds evaluates ts against the totals of r, stores the outcome
+// in r.Thresholds and returns the number of failed thresholds.
+func (r *Result) CheckThresholds(ts []Threshold) int {
+	failed := 0
+	r.Thresholds = make([]Threshold, 0, len(ts))
+	for _, t := range ts {
+		t.Value, t.Passed, t.Note = 0, false, ""
+		if v, ok := r.thresholdValue(t.Metric); ok {
+			t.Value = v
+			switch t.Op {
+			case "<":
+				t.Passed = v < t.Limit
+			case "<=":
+				t.Passed = v <= t.Limit
+			case ">":
+				t.Passed = v > t.Limit
+			case ">=":
+				t.Passed = v >= t.Limit
+			}
+		} else {
+			t.Note = "no responses"
+		}
+		if !t.Passed {
+			failed++
+		}
+		r.Thresholds = append(r.Thresholds, t)
+	}
+	return failed
+}
+
+func (r *Result) thresholdValue(metric string) (float64, bool) {
+	switch metric {
+	case "rps":
+		return r.RPS, true
+	case "error_rate":
+		return r.FailureRate(), r.TotalRequests > 0
+	}
+	l := r.Latency
+	if l == nil || l.Count == 0 {
+		return 0, false
+	}
+	switch metric {
+	case "mean":
+		return l.Mean, true
+	case "p50":
+		return l.P50, true
+	case "p90":
+		return l.P90, true
+	case "p95":
+		return l.P95, true
+	case "p99":
+		return l.P99, true
+	case "max":
+		return l.Max, true
+	}
+	return 0, false
+}
+
+// FormattedValue returns the measured value in the unit of the metric,
+// e.g. "412ms" or "1.25%".
+func (t Threshold) FormattedValue() string {
+	if t.Note != "" {
+		return t.Note
+	}
+	switch t.Metric {
+	case "rps":
+		return strconv.FormatFloat(t.Value, 'f', 2, 64)
+	case "error_rate":
+		return fmt.Sprintf("%.2f%%", t.Value)
+	}
+	return formatMS(t.Value)
+}
+
+// Message explains a failed threshold, e.g. "p95 was 412ms, expected
+// <300ms".
+func (t Threshold) Message() string {
+	if t.Note != "" {
+		return fmt.Sprintf("%s: %s, expected %s", t.Metric, t.Note, strings.TrimPrefix(t.Expr, t.Metric))
+	}
+	return fmt.Sprintf("%s was %s, expected %s", t.Metric, t.FormattedValue(), strings.TrimPrefix(t.Expr, t.Metric))
+}
+
+// FailedThresholds returns the thresholds of r that did not pass.
+func (r *Result) FailedThresholds() []Threshold {
+	var failed []Threshold
+	for _, t := range r.Thresholds {
+		if !t.Passed {
+			failed = append(failed, t)
+		}
+	}
+	return failed
+}
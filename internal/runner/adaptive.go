@@ -0,0 +1,246 @@
+package runner
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// overloadFailRate is the share of failed requests in a window (no
+// response or 5xx) that the controllers treat as overload whatever the
+// latency: fast failures must not pass for headroom.
+const overloadFailRate = 0.01
+
+// Window is what a Controller sees at the end of each control interval.
+type Window struct {
+	Concurrency int
+	Requests    int
+	// Failed counts requests without a response and 5xx responses.
+	Failed   int
+	P95      time.Duration
+	Duration time.Duration
+}
+
+// Overloaded reports whether more than overloadFailRate of the requests of
+// w failed.
+func (w Window) Overloaded() bool {
+	return w.Requests > 0 && float64(w.Failed) > overloadFailRate*float64(w.Requests)
+}
+
+// Controller picks the concurrency of the next control interval from the
+// last one; RunAdaptive clamps the answer to its bounds.
+type Controller interface {
+	Next(w Window) int
+}
+
+// AIMD is an additive-increase/multiplicative-decrease controller: it adds
+// Increase workers while p95 stays at or below Target and multiplies the
+// concurrency by Decrease (0..1) when it exceeds it or the window is
+// overloaded. Like TCP it starts slow: it doubles the concurrency until
+// the first window over the target.
+type AIMD struct {
+	Target   time.Duration
+	Increase int
+	Decrease float64
+
+	steady bool
+}
+
+// Next implements Controller.
+func (a *AIMD) Next(w Window) int {
+	if w.Requests == 0 {
+		return w.Concurrency
+	}
+	if w.P95 > a.Target || w.Overloaded() {
+		a.steady = true
+		return int(float64(w.Concurrency) * a.Decrease)
+	}
+	if !a.steady {
+		return 2 * w.Concurrency
+	}
+	return w.Concurrency + a.Increase
+}
+
+// PID is a proportional-integral-derivative controller on the relative p95
+// error e = (Target-p95)/Target, a failed window counting as -1. It works
+// in velocity form on the logarithm of the concurrency, which it scales
+// every window by 1 + Ki*e + Kp*Δe + Kd*Δ²e (bounded to 0.5..2): the
+// concurrency itself carries the integral, so nothing winds up while it
+// sits at a bound.
+type PID struct {
+	Target     time.Duration
+	Kp, Ki, Kd float64
+
+	prev, prev2 float64
+	windows     int
+}
+
+// Next implements Controller.
+func (p *PID) Next(w Window) int {
+	if w.Requests == 0 {
+		return w.Concurrency
+	}
+	e := -1.0
+	if !w.Overloaded() {
+		e = max(-1, float64(p.Target-w.P95)/float64(p.Target))
+	}
+	var de, dde float64
+	if p.windows > 0 {
+		de = e - p.prev
+	}
+	if p.windows > 1 {
+		dde = e - 2*p.prev + p.prev2
+	}
+	p.prev, p.prev2 = e, p.prev
+	p.windows++
+	out := min(max(p.Ki*e+p.Kp*de+p.Kd*dde, -0.5), 1)
+	next := int(math.Round(float64(w.Concurrency) * (1 + out)))
+	if next == w.Concurrency && math.Abs(out) > 0.01 {
+		// keep moving at low concurrency, where a fraction rounds away
+		if out > 0 {
+			next++
+		} else {
+			next--
+		}
+	}
+	return next
+}
+
+// AdaptiveOptions configures RunAdaptive.
+type AdaptiveOptions struct {
+	Controller Controller
+	// Interval is the control interval.
+	Interval        time.Duration
+	Start, Min, Max int
+	// OnStep, when set, is called after every interval.
+	OnStep func(AdaptiveStep)
+}
+
+// AdaptiveStep is one control interval of RunAdaptive: the concurrency it
+// ran with and what it measured. At is its end, relative to the start.
+type AdaptiveStep struct {
+	At          time.Duration
+	Concurrency int
+	Requests    int
+	RPS         float64
+	Failed      int
+	P95         time.Duration
+}
+
+// RunAdaptive executes requests for a given duration, letting a.Controller
+// change the number of workers after every interval. It returns the report
+// and the trajectory of the concurrency.
+func RunAdaptive(ctx context.Context, targetURL string, d time.Duration, opts Options, a AdaptiveOptions) (Report, []AdaptiveStep, error) {
+	start := time.Now()
+	rec := newRecorder(true)
+	rec.rep.Resolution = opts.Resolution
+
+	client := &http.Client{}
+	defer client.CloseIdleConnections()
+
+	win := &windowSink{}
+	opts.Sinks = append(append([]Sink(nil), opts.Sinks...), win)
+
+	var wg sync.WaitGroup
+	var stops []chan struct{}
+	resize := func(n int) {
+		for len(stops) < n {
+			stop := make(chan struct{})
+			stops = append(stops, stop)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case <-stop:
+						return
+					default:
+					}
+					rec.do(ctx, client, targetURL, opts)
+				}
+			}()
+		}
+		for len(stops) > n {
+			// the worker finishes its request first
+			close(stops[len(stops)-1])
+			stops = stops[:len(stops)-1]
+		}
+	}
+
+	clamp := func(n int) int {
+		return min(max(n, a.Min, 1), a.Max)
+	}
+	current := clamp(a.Start)
+	resize(current)
+
+	var steps []AdaptiveStep
+	ticker := time.NewTicker(a.Interval)
+	end := time.NewTimer(d)
+	last := start
+	for done := false; !done; {
+		select {
+		case <-ctx.Done():
+			done = true
+		case <-end.C:
+			done = true
+		case <-ticker.C:
+		}
+		now := time.Now()
+		w := win.take()
+		w.Concurrency, w.Duration = current, now.Sub(last)
+		last = now
+		if done && w.Duration < a.Interval/2 {
+			// too short to report
+			break
+		}
+		step := AdaptiveStep{At: now.Sub(start), Concurrency: current, Requests: w.Requests,
+			RPS: float64(w.Requests) / w.Duration.Seconds(), Failed: w.Failed, P95: w.P95}
+		steps = append(steps, step)
+		if a.OnStep != nil {
+			a.OnStep(step)
+		}
+		if !done {
+			current = clamp(a.Controller.Next(w))
+			resize(current)
+		}
+	}
+	ticker.Stop()
+	end.Stop()
+	resize(0)
+	wg.Wait()
+	rep := rec.rep
+	rep.Duration = time.Since(start)
+	return rep, steps, nil
+}
+
+// windowSink collects the requests finished since its last take.
+type windowSink struct {
+	mu       sync.Mutex
+	requests int
+	failed   int
+	latency  Histogram
+}
+
+func (s *windowSink) Record(smp Sample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	if smp.Err != nil || smp.Status >= 500 {
+		s.failed++
+	}
+	if smp.Err == nil {
+		s.latency.Record(smp.Latency)
+	}
+}
+
+func (s *windowSink) take() Window {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w := Window{Requests: s.requests, Failed: s.failed, P95: s.latency.Quantile(0.95)}
+	s.requests, s.failed, s.latency = 0, 0, Histogram{}
+	return w
+}
@@ -0,0 +1,72 @@
+package runner
+
+import (
+	"sync"
+	"time"
+)
+
+// snapshot returns a deep copy of the report so far, its Duration measured
+// from start and TotalRequests counting the requests completed.
+func (r *recorder) snapshot(start time.Time) Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rep := r.rep
+	rep.Duration = time.Since(start)
+	rep.StatusCounts = make(map[int]int, len(r.rep.StatusCounts))
+	for code, count := range r.rep.StatusCounts {
+		rep.StatusCounts[code] = count
+	}
+	rep.Latency, rep.Prepare = Histogram{}, Histogram{}
+	rep.Latency.Merge(r.rep.Latency)
+	rep.Prepare.Merge(r.rep.Prepare)
+	rep.Endpoints = nil
+	for label, e := range r.rep.Endpoints {
+		c := rep.endpoint(label)
+		c.Requests, c.Errors, c.Unexpected = e.Requests, e.Errors, e.Unexpected
+		for code, count := range e.StatusCounts {
+			c.StatusCounts[code] = count
+		}
+		c.Latency.Merge(e.Latency)
+	}
+	rep.Timeline = make([]Interval, len(r.rep.Timeline))
+	if !r.countResponses {
+		rep.TotalRequests = 0 // preset to the scheduled requests
+	}
+	for i, iv := range r.rep.Timeline {
+		if !r.countResponses {
+			rep.TotalRequests += iv.Requests
+		}
+		rep.Timeline[i] = Interval{Requests: iv.Requests, Errors: iv.Errors, HTTPErrors: iv.HTTPErrors}
+		rep.Timeline[i].Latency.Merge(iv.Latency)
+	}
+	return rep
+}
+
+// checkpoints hands a snapshot of the report to opts.Checkpoint every
+// opts.CheckpointEvery until the returned stop function is called, which
+// waits for a checkpoint in progress.
+func (r *recorder) checkpoints(opts Options, start time.Time) (stop func()) {
+	if opts.Checkpoint == nil || opts.CheckpointEvery <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(opts.CheckpointEvery)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				opts.Checkpoint(r.snapshot(start))
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
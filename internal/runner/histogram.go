@@ -0,0 +1,119 @@
+package runner
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// histogramBase is the growth factor between bucket boundaries; it bounds
+// the relative error of reported quantiles to about 1%.
+const histogramBase = 1.02
+
+var logHistogramBase = math.Log(histogramBase)
+
+// Histogram records durations in logarithmic buckets. Memory grows with the
+// number of distinct buckets hit (a few hundred at most), not with the
+// number of samples, so it is safe for long tests.
+type Histogram struct {
+	Buckets map[int]int64 `json:"buckets,omitempty"`
+	Count   int64         `json:"count"`
+	Sum     time.Duration `json:"sum"`
+	Min     time.Duration `json:"min"`
+	Max     time.Duration `json:"max"`
+}
+
+func bucketOf(d time.Duration) int {
+	if d <= 1 {
+		return 0
+	}
+	return int(math.Log(float64(d)) / logHistogramBase)
+}
+
+// bucketValue returns the midpoint of bucket i.
+func bucketValue(i int) time.Duration {
+	return time.Duration(math.Pow(histogramBase, float64(i)+0.5))
+}
+
+// Record adds one sample.
+func (h *Histogram) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	if h.Buckets == nil {
+		h.Buckets = make(map[int]int64)
+	}
+	h.Buckets[bucketOf(d)]++
+	if h.Count == 0 || d < h.Min {
+		h.Min = d
+	}
+	if d > h.Max {
+		h.Max = d
+	}
+	h.Count++
+	h.Sum += d
+}
+
+// Merge adds all samples of o into h.
+func (h *Histogram) Merge(o Histogram) {
+	if o.Count == 0 {
+		return
+	}
+	if h.Buckets == nil {
+		h.Buckets = make(map[int]int64, len(o.Buckets))
+	}
+	for b, c := range o.Buckets {
+		h.Buckets[b] += c
+	}
+	if h.Count == 0 || o.Min < h.Min {
+		h.Min = o.Min
+	}
+	if o.Max > h.Max {
+		h.Max = o.Max
+	}
+	h.Count += o.Count
+	h.Sum += o.Sum
+}
+
+// Mean returns the average duration.
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Quantile returns the duration at quantile q (0..1), e.g. 0.95 for p95.
+func (h Histogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return h.Min
+	}
+	if q >= 1 {
+		return h.Max
+	}
+	idx := make([]int, 0, len(h.Buckets))
+	for b := range h.Buckets {
+		idx = append(idx, b)
+	}
+	sort.Ints(idx)
+	rank := int64(math.Ceil(q * float64(h.Count)))
+	var seen int64
+	for _, b := range idx {
+		seen += h.Buckets[b]
+		if seen >= rank {
+			v := bucketValue(b)
+			// bucket midpoints may fall outside the observed range
+			if v < h.Min {
+				v = h.Min
+			}
+			if v > h.Max {
+				v = h.Max
+			}
+			return v
+		}
+	}
+	return h.Max
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"io"
 	"net/http"
+	"slices"
 	"sync"
 	"time"
 )
@@ -16,6 +17,73 @@ type Report struct {
 	Succeeded200  int
 	StatusCounts  map[int]int
 	Errors        int
+	// Unexpected counts responses whose status is not in Options.Expect.
+	Unexpected int
+	// Latency measures each request from send until its response body is
+	// fully read.
+	Latency Histogram
+	// Prepare measures the time spent building each request before it is
+	// sent (body generation, templates, token minting and signing). It is
+	// kept apart from Latency so client-side cost does not skew results.
+	Prepare Histogram
+	// Endpoints breaks results down by Options.Label; it is empty when no
+	// request is labeled.
+	Endpoints map[string]*Endpoint
+	// Start is when the test began; Timeline[i] holds the requests that
+	// completed during window i after Start, each Resolution long (one
+	// second when zero).
+	Start      time.Time
+	Resolution time.Duration
+	Timeline   []Interval
+}
+
+// Endpoint holds the results of the requests sharing one label.
+type Endpoint struct {
+	Requests     int
+	Errors       int
+	Unexpected   int
+	StatusCounts map[int]int
+	Latency      Histogram
+}
+
+func (r *Report) endpoint(label string) *Endpoint {
+	if r.Endpoints == nil {
+		r.Endpoints = make(map[string]*Endpoint)
+	}
+	e, ok := r.Endpoints[label]
+	if !ok {
+		e = &Endpoint{StatusCounts: make(map[int]int)}
+		r.Endpoints[label] = e
+	}
+	return e
+}
+
+// Merge adds the counters and histograms of o into r. Duration is left
+// untouched; callers measure the wall-clock time of the combined run.
+func (r *Report) Merge(o Report) {
+	if r.StatusCounts == nil {
+		r.StatusCounts = make(map[int]int)
+	}
+	r.TotalRequests += o.TotalRequests
+	r.Succeeded200 += o.Succeeded200
+	r.Errors += o.Errors
+	r.Unexpected += o.Unexpected
+	for code, count := range o.StatusCounts {
+		r.StatusCounts[code] += count
+	}
+	r.Latency.Merge(o.Latency)
+	r.Prepare.Merge(o.Prepare)
+	for label, oe := range o.Endpoints {
+		e := r.endpoint(label)
+		e.Requests += oe.Requests
+		e.Errors += oe.Errors
+		e.Unexpected += oe.Unexpected
+		for code, count := range oe.StatusCounts {
+			e.StatusCounts[code] += count
+		}
+		e.Latency.Merge(oe.Latency)
+	}
+	r.mergeTimeline(o)
 }
 
 // RPS returns requests per second.
@@ -31,6 +99,173 @@ type Options struct {
 	Method  string
 	Headers http.Header
 	Body    []byte
+	// BodyFunc, when set, builds a fresh body for every request (for example
+	// multipart payloads with a unique boundary) and takes precedence over
+	// Body. A non-empty content type overrides the Content-Type header.
+	BodyFunc func() (body []byte, contentType string, err error)
+	// Hooks run in order on every request right before it is sent.
+	Hooks []Hook
+	// Label, when set, groups the request's results in Report.Endpoints.
+	Label string
+	// Expect lists the acceptable status codes; other statuses are counted
+	// in Report.Unexpected. Empty accepts any status.
+	Expect []int
+	// Sinks receive every finished request as it completes.
+	Sinks []Sink
+	// Resolution is the length of the Report.Timeline windows; zero means
+	// one second. Long tests use longer windows to bound memory.
+	Resolution time.Duration
+	// Checkpoint, when set, receives a snapshot of the report every
+	// CheckpointEvery while the test runs, e.g. to save partial results.
+	Checkpoint      func(Report)
+	CheckpointEvery time.Duration
+}
+
+// Hook prepares an outgoing request right before it is sent, for example to
+// attach credentials. body is the payload already attached to req. Hooks are
+// shared by all workers and must be safe for concurrent use.
+type Hook interface {
+	Prepare(req *http.Request, body []byte) error
+}
+
+// newRequest builds a single request for targetURL from opts.
+func newRequest(ctx context.Context, targetURL string, opts Options) (*http.Request, error) {
+	method := opts.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	payload := opts.Body
+	var contentType string
+	if opts.BodyFunc != nil {
+		var err error
+		payload, contentType, err = opts.BodyFunc()
+		if err != nil {
+			return nil, err
+		}
+	}
+	var body io.Reader
+	if len(payload) > 0 {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, targetURL, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, vals := range opts.Headers {
+		for _, v := range vals {
+			req.Header.Add(k, v)
+		}
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for _, h := range opts.Hooks {
+		if err := h.Prepare(req, payload); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// recorder accumulates the results of concurrent workers into a Report.
+type recorder struct {
+	mu  sync.Mutex
+	rep Report
+	// countResponses increments TotalRequests for every response received;
+	// RunWithOptions instead presets it to the number of scheduled requests.
+	countResponses bool
+}
+
+func newRecorder(countResponses bool) *recorder {
+	return &recorder{rep: Report{StatusCounts: make(map[int]int), Start: time.Now()}, countResponses: countResponses}
+}
+
+// do builds, sends and records a single request.
+func (r *recorder) do(ctx context.Context, client *http.Client, targetURL string, opts Options) {
+	prepStart := time.Now()
+	req, err := newRequest(ctx, targetURL, opts)
+	prep := time.Since(prepStart)
+	if err != nil {
+		r.fail(opts, Sample{Method: opts.Method, URL: targetURL, Err: err})
+		return
+	}
+	sample := Sample{Method: req.Method, URL: req.URL.String(), Prepare: prep}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		sample.Err = err
+		r.fail(opts, sample)
+		return
+	}
+	// drain and close body to allow connection reuse
+	_, err = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	latency := time.Since(start)
+	if err != nil {
+		// truncated or reset bodies are transport errors, not successes
+		sample.Err = err
+		r.fail(opts, sample)
+		return
+	}
+	r.mu.Lock()
+	if r.countResponses {
+		r.rep.TotalRequests++
+	}
+	r.rep.StatusCounts[resp.StatusCode]++
+	if resp.StatusCode == http.StatusOK {
+		r.rep.Succeeded200++
+	}
+	r.rep.Latency.Record(latency)
+	r.rep.Prepare.Record(prep)
+	iv := r.rep.interval(time.Now())
+	iv.Requests++
+	iv.Latency.Record(latency)
+	if resp.StatusCode >= 400 {
+		iv.HTTPErrors++
+	}
+	unexpected := len(opts.Expect) > 0 && !slices.Contains(opts.Expect, resp.StatusCode)
+	if unexpected {
+		r.rep.Unexpected++
+	}
+	if opts.Label != "" {
+		e := r.rep.endpoint(opts.Label)
+		e.Requests++
+		e.StatusCounts[resp.StatusCode]++
+		e.Latency.Record(latency)
+		if unexpected {
+			e.Unexpected++
+		}
+	}
+	r.mu.Unlock()
+	if len(opts.Sinks) > 0 {
+		sample.Time, sample.Label = time.Now(), opts.Label
+		sample.Status, sample.Latency, sample.Unexpected = resp.StatusCode, latency, unexpected
+		emit(opts, sample)
+	}
+}
+
+// fail records a request that produced no response. s.Prepare is zero when
+// the request could not even be built.
+func (r *recorder) fail(opts Options, s Sample) {
+	now := time.Now()
+	r.mu.Lock()
+	r.rep.Errors++
+	iv := r.rep.interval(now)
+	iv.Requests++
+	iv.Errors++
+	if s.Prepare > 0 {
+		r.rep.Prepare.Record(s.Prepare)
+	}
+	if opts.Label != "" {
+		e := r.rep.endpoint(opts.Label)
+		e.Requests++
+		e.Errors++
+	}
+	r.mu.Unlock()
+	if len(opts.Sinks) > 0 {
+		s.Time, s.Label = now, opts.Label
+		emit(opts, s)
+	}
 }
 
 // Run executes a simple HTTP load test using defaults (GET, no headers, no body).
@@ -41,7 +276,9 @@ func Run(ctx context.Context, targetURL string, total, concurrency int) (Report,
 // RunWithOptions executes a HTTP load test against targetURL with custom options.
 func RunWithOptions(ctx context.Context, targetURL string, total, concurrency int, opts Options) (Report, error) {
 	start := time.Now()
-	rep := Report{TotalRequests: total, StatusCounts: make(map[int]int)}
+	rec := newRecorder(false)
+	rec.rep.TotalRequests, rec.rep.Resolution = total, opts.Resolution
+	defer rec.checkpoints(opts, start)()
 
 	client := &http.Client{}
 	defer client.CloseIdleConnections()
@@ -49,7 +286,6 @@ func RunWithOptions(ctx context.Context, targetURL string, total, concurrency in
 	// Work distribution
 	jobs := make(chan struct{})
 	var wg sync.WaitGroup
-	var mu sync.Mutex
 
 	worker := func() {
 		defer wg.Done()
@@ -58,50 +294,7 @@ func RunWithOptions(ctx context.Context, targetURL string, total, concurrency in
 			if ctx.Err() != nil {
 				return
 			}
-			var bodyReader *bytes.Reader
-			if len(opts.Body) > 0 {
-				bodyReader = bytes.NewReader(opts.Body)
-			}
-			method := opts.Method
-			if method == "" {
-				method = http.MethodGet
-			}
-			var req *http.Request
-			var err error
-			if bodyReader != nil {
-				req, err = http.NewRequestWithContext(ctx, method, targetURL, bodyReader)
-			} else {
-				req, err = http.NewRequestWithContext(ctx, method, targetURL, nil)
-			}
-			if err != nil {
-				mu.Lock()
-				rep.Errors++
-				mu.Unlock()
-				continue
-			}
-			// set headers
-			if opts.Headers != nil {
-				for k, vals := range opts.Headers {
-					for _, v := range vals {
-						req.Header.Add(k, v)
-					}
-				}
-			}
-			resp, err := client.Do(req)
-			if err != nil {
-				mu.Lock()
-				rep.Errors++
-				mu.Unlock()
-				continue
-			}
-			// drain and close body to allow connection reuse
-			_ = resp.Body.Close()
-			mu.Lock()
-			rep.StatusCounts[resp.StatusCode]++
-			if resp.StatusCode == http.StatusOK {
-				rep.Succeeded200++
-			}
-			mu.Unlock()
+			rec.do(ctx, client, targetURL, opts)
 		}
 	}
 
@@ -127,6 +320,7 @@ func RunWithOptions(ctx context.Context, targetURL string, total, concurrency in
 	}()
 
 	wg.Wait()
+	rep := rec.rep
 	rep.Duration = time.Since(start)
 	return rep, nil
 }
@@ -134,16 +328,19 @@ func RunWithOptions(ctx context.Context, targetURL string, total, concurrency in
 // RunForDuration executes requests for a given duration at fixed concurrency.
 func RunForDuration(ctx context.Context, targetURL string, d time.Duration, concurrency int, opts Options) (Report, error) {
 	start := time.Now()
-	rep := Report{StatusCounts: make(map[int]int)}
+	rec := newRecorder(true)
+	rec.rep.Resolution = opts.Resolution
+	defer rec.checkpoints(opts, start)()
 
 	client := &http.Client{}
 	defer client.CloseIdleConnections()
 
 	var wg sync.WaitGroup
-	var mu sync.Mutex
 
-	// Deadline goroutine to cancel via context if needed
-	end := time.After(d)
+	// end is closed (not sent on) so every worker observes the deadline
+	end := make(chan struct{})
+	timer := time.AfterFunc(d, func() { close(end) })
+	defer timer.Stop()
 
 	worker := func() {
 		defer wg.Done()
@@ -156,45 +353,7 @@ func RunForDuration(ctx context.Context, targetURL string, d time.Duration, conc
 			default:
 			}
 
-			var body io.Reader
-			if len(opts.Body) > 0 {
-				body = bytes.NewReader(opts.Body)
-			} else {
-				body = nil
-			}
-			method := opts.Method
-			if method == "" {
-				method = http.MethodGet
-			}
-			req, err := http.NewRequestWithContext(ctx, method, targetURL, body)
-			if err != nil {
-				mu.Lock()
-				rep.Errors++
-				mu.Unlock()
-				continue
-			}
-			if opts.Headers != nil {
-				for k, vals := range opts.Headers {
-					for _, v := range vals {
-						req.Header.Add(k, v)
-					}
-				}
-			}
-			resp, err := client.Do(req)
-			if err != nil {
-				mu.Lock()
-				rep.Errors++
-				mu.Unlock()
-				continue
-			}
-			_ = resp.Body.Close()
-			mu.Lock()
-			rep.TotalRequests++
-			rep.StatusCounts[resp.StatusCode]++
-			if resp.StatusCode == http.StatusOK {
-				rep.Succeeded200++
-			}
-			mu.Unlock()
+			rec.do(ctx, client, targetURL, opts)
 		}
 	}
 
@@ -206,6 +365,7 @@ func RunForDuration(ctx context.Context, targetURL string, d time.Duration, conc
 		go worker()
 	}
 	wg.Wait()
+	rep := rec.rep
 	rep.Duration = time.Since(start)
 	return rep, nil
 }
@@ -214,18 +374,19 @@ func RunForDuration(ctx context.Context, targetURL string, d time.Duration, conc
 // using a simple paced job generator and a fixed number of workers.
 func RunForDurationWithRate(ctx context.Context, targetURL string, d time.Duration, concurrency int, opts Options, rps float64) (Report, error) {
 	start := time.Now()
-	rep := Report{StatusCounts: make(map[int]int)}
+	rec := newRecorder(true)
+	rec.rep.Resolution = opts.Resolution
 
 	if rps <= 0 {
-		return rep, nil
+		return rec.rep, nil
 	}
+	defer rec.checkpoints(opts, start)()
 
 	client := &http.Client{}
 	defer client.CloseIdleConnections()
 
 	jobs := make(chan struct{}, 1024)
 	var wg sync.WaitGroup
-	var mu sync.Mutex
 
 	// paced generator
 	tickerInterval := time.Second
@@ -235,13 +396,20 @@ func RunForDurationWithRate(ctx context.Context, targetURL string, d time.Durati
 			tickerInterval = time.Nanosecond
 		}
 	}
+	// Ticks come late or merge when the interval is below the timer
+	// resolution, so every tick releases the jobs due since the start.
+	// Jobs owed for longer than maxLag (blocked by busy workers) are
+	// dropped rather than sent in a burst.
+	maxLag := max(1, int64(rps/100))
 	ticker := time.NewTicker(tickerInterval)
+	genStart := time.Now()
 	end := time.After(d)
 
 	genDone := make(chan struct{})
 	go func() {
 		defer close(genDone)
 		defer close(jobs)
+		var sent int64
 		for {
 			select {
 			case <-ctx.Done():
@@ -249,12 +417,16 @@ func RunForDurationWithRate(ctx context.Context, targetURL string, d time.Durati
 			case <-end:
 				return
 			case <-ticker.C:
-				select {
-				case jobs <- struct{}{}:
-				case <-ctx.Done():
-					return
-				case <-end:
-					return
+				due := int64(time.Since(genStart).Seconds() * rps)
+				sent = max(sent, due-maxLag)
+				for ; sent < due; sent++ {
+					select {
+					case jobs <- struct{}{}:
+					case <-ctx.Done():
+						return
+					case <-end:
+						return
+					}
 				}
 			}
 		}
@@ -266,43 +438,7 @@ func RunForDurationWithRate(ctx context.Context, targetURL string, d time.Durati
 			if ctx.Err() != nil {
 				return
 			}
-			var body io.Reader
-			if len(opts.Body) > 0 {
-				body = bytes.NewReader(opts.Body)
-			}
-			method := opts.Method
-			if method == "" {
-				method = http.MethodGet
-			}
-			req, err := http.NewRequestWithContext(ctx, method, targetURL, body)
-			if err != nil {
-				mu.Lock()
-				rep.Errors++
-				mu.Unlock()
-				continue
-			}
-			if opts.Headers != nil {
-				for k, vals := range opts.Headers {
-					for _, v := range vals {
-						req.Header.Add(k, v)
-					}
-				}
-			}
-			resp, err := client.Do(req)
-			if err != nil {
-				mu.Lock()
-				rep.Errors++
-				mu.Unlock()
-				continue
-			}
-			_ = resp.Body.Close()
-			mu.Lock()
-			rep.TotalRequests++
-			rep.StatusCounts[resp.StatusCode]++
-			if resp.StatusCode == http.StatusOK {
-				rep.Succeeded200++
-			}
-			mu.Unlock()
+			rec.do(ctx, client, targetURL, opts)
 		}
 	}
 
@@ -316,6 +452,7 @@ func RunForDurationWithRate(ctx context.Context, targetURL string, d time.Durati
 	<-genDone
 	wg.Wait()
 	ticker.Stop()
+	rep := rec.rep
 	rep.Duration = time.Since(start)
 	return rep, nil
 }
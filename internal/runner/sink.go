@@ -0,0 +1,35 @@
+package runner
+
+import "time"
+
+// Sink receives every finished request while a test runs, for example to
+// log it or to export live metrics. Record is called by all workers
+// concurrently and should return quickly: the worker waits for it before
+// sending its next request.
+type Sink interface {
+	Record(s Sample)
+}
+
+// Sample is one finished request.
+type Sample struct {
+	// Time is when the request finished.
+	Time   time.Time
+	Label  string
+	Method string
+	URL    string
+	// Status is 0 when no response arrived; Err then says why.
+	Status  int
+	Latency time.Duration
+	// Prepare is the time spent building the request before it was sent.
+	Prepare time.Duration
+	Err     error
+	// Unexpected is set when the status is not in Options.Expect.
+	Unexpected bool
+}
+
+// emit hands s to every sink of opts.
+func emit(opts Options, s Sample) {
+	for _, sink := range opts.Sinks {
+		sink.Record(s)
+	}
+}
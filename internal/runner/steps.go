@@ -0,0 +1,232 @@
+package runner
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Step is one request of a scripted run: where to send it, how to build it
+// and, for paced runs, when to send it relative to the start of the run (or
+// of the flow iteration). The run-wide Options of the first step, the
+// timeline Resolution and the checkpoints, apply to the whole run.
+type Step struct {
+	URL     string
+	Options Options
+	At      time.Duration
+	// Weight is the relative probability of the step in RunWeighted; values
+	// below 1 count as 1.
+	Weight int
+}
+
+// RunSteps sends steps in order using up to concurrency workers, repeating
+// the sequence loops times. When paced is set each step is held until its At
+// offset (steps must be sorted by At) and every loop starts where the
+// previous one ended; otherwise steps are sent as fast as workers allow.
+// A paced step is delayed further when all workers are busy.
+func RunSteps(ctx context.Context, steps []Step, concurrency int, paced bool, loops int) (Report, error) {
+	start := time.Now()
+	rec := newRecorder(true)
+
+	if len(steps) == 0 {
+		return rec.rep, nil
+	}
+	rec.rep.Resolution = steps[0].Options.Resolution
+	defer rec.checkpoints(steps[0].Options, start)()
+	if loops < 1 {
+		loops = 1
+	}
+
+	client := &http.Client{}
+	defer client.CloseIdleConnections()
+
+	jobs := make(chan *Step)
+	var wg sync.WaitGroup
+
+	worker := func() {
+		defer wg.Done()
+		for s := range jobs {
+			if ctx.Err() != nil {
+				return
+			}
+			rec.do(ctx, client, s.URL, s.Options)
+		}
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go worker()
+	}
+
+	go func() {
+		defer close(jobs)
+		loopLen := steps[len(steps)-1].At
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+		for l := 0; l < loops; l++ {
+			for i := range steps {
+				if paced {
+					due := time.Duration(l)*loopLen + steps[i].At
+					if wait := due - time.Since(start); wait > 0 {
+						timer.Reset(wait)
+						select {
+						case <-ctx.Done():
+							return
+						case <-timer.C:
+						}
+					}
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case jobs <- &steps[i]:
+				}
+			}
+		}
+	}()
+
+	wg.Wait()
+	rep := rec.rep
+	rep.Duration = time.Since(start)
+	return rep, nil
+}
+
+// RunWeighted sends total requests with up to concurrency workers; each
+// request picks a step at random with probability proportional to its
+// Weight.
+func RunWeighted(ctx context.Context, steps []Step, total, concurrency int) (Report, error) {
+	start := time.Now()
+	rec := newRecorder(true)
+
+	if len(steps) == 0 || total <= 0 {
+		return rec.rep, nil
+	}
+	rec.rep.Resolution = steps[0].Options.Resolution
+	defer rec.checkpoints(steps[0].Options, start)()
+	// cumulative weights for a binary search per pick
+	cum := make([]int, len(steps))
+	sum := 0
+	for i, s := range steps {
+		sum += max(s.Weight, 1)
+		cum[i] = sum
+	}
+
+	client := &http.Client{}
+	defer client.CloseIdleConnections()
+
+	jobs := make(chan struct{})
+	var wg sync.WaitGroup
+
+	worker := func() {
+		defer wg.Done()
+		for range jobs {
+			if ctx.Err() != nil {
+				return
+			}
+			n := rand.IntN(sum)
+			s := &steps[sort.SearchInts(cum, n+1)]
+			rec.do(ctx, client, s.URL, s.Options)
+		}
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go worker()
+	}
+
+	go func() {
+		defer close(jobs)
+		for i := 0; i < total; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case jobs <- struct{}{}:
+			}
+		}
+	}()
+
+	wg.Wait()
+	rep := rec.rep
+	rep.Duration = time.Since(start)
+	return rep, nil
+}
+
+// RunFlow runs iterations of the whole step sequence, in order, with up to
+// concurrency virtual users each executing one iteration at a time. When
+// paced is set, steps keep their At offsets relative to the start of their
+// iteration (think time); otherwise each step follows the previous one
+// immediately.
+func RunFlow(ctx context.Context, steps []Step, iterations, concurrency int, paced bool) (Report, error) {
+	start := time.Now()
+	rec := newRecorder(true)
+
+	if len(steps) == 0 || iterations <= 0 {
+		return rec.rep, nil
+	}
+	rec.rep.Resolution = steps[0].Options.Resolution
+	defer rec.checkpoints(steps[0].Options, start)()
+
+	client := &http.Client{}
+	defer client.CloseIdleConnections()
+
+	jobs := make(chan struct{})
+	var wg sync.WaitGroup
+
+	worker := func() {
+		defer wg.Done()
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+		for range jobs {
+			iterStart := time.Now()
+			for i := range steps {
+				if ctx.Err() != nil {
+					return
+				}
+				if paced {
+					if wait := steps[i].At - time.Since(iterStart); wait > 0 {
+						timer.Reset(wait)
+						select {
+						case <-ctx.Done():
+							return
+						case <-timer.C:
+						}
+					}
+				}
+				rec.do(ctx, client, steps[i].URL, steps[i].Options)
+			}
+		}
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go worker()
+	}
+
+	go func() {
+		defer close(jobs)
+		for i := 0; i < iterations; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case jobs <- struct{}{}:
+			}
+		}
+	}()
+
+	wg.Wait()
+	rep := rec.rep
+	rep.Duration = time.Since(start)
+	return rep, nil
+}
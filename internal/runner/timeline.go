@@ -0,0 +1,58 @@
+package runner
+
+import "time"
+
+// Interval holds the requests of a test that completed within one window
+// of Report.Resolution.
+type Interval struct {
+	// Requests counts every completed request, failed ones included.
+	Requests int
+	// Errors counts requests that produced no response.
+	Errors int
+	// HTTPErrors counts responses with a 4xx or 5xx status.
+	HTTPErrors int
+	Latency    Histogram
+}
+
+// Window returns the length of the Timeline entries.
+func (r *Report) Window() time.Duration {
+	if r.Resolution <= 0 {
+		return time.Second
+	}
+	return r.Resolution
+}
+
+// interval returns the Timeline entry for a request completed at t, growing
+// the timeline as needed.
+func (r *Report) interval(t time.Time) *Interval {
+	i := max(int(t.Sub(r.Start)/r.Window()), 0)
+	for len(r.Timeline) <= i {
+		r.Timeline = append(r.Timeline, Interval{})
+	}
+	return &r.Timeline[i]
+}
+
+// mergeTimeline adds the timeline of o into r, aligned on wall-clock time to
+// the window. Both are expected to use the same windows.
+func (r *Report) mergeTimeline(o Report) {
+	if len(o.Timeline) == 0 {
+		return
+	}
+	if r.Start.IsZero() {
+		r.Start = o.Start
+	}
+	if len(r.Timeline) == 0 && r.Resolution == 0 {
+		r.Resolution = o.Resolution
+	}
+	offset := max(int(o.Start.Sub(r.Start)/r.Window()), 0)
+	for len(r.Timeline) < offset+len(o.Timeline) {
+		r.Timeline = append(r.Timeline, Interval{})
+	}
+	for i, oi := range o.Timeline {
+		ri := &r.Timeline[offset+i]
+		ri.Requests += oi.Requests
+		ri.Errors += oi.Errors
+		ri.HTTPErrors += oi.HTTPErrors
+		ri.Latency.Merge(oi.Latency)
+	}
+}
@@ -0,0 +1,262 @@
+package scenario
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Access log formats accepted by LoadAccessLog.
+const (
+	LogAuto     = "auto"     // detect per line: JSON objects or common/combined
+	LogCombined = "combined" // nginx default / Apache combined
+	LogCommon   = "common"   // Apache common log format
+	LogJSON     = "json"     // one JSON object per line
+)
+
+// clfPattern matches the common log format with the optional combined
+// referer and user-agent fields.
+var clfPattern = regexp.MustCompile(`^\S+ \S+ \S+ \[([^\]]+)\] "([^"]*)" (?:\d{3}|-) \S+(?: "([^"]*)" "([^"]*)")?`)
+
+const clfTime = "02/Jan/2006:15:04:05 -0700"
+
+// JSON field names tried in order for each value.
+var (
+	jsonTimeKeys    = []string{"time", "timestamp", "@timestamp", "time_local", "time_iso8601", "ts"}
+	jsonMethodKeys  = []string{"method", "request_method", "verb"}
+	jsonPathKeys    = []string{"path", "uri", "request_uri", "url"}
+	jsonRequestKeys = []string{"request"}
+	jsonUAKeys      = []string{"user_agent", "http_user_agent", "userAgent"}
+)
+
+// AccessLogStats reports how many lines LoadAccessLog used and skipped.
+type AccessLogStats struct {
+	Lines   int
+	Skipped int
+}
+
+// LoadAccessLog parses an access log ("-" reads stdin from the given reader)
+// into requests against the path and query that were logged. URLs are
+// relative ("/path?query") and must be rebased before replay. Offsets are
+// relative to the earliest entry; requests logged within the same second by
+// second-precision formats are spread evenly across that second. Lines
+// without a usable timestamp, method or path are skipped.
+func LoadAccessLog(path string, stdin io.Reader, format string) ([]Request, AccessLogStats, error) {
+	var stats AccessLogStats
+	switch format {
+	case "", LogAuto, LogCombined, LogCommon, LogJSON:
+	default:
+		return nil, stats, fmt.Errorf("unsupported log format %q (use auto|combined|common|json)", format)
+	}
+	var r io.Reader = stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, stats, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	type entry struct {
+		req    Request
+		at     time.Time
+		coarse bool // second precision
+	}
+	var entries []entry
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16<<20)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		stats.Lines++
+		var (
+			e   entry
+			ok  bool
+			err error
+		)
+		isJSON := format == LogJSON || ((format == "" || format == LogAuto) && strings.HasPrefix(line, "{"))
+		if isJSON {
+			e.req, e.at, ok, err = parseJSONLogLine(line)
+		} else {
+			e.req, e.at, ok = parseCLFLine(line)
+			e.coarse = true
+		}
+		if err != nil || !ok {
+			stats.Skipped++
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, stats, err
+	}
+	if len(entries) == 0 {
+		return nil, stats, nil
+	}
+
+	// logs are written when requests complete, so restore start order
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].at.Before(entries[j].at) })
+	first := entries[0].at
+	reqs := make([]Request, len(entries))
+	for i := 0; i < len(entries); {
+		j := i + 1
+		if entries[i].coarse {
+			for j < len(entries) && entries[j].coarse && entries[j].at.Equal(entries[i].at) {
+				j++
+			}
+		}
+		n := j - i
+		for k := i; k < j; k++ {
+			offset := entries[k].at.Sub(first)
+			if n > 1 {
+				offset += time.Duration(k-i) * time.Second / time.Duration(n)
+			}
+			req := entries[k].req
+			req.OffsetMS = float64(offset.Microseconds()) / 1000
+			reqs[k] = req
+		}
+		i = j
+	}
+	return reqs, stats, nil
+}
+
+func parseCLFLine(line string) (Request, time.Time, bool) {
+	m := clfPattern.FindStringSubmatch(line)
+	if m == nil {
+		return Request{}, time.Time{}, false
+	}
+	at, err := time.Parse(clfTime, m[1])
+	if err != nil {
+		return Request{}, time.Time{}, false
+	}
+	req, ok := parseRequestLine(m[2])
+	if !ok {
+		return Request{}, time.Time{}, false
+	}
+	if ua := m[4]; ua != "" && ua != "-" {
+		req.Headers = http.Header{"User-Agent": {ua}}
+	}
+	return req, at, true
+}
+
+// parseRequestLine parses `GET /path?q HTTP/1.1`.
+func parseRequestLine(s string) (Request, bool) {
+	parts := strings.Fields(s)
+	if len(parts) < 2 || !strings.HasPrefix(parts[1], "/") {
+		return Request{}, false
+	}
+	method := strings.ToUpper(parts[0])
+	for _, c := range method {
+		if c < 'A' || c > 'Z' {
+			return Request{}, false
+		}
+	}
+	return Request{Method: method, URL: parts[1]}, true
+}
+
+func parseJSONLogLine(line string) (Request, time.Time, bool, error) {
+	var m map[string]any
+	if err := json.Unmarshal([]byte(line), &m); err != nil {
+		return Request{}, time.Time{}, false, err
+	}
+	at, ok := jsonTime(firstValue(m, jsonTimeKeys))
+	if !ok {
+		return Request{}, time.Time{}, false, nil
+	}
+	var req Request
+	method, _ := firstValue(m, jsonMethodKeys).(string)
+	target, _ := firstValue(m, jsonPathKeys).(string)
+	if method != "" && strings.HasPrefix(target, "/") {
+		req = Request{Method: strings.ToUpper(method), URL: target}
+	} else if line, _ := firstValue(m, jsonRequestKeys).(string); line != "" {
+		if req, ok = parseRequestLine(line); !ok {
+			return Request{}, time.Time{}, false, nil
+		}
+	} else {
+		return Request{}, time.Time{}, false, nil
+	}
+	if ua, _ := firstValue(m, jsonUAKeys).(string); ua != "" && ua != "-" {
+		req.Headers = http.Header{"User-Agent": {ua}}
+	}
+	return req, at, true, nil
+}
+
+func firstValue(m map[string]any, keys []string) any {
+	for _, k := range keys {
+		if v, ok := m[k]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+// jsonTime accepts RFC 3339 strings, CLF timestamps and Unix seconds (as a
+// number or a numeric string).
+func jsonTime(v any) (time.Time, bool) {
+	switch t := v.(type) {
+	case float64:
+		return unixSeconds(t), true
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, clfTime} {
+			if at, err := time.Parse(layout, t); err == nil {
+				return at, true
+			}
+		}
+		if f, err := strconv.ParseFloat(t, 64); err == nil {
+			return unixSeconds(f), true
+		}
+	}
+	return time.Time{}, false
+}
+
+func unixSeconds(f float64) time.Time {
+	sec := int64(f)
+	return time.Unix(sec, int64((f-float64(sec))*1e9))
+}
+
+// Path patterns used by PathPattern for variable segments.
+var (
+	uuidSegment = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	numSegment  = regexp.MustCompile(`^\d+$`)
+	hexSegment  = regexp.MustCompile(`^[0-9a-fA-F]{16,}$`)
+)
+
+// PathPattern reduces a request target to a route-like pattern for grouping
+// results: the query is dropped and numeric, UUID and long hex segments
+// become {id}, {uuid} and {hex}, e.g. "/users/42/orders?x=1" becomes
+// "/users/{id}/orders".
+func PathPattern(target string) string {
+	p, _, _ := strings.Cut(target, "?")
+	if i := strings.Index(p, "://"); i >= 0 {
+		// absolute URL: keep only the path
+		rest := p[i+3:]
+		if j := strings.Index(rest, "/"); j >= 0 {
+			p = rest[j:]
+		} else {
+			p = "/"
+		}
+	}
+	segs := strings.Split(p, "/")
+	for i, s := range segs {
+		switch {
+		case numSegment.MatchString(s):
+			segs[i] = "{id}"
+		case uuidSegment.MatchString(s):
+			segs[i] = "{uuid}"
+		case hexSegment.MatchString(s):
+			segs[i] = "{hex}"
+		}
+	}
+	return strings.Join(segs, "/")
+}
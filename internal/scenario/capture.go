@@ -0,0 +1,212 @@
+package scenario
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// skipHeaders are not recorded: hop-by-hop and proxy headers, and headers
+// the HTTP client recomputes when the request is replayed.
+var skipHeaders = map[string]bool{
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Proxy-Authorization": true,
+	"Proxy-Connection":    true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+	"Content-Length":      true,
+	"Host":                true,
+}
+
+// Capture is an HTTP proxy that forwards requests and records each one to
+// Out. Clients either use it as a forward proxy (HTTP_PROXY) or, when Target
+// is set, send requests to it directly as if it were the target.
+//
+// HTTPS requests sent through a forward proxy arrive as CONNECT tunnels.
+// They are passed through without being recorded, since recording them
+// would require intercepting TLS; use Target mode for HTTPS services.
+type Capture struct {
+	Out *Writer
+	// Target, when set, turns Capture into a reverse proxy for this base URL.
+	Target *url.URL
+	// MaxBodySize caps recorded bodies; larger requests are forwarded but
+	// recorded without a body. 0 means no limit.
+	MaxBodySize int64
+	// DropHeaders lists extra header names not to record (e.g. Cookie).
+	DropHeaders []string
+	// OnRecord and OnTunnel, when set, are called for each recorded request
+	// and each unrecorded CONNECT tunnel.
+	OnRecord func(Request)
+	OnTunnel func(host string)
+	// Now defaults to time.Now.
+	Now func() time.Time
+
+	once  sync.Once
+	rp    *httputil.ReverseProxy
+	mu    sync.Mutex
+	first time.Time
+	err   error
+}
+
+func (c *Capture) init() {
+	c.rp = &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			if c.Target != nil {
+				pr.SetURL(c.Target)
+				return
+			}
+			// forward proxy: the request line already carries the absolute URL
+			pr.Out.Host = ""
+		},
+		FlushInterval: -1,
+	}
+}
+
+// Err returns the first error writing to Out, if any.
+func (c *Capture) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// ServeHTTP implements http.Handler.
+func (c *Capture) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.once.Do(c.init)
+	if r.Method == http.MethodConnect {
+		c.tunnel(w, r)
+		return
+	}
+
+	target, err := c.targetURL(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var body []byte
+	if r.Body != nil {
+		limit := c.MaxBodySize
+		if limit <= 0 || limit == math.MaxInt64 {
+			limit = math.MaxInt64
+			body, err = io.ReadAll(r.Body)
+		} else {
+			body, err = io.ReadAll(io.LimitReader(r.Body, limit+1))
+		}
+		if err != nil {
+			http.Error(w, "reading request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		// forward the body untouched, including anything past the limit
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		if int64(len(body)) > limit {
+			body = nil
+		}
+	}
+	c.record(r, target, body)
+	c.rp.ServeHTTP(w, r)
+}
+
+func (c *Capture) targetURL(r *http.Request) (string, error) {
+	if c.Target != nil {
+		u := *c.Target
+		u.Path = strings.TrimSuffix(u.Path, "/") + r.URL.Path
+		u.RawPath = ""
+		u.RawQuery = r.URL.RawQuery
+		return u.String(), nil
+	}
+	if !r.URL.IsAbs() {
+		return "", fmt.Errorf("not a proxy request: %s (configure this address as HTTP proxy or set a target)", r.URL)
+	}
+	return r.URL.String(), nil
+}
+
+func (c *Capture) record(r *http.Request, target string, body []byte) {
+	now := time.Now
+	if c.Now != nil {
+		now = c.Now
+	}
+	t := now()
+
+	hdr := make(http.Header)
+	for k, v := range r.Header {
+		if skipHeaders[k] || c.dropped(k) {
+			continue
+		}
+		hdr[k] = append([]string(nil), v...)
+	}
+	req := Request{Method: r.Method, URL: target, Headers: hdr}
+	req.SetPayload(body)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.first.IsZero() {
+		c.first = t
+	}
+	req.OffsetMS = float64(t.Sub(c.first).Microseconds()) / 1000
+	if err := c.Out.Write(req); err != nil && c.err == nil {
+		c.err = err
+	}
+	if c.OnRecord != nil {
+		c.OnRecord(req)
+	}
+}
+
+func (c *Capture) dropped(name string) bool {
+	for _, d := range c.DropHeaders {
+		if strings.EqualFold(d, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// tunnel relays a CONNECT request without inspecting it.
+func (c *Capture) tunnel(w http.ResponseWriter, r *http.Request) {
+	if c.OnTunnel != nil {
+		c.OnTunnel(r.Host)
+	}
+	upstream, err := net.DialTimeout("tcp", r.Host, 10*time.Second)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		_ = upstream.Close()
+		http.Error(w, "tunneling not supported", http.StatusInternalServerError)
+		return
+	}
+	_, _ = conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
+
+	done := make(chan struct{}, 2)
+	go func() {
+		// flush anything the client sent after the CONNECT line
+		if n := rw.Reader.Buffered(); n > 0 {
+			b, _ := rw.Reader.Peek(n)
+			_, _ = upstream.Write(b)
+		}
+		_, _ = io.Copy(upstream, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(conn, upstream)
+		done <- struct{}{}
+	}()
+	<-done
+	_ = conn.Close()
+	_ = upstream.Close()
+}
@@ -0,0 +1,194 @@
+package scenario
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// HARFilter selects which HAR entries become requests.
+type HARFilter struct {
+	// Domains keeps only these hosts; "example.com" also matches its
+	// subdomains. Empty keeps all.
+	Domains []string
+	// Methods keeps only these methods. Empty keeps all.
+	Methods []string
+	// IncludeStatic keeps images, fonts, stylesheets, scripts and media,
+	// which are dropped by default.
+	IncludeStatic bool
+}
+
+// harLog mirrors the subset of the HAR 1.2 format we use.
+type harLog struct {
+	Log struct {
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time `json:"startedDateTime"`
+	Request         struct {
+		Method   string    `json:"method"`
+		URL      string    `json:"url"`
+		Headers  []harPair `json:"headers"`
+		PostData *struct {
+			MimeType string    `json:"mimeType"`
+			Text     string    `json:"text"`
+			Encoding string    `json:"encoding"`
+			Params   []harPair `json:"params"`
+		} `json:"postData"`
+	} `json:"request"`
+	Response struct {
+		Content struct {
+			MimeType string `json:"mimeType"`
+		} `json:"content"`
+	} `json:"response"`
+}
+
+type harPair struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// staticExt lists file extensions treated as static assets.
+var staticExt = map[string]bool{
+	".js": true, ".mjs": true, ".css": true, ".map": true,
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true,
+	".webp": true, ".avif": true, ".ico": true, ".bmp": true,
+	".woff": true, ".woff2": true, ".ttf": true, ".otf": true, ".eot": true,
+	".mp4": true, ".webm": true, ".mp3": true, ".wav": true, ".ogg": true,
+}
+
+// LoadHAR reads a HAR file ("-" reads stdin from the given reader) and
+// converts the entries kept by filter into requests, ordered by start time
+// with offsets relative to the first kept entry.
+func LoadHAR(path string, stdin io.Reader, filter HARFilter) ([]Request, error) {
+	var r io.Reader = stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var doc harLog
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parsing HAR %s: %w", path, err)
+	}
+
+	var (
+		reqs  []Request
+		first time.Time
+	)
+	for i, e := range doc.Log.Entries {
+		u, err := url.Parse(e.Request.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			// data:, blob:, chrome-extension: and similar entries
+			continue
+		}
+		if !filter.keep(e, u) {
+			continue
+		}
+		req := Request{Method: strings.ToUpper(e.Request.Method), URL: e.Request.URL, Headers: make(http.Header)}
+		for _, h := range e.Request.Headers {
+			name := http.CanonicalHeaderKey(h.Name)
+			// HTTP/2 pseudo-headers (":authority") are not real headers
+			if strings.HasPrefix(h.Name, ":") || skipHeaders[name] {
+				continue
+			}
+			req.Headers.Add(name, h.Value)
+		}
+		if pd := e.Request.PostData; pd != nil {
+			body, err := harBody(pd.Text, pd.Encoding, pd.Params)
+			if err != nil {
+				return nil, fmt.Errorf("HAR entry %d (%s): %w", i+1, e.Request.URL, err)
+			}
+			req.SetPayload(body)
+			if pd.MimeType != "" && req.Headers.Get("Content-Type") == "" {
+				req.Headers.Set("Content-Type", pd.MimeType)
+			}
+		}
+		if first.IsZero() {
+			first = e.StartedDateTime
+		}
+		if !e.StartedDateTime.IsZero() {
+			req.OffsetMS = max(float64(e.StartedDateTime.Sub(first).Microseconds())/1000, 0)
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs, nil
+}
+
+// harBody returns the request payload of a postData object: its text
+// (base64 decoded when so marked) or, for forms exported without text, the
+// URL-encoded params.
+func harBody(text, encoding string, params []harPair) ([]byte, error) {
+	if text != "" {
+		if encoding == "base64" {
+			return base64.StdEncoding.DecodeString(text)
+		}
+		return []byte(text), nil
+	}
+	if len(params) == 0 {
+		return nil, nil
+	}
+	v := url.Values{}
+	for _, p := range params {
+		v.Add(p.Name, p.Value)
+	}
+	return []byte(v.Encode()), nil
+}
+
+func (f HARFilter) keep(e harEntry, u *url.URL) bool {
+	if len(f.Methods) > 0 {
+		ok := false
+		for _, m := range f.Methods {
+			if strings.EqualFold(m, e.Request.Method) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(f.Domains) > 0 {
+		host := strings.ToLower(u.Hostname())
+		ok := false
+		for _, d := range f.Domains {
+			d = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "*."))
+			if host == d || strings.HasSuffix(host, "."+d) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if !f.IncludeStatic && isStatic(u, e.Response.Content.MimeType) {
+		return false
+	}
+	return true
+}
+
+func isStatic(u *url.URL, mimeType string) bool {
+	if staticExt[strings.ToLower(path.Ext(u.Path))] {
+		return true
+	}
+	mt := strings.ToLower(mimeType)
+	for _, p := range []string{"image/", "font/", "audio/", "video/", "text/css", "javascript"} {
+		if strings.Contains(mt, p) {
+			return true
+		}
+	}
+	return false
+}
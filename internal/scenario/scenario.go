@@ -0,0 +1,241 @@
+// Package scenario defines the request file format shared by the record,
+// replay and import commands: one JSON request per line (JSONL), each with
+// its method, URL, headers, body and offset from the start of the session.
+package scenario
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/JeanGrijp/stress-test/internal/feeder"
+	"github.com/JeanGrijp/stress-test/internal/runner"
+	"github.com/JeanGrijp/stress-test/internal/tmpl"
+)
+
+// Request is one recorded or imported request.
+type Request struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+	// BodyEncoding is "base64" for binary bodies; empty means Body is text.
+	BodyEncoding string `json:"body_encoding,omitempty"`
+	// OffsetMS is when the request was sent, in milliseconds since the first
+	// request of the session.
+	OffsetMS float64 `json:"offset_ms"`
+	// Weight is the relative frequency of the request in weighted runs
+	// (0 counts as 1).
+	Weight int `json:"weight,omitempty"`
+	// Label groups the request's results in per-endpoint reports.
+	Label string `json:"label,omitempty"`
+	// Expect lists the acceptable status codes (empty accepts any).
+	Expect []int `json:"expect,omitempty"`
+}
+
+// Offset returns OffsetMS as a duration.
+func (r Request) Offset() time.Duration {
+	return time.Duration(r.OffsetMS * float64(time.Millisecond))
+}
+
+// Payload returns the decoded body.
+func (r Request) Payload() ([]byte, error) {
+	switch r.BodyEncoding {
+	case "":
+		return []byte(r.Body), nil
+	case "base64":
+		return base64.StdEncoding.DecodeString(r.Body)
+	default:
+		return nil, fmt.Errorf("unsupported body_encoding %q", r.BodyEncoding)
+	}
+}
+
+// SetPayload stores b as text when it is valid UTF-8 and as base64 otherwise.
+func (r *Request) SetPayload(b []byte) {
+	if utf8.Valid(b) {
+		r.Body, r.BodyEncoding = string(b), ""
+		return
+	}
+	r.Body, r.BodyEncoding = base64.StdEncoding.EncodeToString(b), "base64"
+}
+
+// Load reads a JSONL scenario file ("-" reads stdin from the given reader).
+func Load(path string, stdin io.Reader) ([]Request, error) {
+	var r io.Reader = stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	return Read(r)
+}
+
+// Read parses JSONL requests from r, skipping blank lines.
+func Read(r io.Reader) ([]Request, error) {
+	var reqs []Request
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 256<<20)
+	line := 0
+	for sc.Scan() {
+		line++
+		b := sc.Bytes()
+		if len(b) == 0 {
+			continue
+		}
+		var req Request
+		if err := json.Unmarshal(b, &req); err != nil {
+			return nil, fmt.Errorf("scenario line %d: %w", line, err)
+		}
+		if req.URL == "" {
+			return nil, fmt.Errorf("scenario line %d: missing url", line)
+		}
+		reqs = append(reqs, req)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return reqs, nil
+}
+
+// Writer appends requests to a JSONL stream. It is safe for concurrent use.
+type Writer struct {
+	mu  sync.Mutex
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+// NewWriter returns a Writer on w.
+func NewWriter(w io.Writer) *Writer {
+	buf := bufio.NewWriter(w)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	return &Writer{buf: buf, enc: enc}
+}
+
+// Write appends req and flushes it, so a recording survives an abrupt stop.
+func (w *Writer) Write(req Request) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.enc.Encode(req); err != nil {
+		return err
+	}
+	return w.buf.Flush()
+}
+
+// Weighted merges requests with the same method, URL and body into one
+// request whose Weight is the number of occurrences (plus their weights),
+// keeping the first occurrence's headers and order.
+func Weighted(reqs []Request) []Request {
+	type key struct{ method, url, body string }
+	index := make(map[key]int)
+	var out []Request
+	for _, r := range reqs {
+		k := key{r.Method, r.URL, r.Body}
+		w := max(r.Weight, 1)
+		if i, ok := index[k]; ok {
+			out[i].Weight += w
+			continue
+		}
+		index[k] = len(out)
+		r.Weight = w
+		out = append(out, r)
+	}
+	return out
+}
+
+// Steps converts requests into runner steps sorted by offset. Offsets are
+// divided by speed (2 replays twice as fast); base supplies hooks and extra
+// headers, which override recorded ones.
+//
+// URLs and header values may contain tmpl placeholders such as
+// {feed:column}; they are rendered per request, with one row of feed shared
+// by the URL and headers of a request.
+func Steps(reqs []Request, base runner.Options, speed float64, feed *feeder.Feeder) ([]runner.Step, error) {
+	if speed <= 0 {
+		speed = 1
+	}
+	steps := make([]runner.Step, 0, len(reqs))
+	for i, r := range reqs {
+		body, err := r.Payload()
+		if err != nil {
+			return nil, fmt.Errorf("request %d (%s %s): %w", i+1, r.Method, r.URL, err)
+		}
+		hdr := r.Headers.Clone()
+		if hdr == nil {
+			hdr = make(http.Header)
+		}
+		for k, v := range base.Headers {
+			hdr[k] = v
+		}
+		opts := base
+		opts.Method = r.Method
+		opts.Body = body
+		if r.Label != "" {
+			opts.Label = r.Label
+		}
+		if len(r.Expect) > 0 {
+			opts.Expect = r.Expect
+		}
+		hook, static, err := templateHook(r.URL, hdr, feed)
+		if err != nil {
+			return nil, fmt.Errorf("request %d (%s %s): %w", i+1, r.Method, r.URL, err)
+		}
+		opts.Headers = static
+		if hook != nil {
+			opts.Hooks = append([]runner.Hook{hook}, base.Hooks...)
+		}
+		steps = append(steps, runner.Step{
+			URL:     r.URL,
+			Options: opts,
+			At:      time.Duration(float64(r.Offset()) / speed),
+			Weight:  r.Weight,
+		})
+	}
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].At < steps[j].At })
+	return steps, nil
+}
+
+// templateHook returns a hook rendering the placeholders of target and hdr
+// (nil when there are none) and the headers left without placeholders.
+func templateHook(target string, hdr http.Header, feed *feeder.Feeder) (runner.Hook, http.Header, error) {
+	h := &tmpl.Headers{Feeder: feed}
+	usesFeed := false
+	if t := tmpl.Parse(target); t.Dynamic() {
+		h.URL = t
+		usesFeed = t.UsesFeed()
+	}
+	static := make(http.Header, len(hdr))
+	for k, vals := range hdr {
+		for _, v := range vals {
+			t := tmpl.Parse(v)
+			if !t.Dynamic() {
+				static.Add(k, v)
+				continue
+			}
+			if t.UsesJWT() {
+				return nil, nil, fmt.Errorf("header %s: {jwt} is not supported in scenario files", k)
+			}
+			h.Headers = append(h.Headers, tmpl.Header{Name: k, Value: t})
+			usesFeed = usesFeed || t.UsesFeed()
+		}
+	}
+	if h.URL == nil && len(h.Headers) == 0 {
+		return nil, hdr, nil
+	}
+	if usesFeed && feed == nil {
+		return nil, nil, errors.New("{feed:...} placeholders require a data feeder")
+	}
+	return h, static, nil
+}
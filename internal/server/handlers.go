@@ -0,0 +1,424 @@
+package server
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	mrand "math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JeanGrijp/stress-test/internal/form"
+)
+
+// parseDelay parses a Go duration ("150ms", "1.5s"); bare numbers are
+// milliseconds.
+func parseDelay(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(f * float64(time.Millisecond)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// queryDelay parses query parameter name as a delay, returning def when it
+// is absent.
+func queryDelay(r *http.Request, name string, def time.Duration) (time.Duration, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	d, err := parseDelay(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	return d, nil
+}
+
+// querySize parses query parameter name as a byte size.
+func (s *Server) querySize(r *http.Request, name string, def int64) (int64, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := form.ParseSize(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	if n > s.cfg.MaxBodySize {
+		return 0, fmt.Errorf("%s: %d exceeds the maximum body size %d", name, n, s.cfg.MaxBodySize)
+	}
+	return n, nil
+}
+
+// sleep waits for d or until the client goes away.
+func sleep(r *http.Request, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-r.Context().Done():
+	}
+}
+
+// commonDelay applies the server-wide latency plus the ?delay= and ?jitter=
+// parameters accepted by every endpoint.
+func (s *Server) commonDelay(r *http.Request) error {
+	d, err := queryDelay(r, "delay", 0)
+	if err != nil {
+		return err
+	}
+	jitter, err := queryDelay(r, "jitter", s.cfg.Jitter)
+	if err != nil {
+		return err
+	}
+	d += s.cfg.Latency
+	if jitter > 0 {
+		d += time.Duration(mrand.Int64N(int64(jitter)))
+	}
+	sleep(r, d)
+	return nil
+}
+
+// statusParam returns the ?status= override, or 0 when absent.
+func statusParam(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("status")
+	if v == "" || strings.HasPrefix(r.URL.Path, "/status") {
+		return 0, nil
+	}
+	return parseStatus(v)
+}
+
+func parseStatus(v string) (int, error) {
+	code, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil || code < 100 || code > 999 {
+		return 0, fmt.Errorf("invalid status code %q", v)
+	}
+	return code, nil
+}
+
+// routeKey groups paths for /stats by their first segment, e.g.
+// /delay/100ms -> /delay.
+func routeKey(path string) string {
+	trimmed := strings.TrimPrefix(path, "/")
+	if i := strings.IndexByte(trimmed, '/'); i >= 0 {
+		trimmed = trimmed[:i]
+	}
+	return "/" + trimmed
+}
+
+func (s *Server) handleOK(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = io.WriteString(w, "ok\n")
+}
+
+func (s *Server) handleDelay(w http.ResponseWriter, r *http.Request) {
+	d, err := parseDelay(r.PathValue("d"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sleep(r, d)
+	s.handleOK(w, r)
+}
+
+func (s *Server) handleRandom(w http.ResponseWriter, r *http.Request) {
+	lo, err := queryDelay(r, "min", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hi, err := queryDelay(r, "max", 100*time.Millisecond)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if hi < lo {
+		http.Error(w, "max must be >= min", http.StatusBadRequest)
+		return
+	}
+	d := lo
+	if hi > lo {
+		d += time.Duration(mrand.Int64N(int64(hi - lo)))
+	}
+	sleep(r, d)
+	s.handleOK(w, r)
+}
+
+func (s *Server) handleLatency(w http.ResponseWriter, r *http.Request) {
+	d, err := sampleLatency(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sleep(r, d)
+	s.handleOK(w, r)
+}
+
+// sampleLatency draws a delay from the distribution described by the query.
+func sampleLatency(r *http.Request) (time.Duration, error) {
+	var d float64
+	switch dist := r.URL.Query().Get("dist"); dist {
+	case "", "normal":
+		mean, err := queryDelay(r, "mean", 50*time.Millisecond)
+		if err != nil {
+			return 0, err
+		}
+		stddev, err := queryDelay(r, "stddev", mean/5)
+		if err != nil {
+			return 0, err
+		}
+		d = float64(mean) + mrand.NormFloat64()*float64(stddev)
+	case "exp":
+		mean, err := queryDelay(r, "mean", 50*time.Millisecond)
+		if err != nil {
+			return 0, err
+		}
+		d = mrand.ExpFloat64() * float64(mean)
+	case "lognormal":
+		median, err := queryDelay(r, "median", 50*time.Millisecond)
+		if err != nil {
+			return 0, err
+		}
+		p99, err := queryDelay(r, "p99", 4*median)
+		if err != nil {
+			return 0, err
+		}
+		if median <= 0 || p99 <= median {
+			return 0, errors.New("lognormal needs 0 < median < p99")
+		}
+		// p99 = median * exp(2.326 * sigma)
+		mu := math.Log(float64(median))
+		sigma := math.Log(float64(p99)/float64(median)) / 2.3263
+		d = math.Exp(mu + sigma*mrand.NormFloat64())
+	default:
+		return 0, fmt.Errorf("unsupported dist %q (use normal|exp|lognormal)", dist)
+	}
+	if d < 0 {
+		d = 0
+	}
+	return time.Duration(d), nil
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	var code int
+	var err error
+	switch {
+	case r.PathValue("code") != "":
+		code, err = parseStatus(r.PathValue("code"))
+	case r.URL.Query().Get("mix") != "":
+		code, err = pickStatus(r.URL.Query().Get("mix"))
+	default:
+		code, err = 200, nil
+		if v := r.URL.Query().Get("status"); v != "" {
+			code, err = parseStatus(v)
+		}
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(code)
+	fmt.Fprintf(w, "%d %s\n", code, http.StatusText(code))
+}
+
+// pickStatus chooses a status from a weighted mix such as "200:90,503:10".
+func pickStatus(mix string) (int, error) {
+	type choice struct {
+		code   int
+		weight float64
+	}
+	var choices []choice
+	var total float64
+	for _, item := range strings.Split(mix, ",") {
+		c, w, ok := strings.Cut(strings.TrimSpace(item), ":")
+		code, err := parseStatus(c)
+		if err != nil {
+			return 0, err
+		}
+		weight := 1.0
+		if ok {
+			weight, err = strconv.ParseFloat(w, 64)
+			if err != nil || weight < 0 {
+				return 0, fmt.Errorf("invalid weight in %q", item)
+			}
+		}
+		choices = append(choices, choice{code, weight})
+		total += weight
+	}
+	if total <= 0 {
+		return 0, errors.New("status mix weights must add up to more than 0")
+	}
+	x := mrand.Float64() * total
+	for _, c := range choices {
+		if x < c.weight {
+			return c.code, nil
+		}
+		x -= c.weight
+	}
+	return choices[len(choices)-1].code, nil
+}
+
+func (s *Server) handleEcho(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, s.cfg.MaxBodySize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"method":  r.Method,
+		"url":     r.URL.String(),
+		"proto":   r.Proto,
+		"host":    r.Host,
+		"remote":  r.RemoteAddr,
+		"headers": r.Header,
+		"body":    string(body),
+		"length":  len(body),
+	})
+}
+
+func (s *Server) handleBytes(w http.ResponseWriter, r *http.Request) {
+	n, err := form.ParseSize(r.PathValue("size"))
+	if err == nil && n > s.cfg.MaxBodySize {
+		err = fmt.Errorf("%d exceeds the maximum body size %d", n, s.cfg.MaxBodySize)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(n, 10))
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.CopyN(w, payloadReader(r.URL.Query().Get("random") == "true"), n)
+}
+
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	chunks := 10
+	if v := q.Get("chunks"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid chunks", http.StatusBadRequest)
+			return
+		}
+		chunks = n
+	}
+	interval, err := queryDelay(r, "interval", 100*time.Millisecond)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	size, err := s.querySize(r, "size", 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if int64(chunks)*size > s.cfg.MaxBodySize {
+		http.Error(w, "stream exceeds the maximum body size", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	src := payloadReader(q.Get("random") == "true")
+	for i := 0; i < chunks; i++ {
+		if i > 0 {
+			sleep(r, interval)
+		}
+		if r.Context().Err() != nil {
+			return
+		}
+		if _, err := io.CopyN(w, src, size); err != nil {
+			return
+		}
+		_ = rc.Flush()
+	}
+}
+
+func (s *Server) handleSlow(w http.ResponseWriter, r *http.Request) {
+	size, err := s.querySize(r, "size", 64<<10)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rate, err := s.querySize(r, "rate", 16<<10)
+	if err != nil || rate <= 0 {
+		http.Error(w, "rate must be a positive size per second", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	// send in ~10 slices per second to keep the rate smooth
+	slice := rate / 10
+	if slice < 1 {
+		slice = 1
+	}
+	src := payloadReader(false)
+	start := time.Now()
+	var sent int64
+	for sent < size {
+		n := min(slice, size-sent)
+		if _, err := io.CopyN(w, src, n); err != nil {
+			return
+		}
+		_ = rc.Flush()
+		sent += n
+		due := time.Duration(float64(sent) / float64(rate) * float64(time.Second))
+		sleep(r, due-time.Since(start))
+		if r.Context().Err() != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) handleDrop(w http.ResponseWriter, r *http.Request) {
+	after, err := s.querySize(r, "after", -1)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if after >= 0 {
+		// promise more bytes than we send so the client sees a truncated body
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.FormatInt(after+1024, 10))
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.CopyN(w, payloadReader(false), after)
+		_ = http.NewResponseController(w).Flush()
+	}
+	// aborts the HTTP/1 connection or resets the HTTP/2 stream
+	panic(http.ErrAbortHandler)
+}
+
+// fillReader yields an endless stream of 'x' bytes.
+type fillReader struct{}
+
+func (fillReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 'x'
+	}
+	return len(p), nil
+}
+
+func payloadReader(random bool) io.Reader {
+	if random {
+		return rand.Reader
+	}
+	return fillReader{}
+}
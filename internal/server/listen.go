@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ListenConfig configures how the server accepts connections.
+type ListenConfig struct {
+	Addr string
+	// TLS serves HTTPS (HTTP/1.1 and HTTP/2 via ALPN). Without CertFile and
+	// KeyFile a self-signed certificate for localhost is generated.
+	TLS      bool
+	CertFile string
+	KeyFile  string
+	// H2C additionally accepts HTTP/2 over cleartext (prior knowledge).
+	H2C bool
+}
+
+// Serve listens on cfg.Addr and serves h until ctx is cancelled, then shuts
+// down gracefully. ready, when set, is called with the bound address once
+// the listener is open (useful with port 0).
+func Serve(ctx context.Context, cfg ListenConfig, h http.Handler, ready func(addr string)) error {
+	srv := &http.Server{
+		Handler:           h,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	if cfg.TLS {
+		protocols.SetHTTP2(true)
+	}
+	if cfg.H2C {
+		protocols.SetUnencryptedHTTP2(true)
+	}
+	srv.Protocols = protocols
+
+	if cfg.TLS {
+		tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+		if cfg.CertFile != "" || cfg.KeyFile != "" {
+			cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+			if err != nil {
+				return err
+			}
+			tlsCfg.Certificates = []tls.Certificate{cert}
+		} else {
+			cert, err := SelfSignedCert()
+			if err != nil {
+				return err
+			}
+			tlsCfg.Certificates = []tls.Certificate{cert}
+		}
+		srv.TLSConfig = tlsCfg
+	}
+
+	ln, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return err
+	}
+	if ready != nil {
+		ready(ln.Addr().String())
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		if cfg.TLS {
+			errc <- srv.ServeTLS(ln, "", "")
+		} else {
+			errc <- srv.Serve(ln)
+		}
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			return err
+		}
+		if err := <-errc; err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
+
+// SelfSignedCert returns a short-lived ECDSA certificate for localhost,
+// 127.0.0.1 and ::1.
+func SelfSignedCert() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "stress-test serve"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(7 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
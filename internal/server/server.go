@@ -0,0 +1,233 @@
+// Package server implements a configurable HTTP target used to calibrate
+// stress-test and to write integration tests without a real service.
+//
+// Every endpoint is parameterized through its path or query string, so a
+// single server can emulate fast, slow, failing and misbehaving backends:
+//
+//	/ok                          200 with a tiny body
+//	/delay/{d}                   respond after d (e.g. 150ms; bare numbers are ms), ?jitter=d
+//	/random?min=d&max=d          uniform random latency
+//	/latency?dist=...            latency drawn from a distribution:
+//	                               dist=normal&mean=d&stddev=d
+//	                               dist=exp&mean=d
+//	                               dist=lognormal&median=d&p99=d
+//	/status/{code}               fixed status code
+//	/status?mix=200:90,503:10    weighted status code mix
+//	/echo                        echo method, URL, headers and body as JSON
+//	/bytes/{size}                body of size bytes (e.g. 10MiB), ?random=true
+//	/stream?chunks=n&interval=d&size=s   chunked streaming response
+//	/slow?size=s&rate=s          body sent at rate bytes per second
+//	/drop                        abort the connection without a response
+//	/drop?after=s                send headers and s bytes, then abort (truncated body)
+//	/stats                       server-side counters as JSON
+//
+// Query parameters delay, status and jitter are also honoured by every
+// endpoint, e.g. /echo?delay=20ms&status=201.
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Config configures the handler.
+type Config struct {
+	// Latency is added to every request before the endpoint runs.
+	Latency time.Duration
+	// Jitter adds a uniform random delay in [0, Jitter) to every request.
+	Jitter time.Duration
+	// MaxBodySize caps generated bodies (/bytes, /stream, /slow). Default 1GiB.
+	MaxBodySize int64
+}
+
+// Server is an http.Handler serving the calibration endpoints.
+type Server struct {
+	cfg   Config
+	mux   *http.ServeMux
+	start time.Time
+
+	total    atomic.Int64
+	inFlight atomic.Int64
+
+	mu     sync.Mutex
+	byPath map[string]*pathStats
+}
+
+type pathStats struct {
+	Requests int64
+	Statuses map[int]int64
+	// HandlingTotal is the server-side time spent on requests, including
+	// injected latency; compare it with client latency to estimate overhead.
+	HandlingTotal time.Duration
+}
+
+// New returns a handler serving the calibration endpoints.
+func New(cfg Config) *Server {
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = 1 << 30
+	}
+	s := &Server{cfg: cfg, mux: http.NewServeMux(), start: time.Now(), byPath: make(map[string]*pathStats)}
+	s.mux.HandleFunc("/{$}", s.handleIndex)
+	s.mux.HandleFunc("/ok", s.handleOK)
+	s.mux.HandleFunc("/delay/{d}", s.handleDelay)
+	s.mux.HandleFunc("/random", s.handleRandom)
+	s.mux.HandleFunc("/latency", s.handleLatency)
+	s.mux.HandleFunc("/status/{code}", s.handleStatus)
+	s.mux.HandleFunc("/status", s.handleStatus)
+	s.mux.HandleFunc("/echo", s.handleEcho)
+	s.mux.HandleFunc("/bytes/{size}", s.handleBytes)
+	s.mux.HandleFunc("/stream", s.handleStream)
+	s.mux.HandleFunc("/slow", s.handleSlow)
+	s.mux.HandleFunc("/drop", s.handleDrop)
+	s.mux.HandleFunc("GET /stats", s.handleStats)
+	return s
+}
+
+// statusRecorder captures the status code written by a handler and applies
+// the ?status= override.
+type statusRecorder struct {
+	http.ResponseWriter
+	status   int
+	override int
+}
+
+func (w *statusRecorder) WriteHeader(code int) {
+	if w.status != 0 {
+		return
+	}
+	if w.override != 0 {
+		code = w.override
+	}
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush lets streaming endpoints flush through the recorder.
+func (w *statusRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (w *statusRecorder) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	s.total.Add(1)
+	s.inFlight.Add(1)
+	rec := &statusRecorder{ResponseWriter: w}
+	defer func() {
+		s.inFlight.Add(-1)
+		status := rec.status
+		if status == 0 {
+			status = -1 // aborted before any response
+		}
+		s.observe(r.URL.Path, status, time.Since(start))
+	}()
+
+	if r.URL.Path != "/stats" {
+		code, err := statusParam(r)
+		if err != nil {
+			http.Error(rec, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.commonDelay(r); err != nil {
+			http.Error(rec, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rec.override = code
+	}
+	s.mux.ServeHTTP(rec, r)
+}
+
+func (s *Server) observe(path string, status int, d time.Duration) {
+	key := routeKey(path)
+	s.mu.Lock()
+	ps, ok := s.byPath[key]
+	if !ok {
+		ps = &pathStats{Statuses: make(map[int]int64)}
+		s.byPath[key] = ps
+	}
+	ps.Requests++
+	ps.Statuses[status]++
+	ps.HandlingTotal += d
+	s.mu.Unlock()
+}
+
+// Stats is the document served by /stats.
+type Stats struct {
+	UptimeSeconds float64              `json:"uptime_seconds"`
+	Requests      int64                `json:"requests"`
+	InFlight      int64                `json:"in_flight"`
+	Paths         map[string]PathStats `json:"paths"`
+}
+
+// PathStats are the counters of one route.
+type PathStats struct {
+	Requests       int64            `json:"requests"`
+	Statuses       map[string]int64 `json:"statuses"`
+	MeanHandlingMS float64          `json:"mean_handling_ms"`
+}
+
+// Snapshot returns the current counters.
+func (s *Server) Snapshot() Stats {
+	st := Stats{
+		UptimeSeconds: time.Since(s.start).Seconds(),
+		Requests:      s.total.Load(),
+		InFlight:      s.inFlight.Load(),
+		Paths:         make(map[string]PathStats),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, ps := range s.byPath {
+		out := PathStats{Requests: ps.Requests, Statuses: make(map[string]int64, len(ps.Statuses))}
+		for code, n := range ps.Statuses {
+			label := "aborted"
+			if code > 0 {
+				label = strconv.Itoa(code)
+			}
+			out.Statuses[label] = n
+		}
+		if ps.Requests > 0 {
+			out.MeanHandlingMS = float64(ps.HandlingTotal) / float64(ps.Requests) / float64(time.Millisecond)
+		}
+		st.Paths[k] = out
+	}
+	return st
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Snapshot())
+}
+
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	routes := []string{
+		"/ok", "/delay/{d}", "/random?min=&max=", "/latency?dist=normal|exp|lognormal",
+		"/status/{code}", "/status?mix=200:90,503:10", "/echo", "/bytes/{size}",
+		"/stream?chunks=&interval=&size=", "/slow?size=&rate=", "/drop?after=", "/stats",
+	}
+	sort.Strings(routes)
+	writeJSON(w, http.StatusOK, map[string]any{"endpoints": routes})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
@@ -0,0 +1,66 @@
+package sign
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestExpandCanonical(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com/orders/42?x=1&y=2", nil)
+	req.Header.Set("X-Tenant", "abc")
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{DefaultCanonical, "POST\n/orders/42\nx=1&y=2\n1700000000\n015abd7f5cc57a2dd94b7590f04ad8084273905ee33ec5cebeae62276a97f862"},
+		{`{host}\t{header:X-Tenant}`, "example.com\tabc"},
+		{`{url}`, "https://example.com/orders/42?x=1&y=2"},
+		{`a\\n{typo}`, `a\n{typo}`},
+		{`{method`, "{method"},
+	}
+	for _, tt := range tests {
+		if got := expandCanonical(tt.tmpl, req, []byte(`{"a":1}`), "1700000000"); got != tt.want {
+			t.Errorf("expandCanonical(%q) = %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+}
+
+func TestHMACPrepare(t *testing.T) {
+	now := func() time.Time { return time.Unix(1700000000, 0) }
+	tests := []struct {
+		name   string
+		h      HMAC
+		header string
+		want   string
+	}{
+		{
+			name:   "default canonical, hex",
+			h:      HMAC{Key: []byte("secret"), Now: now},
+			header: "X-Signature",
+			want:   "9bcce4e5a5b98bf255aac8814c30fc6a464c3da41f55fe6da0e0965d58868b57",
+		},
+		{
+			name: "custom canonical, base64 and prefix",
+			h: HMAC{Key: []byte("secret"), Canonical: "{method} {host} {header:X-Tenant} {timestamp}",
+				Header: "Authorization", Prefix: "HMAC ", Encoding: "base64", TimestampFormat: "rfc3339", Now: now},
+			header: "Authorization",
+			want:   "HMAC bygCUTIEVA91zTFDfIm/fHzKU+JZZQCQN250s9EqxhI=",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, "https://example.com/orders/42?x=1&y=2", nil)
+			req.Header.Set("X-Tenant", "abc")
+			if err := tt.h.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.h.Prepare(req, []byte(`{"a":1}`)); err != nil {
+				t.Fatal(err)
+			}
+			if got := req.Header.Get(tt.header); got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+// Package sink implements runner.Sink outputs that follow a test while it
+// runs: a per-request NDJSON log, optionally rotated, and a Prometheus
+// Pushgateway exporter.
+package sink
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/JeanGrijp/stress-test/internal/runner"
+)
+
+// NDJSON writes one JSON object per finished request, e.g.
+//
+//	{"time":"2026-01-02T15:04:05.123456Z","label":"GET /users","method":"GET","url":"https://example.com/users","status":200,"latency_ms":12.5}
+//
+// Requests without a response have no status and an "error" instead.
+type NDJSON struct {
+	mu  sync.Mutex
+	w   *bufio.Writer
+	c   io.Closer
+	err error
+
+	// rotation, see NewRotatingNDJSON
+	path    string
+	maxSize int64
+	keep    int
+	size    int64
+}
+
+type ndjsonLine struct {
+	Time       string  `json:"time"`
+	Label      string  `json:"label,omitempty"`
+	Method     string  `json:"method,omitempty"`
+	URL        string  `json:"url"`
+	Status     int     `json:"status,omitempty"`
+	LatencyMS  float64 `json:"latency_ms,omitempty"`
+	PrepareMS  float64 `json:"prepare_ms,omitempty"`
+	Unexpected bool    `json:"unexpected_status,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// NewNDJSON logs to w; Close closes it when it is an io.Closer.
+func NewNDJSON(w io.Writer) *NDJSON {
+	s := &NDJSON{w: bufio.NewWriterSize(w, 64*1024)}
+	s.c, _ = w.(io.Closer)
+	return s
+}
+
+// NewRotatingNDJSON logs to the file at path and rotates it once it
+// reaches maxSize bytes, so long tests keep a bounded log: path is renamed
+// to path.1, path.1 to path.2 and so on, keeping the keep most recent
+// rotated files.
+func NewRotatingNDJSON(path string, maxSize int64, keep int) (*NDJSON, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	s := NewNDJSON(f)
+	s.path, s.maxSize, s.keep = path, maxSize, keep
+	return s, nil
+}
+
+// rotate moves the full log aside and starts a new one; s.mu is held.
+func (s *NDJSON) rotate() error {
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	if err := s.c.Close(); err != nil {
+		return err
+	}
+	if s.keep == 0 {
+		if err := os.Remove(s.path); err != nil {
+			return err
+		}
+	}
+	for i := s.keep; i >= 1; i-- {
+		from := s.path
+		if i > 1 {
+			from = fmt.Sprintf("%s.%d", s.path, i-1)
+		}
+		if err := os.Rename(from, fmt.Sprintf("%s.%d", s.path, i)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	f, err := os.Create(s.path)
+	if err != nil {
+		return err
+	}
+	s.w.Reset(f)
+	s.c, s.size = f, 0
+	return nil
+}
+
+// Record implements runner.Sink.
+func (s *NDJSON) Record(smp runner.Sample) {
+	line := ndjsonLine{
+		Time:       smp.Time.UTC().Format(time.RFC3339Nano),
+		Label:      smp.Label,
+		Method:     smp.Method,
+		URL:        smp.URL,
+		Status:     smp.Status,
+		LatencyMS:  ms(smp.Latency),
+		PrepareMS:  ms(smp.Prepare),
+		Unexpected: smp.Unexpected,
+	}
+	if smp.Err != nil {
+		line.Error = smp.Err.Error()
+	}
+	data, err := json.Marshal(line)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return
+	}
+	if err == nil {
+		var n int
+		n, err = s.w.Write(append(data, '\n'))
+		s.size += int64(n)
+	}
+	if err == nil && s.maxSize > 0 && s.size >= s.maxSize {
+		err = s.rotate()
+	}
+	s.err = err
+}
+
+// Close flushes the log and returns the first write error.
+func (s *NDJSON) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.w.Flush(); s.err == nil {
+		s.err = err
+	}
+	if s.c != nil {
+		if err := s.c.Close(); s.err == nil {
+			s.err = err
+		}
+	}
+	return s.err
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
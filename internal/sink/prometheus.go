@@ -0,0 +1,161 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JeanGrijp/stress-test/internal/runner"
+)
+
+// Prometheus pushes request counters and a latency summary to a Prometheus
+// Pushgateway while the test runs and once more when it is closed.
+type Prometheus struct {
+	url    string
+	client *http.Client
+
+	mu         sync.Mutex
+	requests   map[promKey]int64
+	unexpected int64
+	latency    runner.Histogram
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type promKey struct {
+	endpoint, status string
+}
+
+// promQuantiles are the quantiles of the latency summary.
+var promQuantiles = []float64{0.5, 0.9, 0.95, 0.99}
+
+// NewPrometheus pushes to the Pushgateway at url every interval (never
+// when interval is 0). A url without a /metrics/job/ path pushes to the
+// job "stress-test".
+func NewPrometheus(url string, interval time.Duration) *Prometheus {
+	url = strings.TrimRight(url, "/")
+	if !strings.Contains(url, "/metrics/job/") {
+		url += "/metrics/job/stress-test"
+	}
+	p := &Prometheus{
+		url:      url,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		requests: make(map[promKey]int64),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go p.loop(interval)
+	return p
+}
+
+func (p *Prometheus) loop(interval time.Duration) {
+	defer close(p.done)
+	if interval <= 0 {
+		<-p.stop
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-t.C:
+			// failed pushes are retried on the next tick; Close reports
+			// the last one
+			_ = p.push(context.Background())
+		}
+	}
+}
+
+// Record implements runner.Sink.
+func (p *Prometheus) Record(s runner.Sample) {
+	status := "error"
+	if s.Err == nil {
+		status = strconv.Itoa(s.Status)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests[promKey{s.Label, status}]++
+	if s.Unexpected {
+		p.unexpected++
+	}
+	if s.Err == nil {
+		p.latency.Record(s.Latency)
+	}
+}
+
+// Close stops the periodic pushes and pushes the final values.
+func (p *Prometheus) Close() error {
+	close(p.stop)
+	<-p.done
+	return p.push(context.Background())
+}
+
+func (p *Prometheus) push(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, p.url, bytes.NewReader(p.exposition()))
+	if err != nil {
+		return fmt.Errorf("prometheus push: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("prometheus push: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("prometheus push: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// exposition renders the metrics in the Prometheus text format.
+func (p *Prometheus) exposition() []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var b bytes.Buffer
+	keys := make([]promKey, 0, len(p.requests))
+	for k := range p.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].endpoint != keys[j].endpoint {
+			return keys[i].endpoint < keys[j].endpoint
+		}
+		return keys[i].status < keys[j].status
+	})
+	b.WriteString("# HELP stress_test_requests_total Finished requests by status (\"error\" when no response arrived).\n")
+	b.WriteString("# TYPE stress_test_requests_total counter\n")
+	for _, k := range keys {
+		labels := fmt.Sprintf("status=%q", k.status)
+		if k.endpoint != "" {
+			labels = "endpoint=" + promLabel(k.endpoint) + "," + labels
+		}
+		fmt.Fprintf(&b, "stress_test_requests_total{%s} %d\n", labels, p.requests[k])
+	}
+	b.WriteString("# HELP stress_test_unexpected_status_total Responses whose status was not expected.\n")
+	b.WriteString("# TYPE stress_test_unexpected_status_total counter\n")
+	fmt.Fprintf(&b, "stress_test_unexpected_status_total %d\n", p.unexpected)
+	b.WriteString("# HELP stress_test_request_duration_seconds Response latency.\n")
+	b.WriteString("# TYPE stress_test_request_duration_seconds summary\n")
+	for _, q := range promQuantiles {
+		fmt.Fprintf(&b, "stress_test_request_duration_seconds{quantile=\"%g\"} %g\n", q, p.latency.Quantile(q).Seconds())
+	}
+	fmt.Fprintf(&b, "stress_test_request_duration_seconds_sum %g\n", p.latency.Sum.Seconds())
+	fmt.Fprintf(&b, "stress_test_request_duration_seconds_count %d\n", p.latency.Count)
+	return b.Bytes()
+}
+
+// promLabel quotes a label value as the text format expects.
+func promLabel(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v) + `"`
+}
@@ -0,0 +1,71 @@
+package tmpl
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/JeanGrijp/stress-test/internal/feeder"
+)
+
+// Header is a request header whose value is rendered per request.
+type Header struct {
+	Name  string
+	Value *Template
+}
+
+// Headers renders templated headers, and optionally the URL, on every
+// request. It implements runner.Hook and is safe for concurrent use.
+type Headers struct {
+	Headers []Header
+	// URL, when set, replaces the request URL; substituted values are
+	// path-escaped.
+	URL *Template
+	// Feeder supplies one row per request for {feed:column} placeholders.
+	Feeder *feeder.Feeder
+	// JWT mints the token used by {jwt}.
+	JWT *JWT
+}
+
+// Prepare implements runner.Hook.
+func (h *Headers) Prepare(req *http.Request, _ []byte) error {
+	c := &Context{JWT: h.JWT}
+	if h.Feeder != nil {
+		c.Row = h.Feeder.Next()
+	}
+	if h.URL != nil {
+		raw, err := h.URL.ExecuteEscaped(c, url.PathEscape)
+		if err != nil {
+			return err
+		}
+		u, err := url.Parse(raw)
+		if err != nil {
+			return fmt.Errorf("rendered URL %q: %w", raw, err)
+		}
+		req.URL = u
+		req.Host = u.Host
+	}
+	for i, hd := range h.Headers {
+		v, err := hd.Value.Execute(c)
+		if err != nil {
+			return err
+		}
+		// the first template for a name replaces, later ones append, so
+		// repeated headers keep their multiplicity
+		if i == 0 || !h.seenBefore(i, hd.Name) {
+			req.Header.Set(hd.Name, v)
+		} else {
+			req.Header.Add(hd.Name, v)
+		}
+	}
+	return nil
+}
+
+func (h *Headers) seenBefore(i int, name string) bool {
+	for _, prev := range h.Headers[:i] {
+		if http.CanonicalHeaderKey(prev.Name) == http.CanonicalHeaderKey(name) {
+			return true
+		}
+	}
+	return false
+}
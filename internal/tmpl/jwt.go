@@ -0,0 +1,79 @@
+package tmpl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/JeanGrijp/stress-test/internal/jwt"
+)
+
+// Claim is a JWT claim whose value is a template rendered per request.
+type Claim struct {
+	Name  string
+	Value *Template
+	// Raw values are decoded as JSON after rendering (numbers, booleans,
+	// arrays, objects) instead of being sent as strings.
+	Raw bool
+}
+
+// ParseClaim parses "name=value" (string claim) or "name:=json" (raw JSON
+// claim). Both forms may contain placeholders, e.g. "sub={feed:user_id}".
+func ParseClaim(spec string) (Claim, error) {
+	if name, val, ok := strings.Cut(spec, ":="); ok && name != "" && !strings.Contains(name, "=") {
+		return Claim{Name: name, Value: Parse(val), Raw: true}, nil
+	}
+	name, val, ok := strings.Cut(spec, "=")
+	if !ok || name == "" {
+		return Claim{}, fmt.Errorf("invalid JWT claim (use 'name=value' or 'name:=json'): %q", spec)
+	}
+	return Claim{Name: name, Value: Parse(val)}, nil
+}
+
+// JWT mints one token per request. Registered claims iat, exp (when TTL is
+// set) and jti are added automatically unless overridden by Claims.
+type JWT struct {
+	Signer   *jwt.Signer
+	Claims   []Claim
+	TTL      time.Duration
+	Issuer   string
+	Audience string
+}
+
+// Mint builds and signs the claims for the request described by c.
+func (j *JWT) Mint(c *Context) (string, error) {
+	now := time.Now()
+	claims := map[string]any{
+		"iat": now.Unix(),
+		"jti": newUUID(),
+	}
+	if j.TTL > 0 {
+		claims["exp"] = now.Add(j.TTL).Unix()
+	}
+	if j.Issuer != "" {
+		claims["iss"] = j.Issuer
+	}
+	if j.Audience != "" {
+		claims["aud"] = j.Audience
+	}
+	for _, cl := range j.Claims {
+		if cl.Value.UsesJWT() {
+			return "", fmt.Errorf("JWT claim %q cannot reference {jwt}", cl.Name)
+		}
+		v, err := cl.Value.Execute(c)
+		if err != nil {
+			return "", err
+		}
+		if !cl.Raw {
+			claims[cl.Name] = v
+			continue
+		}
+		var raw any
+		if err := json.Unmarshal([]byte(v), &raw); err != nil {
+			return "", fmt.Errorf("JWT claim %q: invalid JSON %q", cl.Name, v)
+		}
+		claims[cl.Name] = raw
+	}
+	return j.Signer.Sign(claims)
+}
@@ -0,0 +1,203 @@
+// Package tmpl expands per-request placeholders in header values and URLs,
+// such as data feeder columns and freshly minted JWTs.
+//
+// Placeholders use the same brace syntax as the HMAC canonical string:
+//
+//	{feed:column}   value of column in the data feeder row of this request
+//	{jwt}           JWT minted for this request (see JWT)
+//	{uuid}          random UUID v4
+//	{unix}          current Unix time in seconds
+//	{unix_ms}       current Unix time in milliseconds
+//
+// Unknown placeholders are kept verbatim.
+package tmpl
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type segKind int
+
+const (
+	segLiteral segKind = iota
+	segFeed
+	segJWT
+	segUUID
+	segUnix
+	segUnixMS
+)
+
+type segment struct {
+	kind segKind
+	text string // literal text or feed column
+}
+
+// Template is a parsed value with placeholders.
+type Template struct {
+	raw  string
+	segs []segment
+}
+
+// Parse parses s. It never fails; text that is not a known placeholder is
+// kept literally.
+func Parse(s string) *Template {
+	t := &Template{raw: s}
+	var lit strings.Builder
+	flush := func() {
+		if lit.Len() > 0 {
+			t.segs = append(t.segs, segment{kind: segLiteral, text: lit.String()})
+			lit.Reset()
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '{' {
+			lit.WriteByte(s[i])
+			continue
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			lit.WriteString(s[i:])
+			break
+		}
+		name := s[i+1 : i+end]
+		seg, ok := parsePlaceholder(name)
+		if !ok {
+			lit.WriteString(s[i : i+end+1])
+		} else {
+			flush()
+			t.segs = append(t.segs, seg)
+		}
+		i += end
+	}
+	flush()
+	return t
+}
+
+func parsePlaceholder(name string) (segment, bool) {
+	if col, ok := strings.CutPrefix(name, "feed:"); ok && col != "" {
+		return segment{kind: segFeed, text: col}, true
+	}
+	switch name {
+	case "jwt":
+		return segment{kind: segJWT}, true
+	case "uuid":
+		return segment{kind: segUUID}, true
+	case "unix":
+		return segment{kind: segUnix}, true
+	case "unix_ms":
+		return segment{kind: segUnixMS}, true
+	}
+	return segment{}, false
+}
+
+// String returns the original text.
+func (t *Template) String() string { return t.raw }
+
+// Dynamic reports whether t contains any placeholder.
+func (t *Template) Dynamic() bool {
+	for _, s := range t.segs {
+		if s.kind != segLiteral {
+			return true
+		}
+	}
+	return false
+}
+
+// UsesFeed reports whether t references a feeder column.
+func (t *Template) UsesFeed() bool { return t.uses(segFeed) }
+
+// UsesJWT reports whether t references {jwt}.
+func (t *Template) UsesJWT() bool { return t.uses(segJWT) }
+
+func (t *Template) uses(k segKind) bool {
+	for _, s := range t.segs {
+		if s.kind == k {
+			return true
+		}
+	}
+	return false
+}
+
+// Context carries the per-request state shared by all templates of one
+// request, so every header sees the same feeder row and the same JWT.
+type Context struct {
+	Row map[string]string
+	JWT *JWT
+
+	token  string
+	minted bool
+}
+
+// Execute renders t for the request described by c.
+func (t *Template) Execute(c *Context) (string, error) {
+	return t.ExecuteEscaped(c, nil)
+}
+
+// ExecuteEscaped renders t like Execute, passing every substituted value
+// (but not the literal text) through escape, e.g. url.PathEscape for URLs.
+func (t *Template) ExecuteEscaped(c *Context, escape func(string) string) (string, error) {
+	if len(t.segs) == 1 && t.segs[0].kind == segLiteral {
+		return t.segs[0].text, nil
+	}
+	var b strings.Builder
+	for _, s := range t.segs {
+		var v string
+		switch s.kind {
+		case segLiteral:
+			b.WriteString(s.text)
+			continue
+		case segFeed:
+			var ok bool
+			v, ok = c.Row[s.text]
+			if !ok {
+				return "", fmt.Errorf("template %q: feeder has no column %q", t.raw, s.text)
+			}
+		case segJWT:
+			tok, err := c.jwtToken()
+			if err != nil {
+				return "", err
+			}
+			v = tok
+		case segUUID:
+			v = newUUID()
+		case segUnix:
+			v = strconv.FormatInt(time.Now().Unix(), 10)
+		case segUnixMS:
+			v = strconv.FormatInt(time.Now().UnixMilli(), 10)
+		}
+		if escape != nil {
+			v = escape(v)
+		}
+		b.WriteString(v)
+	}
+	return b.String(), nil
+}
+
+// jwtToken mints the request's JWT on first use.
+func (c *Context) jwtToken() (string, error) {
+	if c.minted {
+		return c.token, nil
+	}
+	if c.JWT == nil {
+		return "", errors.New("{jwt} used but no JWT signing key is configured")
+	}
+	tok, err := c.JWT.Mint(c)
+	if err != nil {
+		return "", err
+	}
+	c.token, c.minted = tok, true
+	return tok, nil
+}
+
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}